@@ -27,7 +27,7 @@ func (a CodeObject) GetIndexes() map[string]entity.EntityIndex {
 			Fields: []string{"ID"},
 		},
 		"byCodeHash": {
-			Fields: []string{"Code", "VmType", "VmVersion"},
+			Fields: []string{"CodeHash", "VmType", "VmVersion"},
 		},
 	}
 }
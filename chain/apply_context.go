@@ -3,17 +3,22 @@ package chain
 import (
 	"context"
 	"fmt"
+	gotime "time"
 
 	"github.com/MetalBlockchain/antelopevm/chain/account"
 	"github.com/MetalBlockchain/antelopevm/chain/authority"
 	"github.com/MetalBlockchain/antelopevm/chain/fc"
 	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/protocol"
 	"github.com/MetalBlockchain/antelopevm/chain/table"
 	"github.com/MetalBlockchain/antelopevm/chain/time"
 	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
 	"github.com/MetalBlockchain/antelopevm/config"
 	"github.com/MetalBlockchain/antelopevm/crypto"
 	"github.com/MetalBlockchain/antelopevm/crypto/ecc"
+	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
+	"github.com/MetalBlockchain/antelopevm/math"
 	"github.com/MetalBlockchain/antelopevm/state"
 	"github.com/MetalBlockchain/antelopevm/utils"
 	"github.com/MetalBlockchain/antelopevm/wasm"
@@ -26,6 +31,7 @@ var (
 	_ ApplyContext         = &applyContext{}
 
 	errDatabaseAccessViolation = fmt.Errorf("db access violation")
+	errReadOnlyTableOperation  = fmt.Errorf("cannot modify a db record when executing a read-only transaction")
 )
 
 type Notified struct {
@@ -38,7 +44,10 @@ type ApplyContext interface {
 	GetAuthorizationManager() *AuthorizationManager
 	GetAction() transaction.Action
 	RequireAuthorization(name.AccountName) error
-	AddRamUsage(account name.AccountName, delta int64)
+	AddRamUsage(account name.AccountName, delta int64) error
+	PendingBlockTime() time.TimePoint
+	IsBuiltinActivated(codename protocol.BuiltinProtocolFeatureType) (bool, error)
+	EvictCode(codeHash crypto.Sha256, vmType uint8, vmVersion uint8)
 }
 
 type applyContext struct {
@@ -115,6 +124,19 @@ func (a *applyContext) GetSession() *state.Session {
 	return a.Session
 }
 
+func (a *applyContext) PendingBlockTime() time.TimePoint {
+	return a.Control.PendingBlockTime()
+}
+
+func (a *applyContext) IsBuiltinActivated(codename protocol.BuiltinProtocolFeatureType) (bool, error) {
+	return a.Control.GetProtocolFeatureManager(a.Session).IsBuiltinFeatureActivated(codename)
+}
+
+// EvictCode drops the compiled contract once its code object is removed
+func (a *applyContext) EvictCode(codeHash crypto.Sha256, vmType uint8, vmVersion uint8) {
+	a.Control.WasmRuntime.Evict(wasm.ModuleKey{CodeHash: codeHash, VmType: vmType, VmVersion: vmVersion})
+}
+
 func (a *applyContext) GetAuthorizationManager() *AuthorizationManager {
 	return a.Authorization
 }
@@ -151,12 +173,16 @@ func (a *applyContext) Exec() error {
 
 	// Execute context free inlines
 	for _, ordinal := range a.CfaInlineActions {
-		a.TrxContext.ExecuteAction(ordinal, a.RecurseDepth+1)
+		if err := a.TrxContext.ExecuteAction(ordinal, a.RecurseDepth+1); err != nil {
+			return err
+		}
 	}
 
 	// Execute non-context free inlines
 	for _, ordinal := range a.InlineActions {
-		a.TrxContext.ExecuteAction(ordinal, a.RecurseDepth+1)
+		if err := a.TrxContext.ExecuteAction(ordinal, a.RecurseDepth+1); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -171,37 +197,73 @@ func (a *applyContext) execOne() error {
 
 	a.Privileged = receiverAccount.IsPrivileged()
 
+	a.ConsoleOutput = ""
+	a.ActionReturnValue = make([]byte, 0)
+
+	// Native handlers change chain state, so context free actions skip them
 	if !a.ContextFree {
 		native := a.Control.FindApplyHandler(a.Receiver, a.Act.Account, a.Act.Name)
 
 		if native != nil {
+			if a.TrxContext.ReadOnly {
+				return fmt.Errorf("native action %s::%s cannot be executed in a read-only transaction", a.Act.Account, a.Act.Name)
+			}
+
 			if err := native(a); err != nil {
 				return err
 			}
 		}
+	}
+
+	runCode := !receiverAccount.CodeHash.IsZero()
 
-		if !receiverAccount.CodeHash.IsEmpty() && !(a.Act.Account == config.SystemAccountName && a.Act.Name == name.StringToName("setcode") && a.Receiver == config.SystemAccountName) {
-			// Check contract blacklist
+	// eosio::setcode only reaches the system contract once FORWARD_SETCODE is
+	// active, it then runs the code the native handler may just have set
+	if a.Act.Account == config.SystemAccountName && a.Act.Name == name.StringToName("setcode") && a.Receiver == config.SystemAccountName {
+		forwardSetcode, err := a.IsBuiltinActivated(protocol.ForwardSetcode)
+
+		if err != nil {
+			return err
+		}
+
+		if forwardSetcode {
+			if receiverAccount, err = a.Session.FindAccountMetaDataByName(a.Receiver); err != nil {
+				return fmt.Errorf("could not find receiver account: %v", err)
+			}
+		}
+
+		runCode = forwardSetcode && !receiverAccount.CodeHash.IsZero()
+	}
+
+	if runCode {
+		// Check contract blacklist
+		if a.TrxContext.checksAccessLists() {
 			if err := a.Control.CheckContractList(receiverAccount.Name); err != nil {
 				return err
 			}
+		}
 
-			a.TrxContext.PauseBillingTimer()
-			module := wasm.NewWasmExecutionContext(context.Background(), a.Control, a.TrxContext, a, a.Authorization, a.GetMutableResourceLimitsManager(), a.Idx64, a.Idx128, a.Idx256, a.IdxDouble, a.IdxLongDouble)
+		a.TrxContext.PauseBillingTimer()
+		module := wasm.NewWasmExecutionContext(context.Background(), a.Control.WasmRuntime, a.Control, a.TrxContext, a, a.Authorization, a.GetMutableResourceLimitsManager(), a.Control.GetProtocolFeatureManager(a.Session), a.Idx64, a.Idx128, a.Idx256, a.IdxDouble, a.IdxLongDouble)
 
-			// Fetch code object
+		key := wasm.ModuleKey{CodeHash: receiverAccount.CodeHash, VmType: receiverAccount.VmType, VmVersion: receiverAccount.VmVersion}
+
+		// Run the WASM contract, the code object is only fetched to compile it
+		err = module.Exec(key, func() ([]byte, error) {
 			code, err := a.Session.FindCodeObjectByCodeHash(receiverAccount.CodeHash, receiverAccount.VmType, receiverAccount.VmVersion)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
-			// Run the WASM contract
-			if err := module.Exec(code.Code); err != nil {
-				return err
-			}
+			return code.Code, nil
+		})
+		a.Control.Metrics.ObserveWasmInstantiation(module.InstantiationTime())
 
-			a.TrxContext.ResumeBillingTimer()
+		if err != nil {
+			return err
 		}
+
+		a.TrxContext.ResumeBillingTimer()
 	}
 
 	trace, _ := a.TrxContext.GetActionTrace(a.ActionOrdinal)
@@ -248,6 +310,7 @@ func (a *applyContext) execOne() error {
 	a.TrxContext.ExecutedActionReceiptDigests = append(a.TrxContext.ExecutedActionReceiptDigests, receipt.Digest())
 
 	a.FinalizeTrace(trace, start)
+	a.Control.Metrics.ObserveActionExecution(gotime.Duration(trace.Elapsed) * gotime.Microsecond)
 
 	return nil
 }
@@ -279,11 +342,36 @@ func (a *applyContext) ExecuteInline(action transaction.Action) error {
 		}
 	}
 
-	// TODO: Add inline action size
+	// Inline actions are not part of the packed transaction, bill their size
+	packedAction, err := rlp.EncodeToBytes(action)
+
+	if err != nil {
+		return err
+	}
+
+	if err := a.TrxContext.AddNetUsage(config.ActionNetUsageOverhead + uint64(len(packedAction))); err != nil {
+		return err
+	}
+
+	if !a.Privileged && a.TrxContext.checksAccessLists() {
+		actors := name.NewNameSet(len(action.Authorization))
+
+		for _, auth := range action.Authorization {
+			actors.Insert(auth.Actor)
+		}
+
+		if err := a.Control.CheckActorList(actors); err != nil {
+			return err
+		}
+	}
+
 	if !a.Privileged {
 		auth := authority.PermissionLevel{Actor: a.Receiver, Permission: config.EosioCodeName}
 
-		if err := a.Authorization.CheckAuthorization([]*transaction.Action{&action}, ecc.NewPublicKeySet(0), []authority.PermissionLevel{auth}, false, inheritedAuthorizations); err != nil {
+		// Delayed transactions provide the delay they waited for
+		providedDelay := a.PendingBlockTime().Sub(a.TrxContext.Published)
+
+		if err := a.Authorization.CheckAuthorization([]*transaction.Action{&action}, ecc.NewPublicKeySet(0), []authority.PermissionLevel{auth}, providedDelay, false, inheritedAuthorizations); err != nil {
 			return fmt.Errorf("authorization failure with inline action")
 		}
 	}
@@ -297,9 +385,287 @@ func (a *applyContext) ExecuteInline(action transaction.Action) error {
 	return nil
 }
 
+// ExecuteContextFreeInline schedules the context free [action] to run after
+// the current action, it cannot carry authorizations so none are checked
+func (a *applyContext) ExecuteContextFreeInline(action transaction.Action) error {
+	if _, err := a.Session.FindAccountByName(action.Account); err != nil {
+		return fmt.Errorf("inline action's code account %s does not exist", action.Account)
+	}
+
+	if len(action.Authorization) > 0 {
+		return fmt.Errorf("context-free actions cannot have authorizations")
+	}
+
+	packedAction, err := rlp.EncodeToBytes(action)
+
+	if err != nil {
+		return err
+	}
+
+	if err := a.TrxContext.AddNetUsage(config.ActionNetUsageOverhead + uint64(len(packedAction))); err != nil {
+		return err
+	}
+
+	ordinal, err := a.ScheduleActionByAction(action, action.Account, true)
+
+	if err != nil {
+		return err
+	}
+
+	a.CfaInlineActions = append(a.CfaInlineActions, *ordinal)
+
+	return nil
+}
+
+// ScheduleDeferredTransaction stores [trx] to be executed once its delay has
+// passed, the RAM it takes up is billed to [payer]. An existing deferred
+// transaction with the same [senderId] is only replaced when [replaceExisting]
+// is set
+func (a *applyContext) ScheduleDeferredTransaction(senderId math.Uint128, payer name.AccountName, trx *transaction.Transaction, replaceExisting bool) error {
+	if a.TrxContext.ReadOnly {
+		return fmt.Errorf("cannot schedule a deferred transaction from within a read-only transaction")
+	}
+
+	if len(trx.ContextFreeActions) > 0 {
+		return fmt.Errorf("context free actions are not currently allowed in generated transactions")
+	}
+
+	if err := a.TrxContext.ValidateReferencedAccounts(trx); err != nil {
+		return err
+	}
+
+	noDuplicateDeferredId, err := a.IsBuiltinActivated(protocol.NoDuplicateDeferredId)
+
+	if err != nil {
+		return err
+	}
+
+	if noDuplicateDeferredId {
+		if err := a.addGenerationContext(senderId, trx); err != nil {
+			return err
+		}
+
+		trx.Expiration = 0
+		trx.RefBlockNum = 0
+		trx.RefBlockPrefix = 0
+	} else {
+		// Rounds up to the next second so the expiration check is unnecessary
+		trx.Expiration = time.NewTimePointSecTp(a.PendingBlockTime().AddUs(999999))
+		trx.RefBlockNum, trx.RefBlockPrefix = headBlockReference(a.Control.pendingBlock)
+	}
+
+	gpo, err := a.Session.FindGlobalPropertyObject(0)
+
+	if err != nil {
+		return err
+	}
+
+	// Charge ahead of time for the NET needed to retire the deferred transaction
+	if err := a.TrxContext.AddNetUsage(uint64(gpo.Configuration.BasePerTransactionNetUsage) + config.TransactionIdNetUsage); err != nil {
+		return err
+	}
+
+	ramRestrictions, err := a.IsBuiltinActivated(protocol.RamRestrictions)
+
+	if err != nil {
+		return err
+	}
+
+	if !a.Privileged {
+		if payer != a.Receiver {
+			if ramRestrictions {
+				if a.Receiver != a.Act.Account {
+					return fmt.Errorf("cannot bill RAM usage of deferred transactions to another account within notify context")
+				}
+
+				if !a.HasAuthorization(payer) {
+					return fmt.Errorf("cannot bill RAM usage of deferred transaction to another account that has not authorized the action: %s", payer)
+				}
+			} else if err := a.RequireAuthorization(payer); err != nil {
+				return err
+			}
+		}
+
+		if err := a.checkDeferredAuthorization(trx); err != nil {
+			return err
+		}
+	}
+
+	packedTrx, err := rlp.EncodeToBytes(trx)
+
+	if err != nil {
+		return err
+	}
+
+	trxId := *trx.ID()
+	existing, err := a.Session.FindGeneratedTransactionBySenderId(a.Receiver, senderId)
+
+	if err == nil {
+		if !replaceExisting {
+			return fmt.Errorf("deferred transaction with the same sender_id and payer already exists")
+		}
+
+		replaceDeferred, err := a.IsBuiltinActivated(protocol.ReplaceDeferred)
+
+		if err != nil {
+			return err
+		}
+
+		// Before REPLACE_DEFERRED the replaced transaction kept its ID and its
+		// RAM was not refunded
+		if replaceDeferred {
+			if err := a.AddRamUsage(existing.Payer, -existing.BillableSize()); err != nil {
+				return err
+			}
+		} else {
+			trxId = existing.TrxId
+		}
+
+		if err := a.Session.RemoveGeneratedTransaction(existing); err != nil {
+			return err
+		}
+	} else if err != badger.ErrKeyNotFound {
+		return err
+	}
+
+	// Generated transactions are looked up by their ID, which has to be unique
+	if _, err := a.Session.FindGeneratedTransactionByTrxId(trxId); err == nil {
+		return fmt.Errorf("deferred transaction with the same id %s already exists", trxId)
+	} else if err != badger.ErrKeyNotFound {
+		return err
+	}
+
+	published := a.PendingBlockTime()
+	delayUntil := published.AddUs(time.Seconds(int64(trx.DelaySec)))
+	generated := &transaction.GeneratedTransactionObject{
+		TrxId:      trxId,
+		Sender:     a.Receiver,
+		SenderId:   senderId,
+		Payer:      payer,
+		Published:  published,
+		DelayUntil: delayUntil,
+		Expiration: delayUntil.AddUs(time.Seconds(int64(gpo.Configuration.DeferredTrxExpirationWindow))),
+		PackedTrx:  packedTrx,
+	}
+
+	if err := a.Session.CreateGeneratedTransaction(generated); err != nil {
+		return err
+	}
+
+	if !ramRestrictions && a.Receiver != a.Act.Account && a.Receiver != payer && !a.Privileged {
+		return fmt.Errorf("cannot charge RAM to other accounts during notify")
+	}
+
+	return a.AddRamUsage(payer, generated.BillableSize())
+}
+
+// addGenerationContext ties [trx] to the transaction sending it, a context the
+// contract added itself has to match
+func (a *applyContext) addGenerationContext(senderId math.Uint128, trx *transaction.Transaction) error {
+	generationContext := transaction.DeferredTransactionGenerationContext{
+		SenderTrxId: a.TrxContext.ID,
+		SenderId:    senderId,
+		Sender:      a.Receiver,
+	}
+
+	if len(trx.TransactionExtensions) == 0 {
+		data, err := generationContext.Pack()
+
+		if err != nil {
+			return err
+		}
+
+		trx.TransactionExtensions = append(trx.TransactionExtensions, &types.Extension{Type: transaction.DeferredTransactionGenerationContextId, Data: data})
+
+		return nil
+	}
+
+	if len(trx.TransactionExtensions) != 1 || trx.TransactionExtensions[0].Type != transaction.DeferredTransactionGenerationContextId {
+		return fmt.Errorf("only the deferred_transaction_generation_context extension is currently supported for deferred transactions")
+	}
+
+	provided := transaction.DeferredTransactionGenerationContext{}
+
+	if err := rlp.DecodeBytes(trx.TransactionExtensions[0].Data, &provided); err != nil {
+		return fmt.Errorf("ill-formed deferred_transaction_generation_context: %w", err)
+	}
+
+	if provided.Sender != generationContext.Sender {
+		return fmt.Errorf("deferred transaction generation context contains mismatching sender")
+	}
+
+	if provided.SenderId != generationContext.SenderId {
+		return fmt.Errorf("deferred transaction generation context contains mismatching sender_id")
+	}
+
+	if provided.SenderTrxId != generationContext.SenderTrxId {
+		return fmt.Errorf("deferred transaction generation context contains mismatching sender_trx_id")
+	}
+
+	return nil
+}
+
+// checkDeferredAuthorization checks that [trx] is authorized by the eosio.code
+// permission of the sender. Before RESTRICT_ACTION_TO_SELF a transaction only
+// sending actions to the sender itself skipped the check
+func (a *applyContext) checkDeferredAuthorization(trx *transaction.Transaction) error {
+	auth := authority.PermissionLevel{Actor: a.Receiver, Permission: config.EosioCodeName}
+	err := a.Authorization.CheckAuthorization(trx.Actions, ecc.NewPublicKeySet(0), []authority.PermissionLevel{auth}, time.Seconds(int64(trx.DelaySec)), false, nil)
+
+	if err == nil {
+		return nil
+	}
+
+	restrictActionToSelf, activatedErr := a.IsBuiltinActivated(protocol.RestrictActionToSelf)
+
+	if activatedErr != nil {
+		return activatedErr
+	}
+
+	if restrictActionToSelf {
+		return err
+	}
+
+	for _, act := range trx.Actions {
+		if act.Account != a.Receiver {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CancelDeferredTransaction removes the deferred transaction the receiver sent
+// with [senderId] and refunds its RAM, it reports whether one was found
+func (a *applyContext) CancelDeferredTransaction(senderId math.Uint128) (bool, error) {
+	if a.TrxContext.ReadOnly {
+		return false, fmt.Errorf("cannot cancel a deferred transaction from within a read-only transaction")
+	}
+
+	generated, err := a.Session.FindGeneratedTransactionBySenderId(a.Receiver, senderId)
+
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if err := a.AddRamUsage(generated.Payer, -generated.BillableSize()); err != nil {
+		return false, err
+	}
+
+	if err := a.Session.RemoveGeneratedTransaction(generated); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
 func (a *applyContext) FinalizeTrace(trace *transaction.ActionTrace, start time.TimePoint) {
 	trace.Elapsed = uint64(time.Now() - start)
-	trace.AccountRamDeltas = make([]transaction.RamDelta, len(trace.AccountRamDeltas))
+	trace.Console = a.ConsoleOutput
+	trace.ReturnValue = a.ActionReturnValue
+	trace.AccountRamDeltas = make([]transaction.RamDelta, 0, len(a.AccountRamDeltas))
 
 	for account, delta := range a.AccountRamDeltas {
 		trace.AccountRamDeltas = append(trace.AccountRamDeltas, transaction.RamDelta{
@@ -376,6 +742,10 @@ func (a *applyContext) FindAccount(account name.AccountName) (*account.Account,
 	return a.Session.FindAccountByName(account)
 }
 
+func (a *applyContext) FindAccountMetaData(account name.AccountName) (*account.AccountMetaDataObject, error) {
+	return a.Session.FindAccountMetaDataByName(account)
+}
+
 func (a *applyContext) IsAccount(account name.AccountName) bool {
 	if account, err := a.Session.FindAccountByName(account); account != nil && err == nil {
 		return true
@@ -487,6 +857,10 @@ func (a *applyContext) FindI64(code name.AccountName, scope name.ScopeName, tabl
 }
 
 func (a *applyContext) StoreI64(code name.AccountName, scope name.ScopeName, tableName name.TableName, payer name.AccountName, primaryKey uint64, buffer []byte) (int, error) {
+	if a.TrxContext.ReadOnly {
+		return 0, errReadOnlyTableOperation
+	}
+
 	tab, err := a.Session.FindOrCreateTable(code, scope, tableName, payer)
 
 	if err != nil {
@@ -535,6 +909,10 @@ func (a *applyContext) GetI64(iterator int, buffer []byte, bufferSize int) (int,
 }
 
 func (a *applyContext) UpdateI64(iterator int, payer name.AccountName, buffer []byte, bufferSize int) error {
+	if a.TrxContext.ReadOnly {
+		return errReadOnlyTableOperation
+	}
+
 	obj := (a.KeyValueCache.get(iterator)).(*table.KeyValue)
 	tab := a.KeyValueCache.tableCache[obj.TableID]
 
@@ -577,6 +955,10 @@ func (a *applyContext) UpdateI64(iterator int, payer name.AccountName, buffer []
 }
 
 func (a *applyContext) RemoveI64(iterator int) error {
+	if a.TrxContext.ReadOnly {
+		return errReadOnlyTableOperation
+	}
+
 	obj := (a.KeyValueCache.get(iterator)).(*table.KeyValue)
 	tab, err := a.KeyValueCache.getTable(obj.TableID)
 
@@ -792,13 +1174,19 @@ func (a *applyContext) UpdateDatabaseUsage(payer name.AccountName, delta int64)
 		}
 	}
 
-	a.AddRamUsage(payer, delta)
-
-	return nil
+	return a.AddRamUsage(payer, delta)
 }
 
-func (a *applyContext) AddRamUsage(account name.AccountName, delta int64) {
+// AddRamUsage bills [delta] bytes of RAM to [account] and records it in the
+// action trace
+func (a *applyContext) AddRamUsage(account name.AccountName, delta int64) error {
+	if err := a.TrxContext.AddRamUsage(account, delta); err != nil {
+		return err
+	}
+
 	a.AccountRamDeltas[account] += delta
+
+	return nil
 }
 
 func (a *applyContext) CheckAuthorization(actions []transaction.Action, providedKeys ecc.PublicKeySet) error {
@@ -818,6 +1206,20 @@ func (a *applyContext) GetPackedTransaction() *transaction.PackedTransaction {
 	return a.TrxContext.PackedTrx
 }
 
+// GetContextFreeData returns the context free data at [index], there is none
+// once the transaction was pruned
+func (a *applyContext) GetContextFreeData(index uint32) ([]byte, bool) {
+	if int(index) >= len(a.TrxContext.ContextFreeData) {
+		return nil, false
+	}
+
+	return a.TrxContext.ContextFreeData[index], true
+}
+
+func (a *applyContext) IsContextFree() bool {
+	return a.ContextFree
+}
+
 func (a *applyContext) IsContextPrivileged() bool {
 	return a.Privileged
 }
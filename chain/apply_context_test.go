@@ -345,3 +345,22 @@ func TestEndI64(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, -1, res)
 }
+
+func TestReadOnlyTableOperations(t *testing.T) {
+	applyContext, err := setupEnvironment(t)
+	assert.NoError(t, err)
+	receiver := name.StringToName("eosio.token")
+	table := name.StringToName("stat")
+	iterator, err := applyContext.StoreI64(receiver, receiver, table, receiver, 1, []byte{1, 2})
+	assert.NoError(t, err)
+
+	// Records can still be read but not written
+	applyContext.TrxContext.ReadOnly = true
+	assert.GreaterOrEqual(t, applyContext.FindI64(receiver, receiver, table, 1), 0)
+	_, err = applyContext.StoreI64(receiver, receiver, table, receiver, 2, []byte{3})
+	assert.ErrorIs(t, err, errReadOnlyTableOperation)
+	assert.ErrorIs(t, applyContext.UpdateI64(iterator, receiver, []byte{3}, 1), errReadOnlyTableOperation)
+	assert.ErrorIs(t, applyContext.RemoveI64(iterator), errReadOnlyTableOperation)
+	_, err = applyContext.Idx64.Store(receiver, table, receiver, 1, 10)
+	assert.ErrorIs(t, err, errReadOnlyTableOperation)
+}
@@ -2,6 +2,7 @@ package chain
 
 import (
 	"github.com/MetalBlockchain/antelopevm/chain/authority"
+	"github.com/MetalBlockchain/antelopevm/chain/time"
 	"github.com/MetalBlockchain/antelopevm/crypto/ecc"
 )
 
@@ -20,15 +21,17 @@ type AuthorityChecker struct {
 	PermissionToAuthority PermissionToAuthorityFunc
 	ProvidedKeys          ecc.PublicKeySet
 	ProvidedPermissions   []authority.PermissionLevel
+	ProvidedDelay         time.Microseconds
 	UsedKeys              ecc.PublicKeySet
 	RecursionDepthLimit   uint16
 }
 
-func NewAuthorityChecker(permissionToAuthority PermissionToAuthorityFunc, keys ecc.PublicKeySet, providedPermissions []authority.PermissionLevel, recursionDepthLimit uint16) *AuthorityChecker {
+func NewAuthorityChecker(permissionToAuthority PermissionToAuthorityFunc, keys ecc.PublicKeySet, providedPermissions []authority.PermissionLevel, providedDelay time.Microseconds, recursionDepthLimit uint16) *AuthorityChecker {
 	return &AuthorityChecker{
 		PermissionToAuthority: permissionToAuthority,
 		ProvidedKeys:          keys,
 		ProvidedPermissions:   providedPermissions,
+		ProvidedDelay:         providedDelay,
 		UsedKeys:              ecc.NewPublicKeySet(keys.Size()),
 		RecursionDepthLimit:   recursionDepthLimit,
 	}
@@ -59,6 +62,10 @@ func (ac *AuthorityChecker) SatisfiedAuthority(authority *authority.Authority, c
 func (ac *AuthorityChecker) satisfiedAuthority(authority *authority.Authority, cachedPerms *PermissionCacheType, depth uint16) bool {
 	permissions := make(MetaPermission, 0)
 
+	for _, wait := range authority.Waits {
+		permissions = append(permissions, wait)
+	}
+
 	for _, key := range authority.Keys {
 		permissions = append(permissions, key)
 	}
@@ -128,6 +135,9 @@ func NewWeightTallyVisitor(checker *AuthorityChecker, cachedPermissions *Permiss
 
 func (w *WeightTallyVisitor) Visit(permission interface{}) uint32 {
 	switch v := permission.(type) {
+	case authority.WaitWeight:
+		w.VisitWaitWeight(v)
+		return w.TotalWeight
 	case authority.KeyWeight:
 		w.VisitKeyWeight(v)
 		return w.TotalWeight
@@ -139,6 +149,16 @@ func (w *WeightTallyVisitor) Visit(permission interface{}) uint32 {
 	}
 }
 
+// VisitWaitWeight counts [permission] once the transaction is delayed by at
+// least its wait time
+func (w *WeightTallyVisitor) VisitWaitWeight(permission authority.WaitWeight) uint32 {
+	if w.Checker.ProvidedDelay >= time.Seconds(int64(permission.WaitSec)) {
+		w.TotalWeight += uint32(permission.Weight)
+	}
+
+	return w.TotalWeight
+}
+
 func (w *WeightTallyVisitor) VisitKeyWeight(permission authority.KeyWeight) uint32 {
 	for _, key := range w.Checker.ProvidedKeys.Slice() {
 		if key.Compare(permission.Key) {
@@ -186,37 +206,32 @@ func (m MetaPermission) Swap(i, j int) {
 	m[i], m[j] = m[j], m[i]
 }
 
+// Less reports whether [i] has to be visited after [j], heavier permissions
+// go first and on equal weight waits go before keys and keys before accounts
 func (m MetaPermission) Less(i, j int) bool {
-	iType, jType := 0, 0
-	iWeight, jWeight := uint16(0), uint16(0)
-	switch v := m[i].(type) {
-	case authority.KeyWeight:
-		iWeight = uint16(v.Weight)
-		iType = 2
-	case authority.PermissionLevelWeight:
-		iWeight = uint16(v.Weight)
-		iType = 3
-	}
-	switch v := m[j].(type) {
-	case authority.KeyWeight:
-		jWeight = uint16(v.Weight)
-		iType = 2
-	case authority.PermissionLevelWeight:
-		jWeight = uint16(v.Weight)
-		iType = 3
-	}
+	iType, iWeight := metaPermissionOrder(m[i])
+	jType, jWeight := metaPermissionOrder(m[j])
 
 	if iWeight < jWeight {
 		return true
 	} else if iWeight > jWeight {
 		return false
-	} else {
-		if iType < jType {
-			return true
-		} else {
-			return false
-		}
 	}
+
+	return iType > jType
+}
+
+func metaPermissionOrder(permission interface{}) (int, authority.WeightType) {
+	switch v := permission.(type) {
+	case authority.WaitWeight:
+		return 1, v.Weight
+	case authority.KeyWeight:
+		return 2, v.Weight
+	case authority.PermissionLevelWeight:
+		return 3, v.Weight
+	}
+
+	return 0, 0
 }
 
 func (m MetaPermission) Sort() {
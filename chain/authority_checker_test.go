@@ -0,0 +1,41 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/MetalBlockchain/antelopevm/chain/authority"
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/time"
+	"github.com/MetalBlockchain/antelopevm/config"
+	"github.com/MetalBlockchain/antelopevm/crypto/ecc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorityCheckerWaitWeights(t *testing.T) {
+	key, err := ecc.NewRandomPrivateKey()
+	assert.NoError(t, err)
+	treasury := authority.PermissionLevel{Actor: name.StringToName("treasury"), Permission: config.ActiveName}
+
+	// The key alone does not reach the threshold, it needs a day of delay
+	auth := &authority.Authority{
+		Threshold: 2,
+		Keys:      []authority.KeyWeight{{Key: key.PublicKey(), Weight: 1}},
+		Waits:     []authority.WaitWeight{{WaitSec: 86400, Weight: 1}},
+	}
+	permissionToAuthority := func(level *authority.PermissionLevel) (*authority.Authority, error) {
+		return auth, nil
+	}
+	keys := ecc.NewPublicKeySetFromArray([]ecc.PublicKey{key.PublicKey()})
+
+	checker := NewAuthorityChecker(permissionToAuthority, keys, nil, time.Seconds(3600), config.MaxAuthDepth)
+	assert.False(t, checker.SatisfiedPermissionLevel(treasury, nil))
+
+	checker = NewAuthorityChecker(permissionToAuthority, keys, nil, time.Seconds(86400), config.MaxAuthDepth)
+	assert.True(t, checker.SatisfiedPermissionLevel(treasury, nil))
+	assert.True(t, checker.AllKeysUsed())
+
+	// Waits are visited first on equal weight, heavier permissions before them
+	permissions := MetaPermission{auth.Keys[0], authority.PermissionLevelWeight{Permission: treasury, Weight: 2}, auth.Waits[0]}
+	permissions.Sort()
+	assert.Equal(t, MetaPermission{authority.PermissionLevelWeight{Permission: treasury, Weight: 2}, auth.Waits[0], auth.Keys[0]}, permissions)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/MetalBlockchain/antelopevm/chain/types"
 	"github.com/MetalBlockchain/antelopevm/config"
 	"github.com/MetalBlockchain/antelopevm/crypto/ecc"
+	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
 	"github.com/MetalBlockchain/antelopevm/state"
 	wasmApi "github.com/MetalBlockchain/antelopevm/wasm/api"
 	"github.com/dgraph-io/badger/v3"
@@ -44,7 +45,7 @@ func (a *AuthorizationManager) GetPermission(level authority.PermissionLevel) (*
 func (a *AuthorizationManager) ModifyPermission(permission *authority.Permission, auth *authority.Authority) error {
 	return a.Session.ModifyPermission(permission, func() {
 		permission.Auth = *auth
-		permission.LastUpdated = time.Now()
+		permission.LastUpdated = a.Controller.PendingBlockTime()
 	})
 }
 
@@ -94,12 +95,30 @@ func (a *AuthorizationManager) GetPermissionAuthority(level *authority.Permissio
 	return &permission.Auth, nil
 }
 
-func (a *AuthorizationManager) CheckAuthorization(actions []*transaction.Action, keys ecc.PublicKeySet, providedPermissions []authority.PermissionLevel, allowUnusedKeys bool, satisfiedAuthorizations authority.PermissionLevelSet) error {
-	authorityChecker := NewAuthorityChecker(a.GetPermissionAuthority, keys, providedPermissions, config.MaxAuthDepth)
-	permissionsToSatisfy := authority.NewPermissionLevelSet(4)
+// CheckAuthorization checks that [actions] are authorized by [keys] and
+// [providedPermissions], wait weights count once the transaction is delayed by
+// [providedDelay]
+func (a *AuthorizationManager) CheckAuthorization(actions []*transaction.Action, keys ecc.PublicKeySet, providedPermissions []authority.PermissionLevel, providedDelay time.Microseconds, allowUnusedKeys bool, satisfiedAuthorizations authority.PermissionLevelSet) error {
+	gpo, err := a.Session.FindGlobalPropertyObject(0)
+
+	if err != nil {
+		return err
+	}
+
+	// Delays beyond the maximum satisfy any wait
+	effectiveProvidedDelay := providedDelay
+
+	if providedDelay >= time.Seconds(int64(gpo.Configuration.MaxTrxDelay)) {
+		effectiveProvidedDelay = time.MaxMicroseconds()
+	}
+
+	authorityChecker := NewAuthorityChecker(a.GetPermissionAuthority, keys, providedPermissions, effectiveProvidedDelay, config.MaxAuthDepth)
+	permissionsToSatisfy := make(map[authority.PermissionLevel]time.Microseconds)
+	satisfyOrder := make([]authority.PermissionLevel, 0)
 
 	for _, action := range actions {
 		specialCase := false
+		delay := effectiveProvidedDelay
 
 		if action.Account == config.SystemAccountName {
 			specialCase = true
@@ -112,6 +131,18 @@ func (a *AuthorizationManager) CheckAuthorization(actions []*transaction.Action,
 
 			} else if action.Name == name.StringToName("unlinkauth") {
 
+			} else if action.Name == name.StringToName("canceldelay") {
+				// Cancelling is authorized as if it was delayed as long as
+				// the transaction being cancelled
+				cancelDelay, err := a.checkCancelDelayAuthorization(action)
+
+				if err != nil {
+					return err
+				}
+
+				if cancelDelay > delay {
+					delay = cancelDelay
+				}
 			} else {
 				specialCase = false
 			}
@@ -143,15 +174,24 @@ func (a *AuthorizationManager) CheckAuthorization(actions []*transaction.Action,
 			}
 
 			if satisfiedAuthorizations == nil || !satisfiedAuthorizations.Contains(declaredAuth) {
-				permissionsToSatisfy.Insert(declaredAuth)
+				// A permission declared more than once has to be satisfied
+				// with the shortest delay
+				if existing, ok := permissionsToSatisfy[declaredAuth]; !ok {
+					permissionsToSatisfy[declaredAuth] = delay
+					satisfyOrder = append(satisfyOrder, declaredAuth)
+				} else if existing > delay {
+					permissionsToSatisfy[declaredAuth] = delay
+				}
 			}
 		}
 	}
 
 	// Now verify that all the declared authorizations are satisfied:
-	for _, permission := range permissionsToSatisfy.Slice() {
+	for _, permission := range satisfyOrder {
+		authorityChecker.ProvidedDelay = permissionsToSatisfy[permission]
+
 		if !authorityChecker.SatisfiedPermissionLevel(permission, nil) {
-			return fmt.Errorf("transaction declares authority %s, but does not have signatures for it", permission)
+			return fmt.Errorf("transaction declares authority %s, but does not have signatures for it under a provided delay of %d ms", permission, authorityChecker.ProvidedDelay.Count()/1000)
 		}
 	}
 
@@ -162,8 +202,61 @@ func (a *AuthorizationManager) CheckAuthorization(actions []*transaction.Action,
 	return nil
 }
 
-func (a *AuthorizationManager) CheckAuthorizationByPermissionLevel(account name.AccountName, permission name.PermissionName, keys ecc.PublicKeySet, providedPermissions []authority.PermissionLevel, allowUnusedKeys bool) error {
-	authorityChecker := NewAuthorityChecker(a.GetPermissionAuthority, keys, providedPermissions, config.MaxAuthDepth)
+// checkCancelDelayAuthorization checks that the single authorization of the
+// eosio::canceldelay [action] was used by the delayed transaction it cancels
+// and returns the delay of that transaction
+func (a *AuthorizationManager) checkCancelDelayAuthorization(action *transaction.Action) (time.Microseconds, error) {
+	cancel := CancelDelay{}
+
+	if err := rlp.DecodeBytes(action.Data, &cancel); err != nil {
+		return 0, errDecode
+	}
+
+	if len(action.Authorization) != 1 {
+		return 0, fmt.Errorf("canceldelay action should only have one declared authorization")
+	}
+
+	if action.Authorization[0] != cancel.CancelingAuth {
+		return 0, fmt.Errorf("canceldelay action must be signed with the \"canceling_auth\" permission")
+	}
+
+	generated, err := a.Session.FindGeneratedTransactionByTrxId(cancel.TrxId)
+
+	if err == badger.ErrKeyNotFound {
+		return 0, fmt.Errorf("cannot cancel trx_id=%s, there is no deferred transaction with that transaction id", cancel.TrxId)
+	} else if err != nil {
+		return 0, err
+	}
+
+	trx, err := generated.GetTransaction()
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, act := range trx.Actions {
+		for _, auth := range act.Authorization {
+			if auth == cancel.CancelingAuth {
+				return generated.DelayUntil.Sub(generated.Published), nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("canceling_auth in canceldelay action was not found as authorization in the original delayed transaction")
+}
+
+func (a *AuthorizationManager) CheckAuthorizationByPermissionLevel(account name.AccountName, permission name.PermissionName, keys ecc.PublicKeySet, providedPermissions []authority.PermissionLevel, providedDelay time.Microseconds, allowUnusedKeys bool) error {
+	gpo, err := a.Session.FindGlobalPropertyObject(0)
+
+	if err != nil {
+		return err
+	}
+
+	if providedDelay >= time.Seconds(int64(gpo.Configuration.MaxTrxDelay)) {
+		providedDelay = time.MaxMicroseconds()
+	}
+
+	authorityChecker := NewAuthorityChecker(a.GetPermissionAuthority, keys, providedPermissions, providedDelay, config.MaxAuthDepth)
 	authority := authority.PermissionLevel{Actor: account, Permission: permission}
 
 	if !authorityChecker.SatisfiedPermissionLevel(authority, nil) {
@@ -178,7 +271,7 @@ func (a *AuthorizationManager) CheckAuthorizationByPermissionLevel(account name.
 }
 
 func (a *AuthorizationManager) GetRequiredKeys(transaction transaction.Transaction, keys ecc.PublicKeySet) ([]ecc.PublicKey, error) {
-	checker := NewAuthorityChecker(a.GetPermissionAuthority, keys, []authority.PermissionLevel{}, 16)
+	checker := NewAuthorityChecker(a.GetPermissionAuthority, keys, []authority.PermissionLevel{}, time.Seconds(int64(transaction.DelaySec)), 16)
 
 	for _, act := range transaction.Actions {
 		for _, declaredAuth := range act.Authorization {
@@ -1,6 +1,7 @@
 package block
 
 import (
+	"encoding/binary"
 	"encoding/hex"
 )
 
@@ -10,6 +11,12 @@ func (b BlockHash) Hex() string {
 	return hex.EncodeToString(b[:])
 }
 
+// RefBlockPrefix returns the part of the block ID transactions refer to in
+// their TaPoS header, the words of the ID are stored little-endian
+func (b BlockHash) RefBlockPrefix() uint32 {
+	return binary.LittleEndian.Uint32(b[8:12])
+}
+
 type BlockStatus uint8
 
 const (
@@ -0,0 +1,35 @@
+package block
+
+import (
+	"github.com/MetalBlockchain/antelopevm/chain/entity"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
+)
+
+var _ entity.Entity = &BlockSummaryObject{}
+
+// BlockSummaryObject stores the ID of a recent block for TaPoS validation, the
+// object ID is the lower 16 bits of the block number so the summaries form a
+// ring of the last 65536 blocks
+type BlockSummaryObject struct {
+	ID      types.IdType `serialize:"true"`
+	BlockId BlockHash    `serialize:"true"`
+}
+
+// GetId implements core.Entity
+func (b *BlockSummaryObject) GetId() []byte {
+	return b.ID.ToBytes()
+}
+
+// GetIndexes implements core.Entity
+func (b *BlockSummaryObject) GetIndexes() map[string]entity.EntityIndex {
+	return map[string]entity.EntityIndex{
+		"id": {
+			Fields: []string{"ID"},
+		},
+	}
+}
+
+// GetObjectType implements core.Entity
+func (b *BlockSummaryObject) GetObjectType() uint8 {
+	return entity.BlockSummaryObjectType
+}
@@ -10,15 +10,19 @@ import (
 	"github.com/MetalBlockchain/antelopevm/chain/fc"
 	"github.com/MetalBlockchain/antelopevm/chain/global"
 	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/protocol"
 	"github.com/MetalBlockchain/antelopevm/chain/time"
 	"github.com/MetalBlockchain/antelopevm/chain/transaction"
 	"github.com/MetalBlockchain/antelopevm/chain/types"
 	"github.com/MetalBlockchain/antelopevm/config"
 	"github.com/MetalBlockchain/antelopevm/crypto"
 	"github.com/MetalBlockchain/antelopevm/crypto/ecc"
+	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
+	"github.com/MetalBlockchain/antelopevm/metrics"
 	"github.com/MetalBlockchain/antelopevm/state"
-	"github.com/MetalBlockchain/antelopevm/utils"
+	"github.com/MetalBlockchain/antelopevm/wasm"
 	"github.com/MetalBlockchain/antelopevm/wasm/api"
+	"github.com/dgraph-io/badger/v3"
 	log "github.com/inconshreveable/log15"
 )
 
@@ -31,13 +35,27 @@ type Controller struct {
 	ChainId       types.ChainIdType
 	State         *state.State
 
+	// Node-local lists, replace them through SetAccessLists as they are read
+	// while transactions execute
 	ActorWhitelist    name.NameSet
 	ActorBlacklist    name.NameSet
 	ContractWhitelist name.NameSet
 	ContractBlacklist name.NameSet
 	KeyBlackist       ecc.PublicKeySet
 	ReadOnly          bool
+	Metrics           *metrics.Metrics
 
+	// Shared by every contract execution, caches compiled contracts
+	WasmRuntime *wasm.Runtime
+
+	listsMutex sync.RWMutex
+
+	// Network upgrades every validator applies at the same block
+	Upgrades *UpgradeSchedule
+
+	// Block the transactions are currently being pushed into, guarded by
+	// transactionMutex
+	pendingBlock     *state.Block
 	transactionMutex sync.Mutex
 }
 
@@ -53,6 +71,7 @@ func NewController(chainId types.ChainIdType, state *state.State) *Controller {
 		ContractBlacklist: name.NewNameSet(0),
 		KeyBlackist:       ecc.NewPublicKeySet(0),
 		ReadOnly:          false,
+		WasmRuntime:       wasm.NewRuntime(wasm.DefaultModuleCacheSize),
 	}
 
 	// Add native functions
@@ -63,6 +82,7 @@ func NewController(chainId types.ChainIdType, state *state.State) *Controller {
 	controller.SetApplyHandler(name.AccountName(name.StringToName("eosio")), name.AccountName(name.StringToName("eosio")), name.ActionName(name.StringToName("deleteauth")), applyEosioDeleteAuth)
 	controller.SetApplyHandler(name.AccountName(name.StringToName("eosio")), name.AccountName(name.StringToName("eosio")), name.ActionName(name.StringToName("linkauth")), applyEosioLinkAuth)
 	controller.SetApplyHandler(name.AccountName(name.StringToName("eosio")), name.AccountName(name.StringToName("eosio")), name.ActionName(name.StringToName("unlinkauth")), applyEosioUnlinkAuth)
+	controller.SetApplyHandler(name.AccountName(name.StringToName("eosio")), name.AccountName(name.StringToName("eosio")), name.ActionName(name.StringToName("canceldelay")), applyEosioCancelDelay)
 
 	return controller
 }
@@ -89,6 +109,14 @@ func (c *Controller) InitGenesis(session *state.Session, genesisConfig *GenesisS
 		return err
 	}
 
+	if err := c.GetProtocolFeatureManager(session).InitializeProtocolState(); err != nil {
+		return err
+	}
+
+	if err := c.GetResourceLimitsManager(session).InitializeDatabase(gpo.Configuration); err != nil {
+		return err
+	}
+
 	systemAuthority := authority.Authority{
 		Threshold: 1,
 		Keys: []authority.KeyWeight{{
@@ -156,6 +184,10 @@ func (c *Controller) GetResourceLimitsManager(s *state.Session) *ResourceLimitsM
 	return NewResourceLimitsManager(s)
 }
 
+func (c *Controller) GetProtocolFeatureManager(s *state.Session) *ProtocolFeatureManager {
+	return NewProtocolFeatureManager(s)
+}
+
 func (c *Controller) CreateNativeAccount(session *state.Session, initialTimestamp time.TimePoint, name name.AccountName, owner authority.Authority, active authority.Authority, privileged bool) error {
 	// Don't create account if it exists
 	if existingAcc, _ := session.FindAccountByName(name); existingAcc != nil {
@@ -240,18 +272,498 @@ func (c *Controller) FindApplyHandler(receiver name.AccountName, scope name.Acco
 	return nil
 }
 
+// StartBlock applies the changes taking effect at the start of [block] before
+// any of its transactions are executed, it is called both when building and
+// when verifying a block so every node ends up with the same state
+func (c *Controller) StartBlock(session *state.Session, block *state.Block, parent *state.Block) error {
+	blockTime := block.Header.Timestamp.ToTimePoint()
+	upgrades := c.Upgrades.Activations(block.Height(), blockTime, parent.Header.Timestamp.ToTimePoint())
+	protocolFeatures := c.GetProtocolFeatureManager(session)
+	features := make([]types.DigestType, 0)
+
+	for _, upgrade := range upgrades {
+		if err := c.applyUpgrade(session, upgrade); err != nil {
+			return fmt.Errorf("failed to apply upgrade %s: %w", upgrade.Name, err)
+		}
+
+		for _, codename := range upgrade.ActivateFeatures {
+			feature := GetBuiltinProtocolFeatureByName(codename)
+
+			// Features already activated through an earlier upgrade are skipped
+			if activated, err := protocolFeatures.IsFeatureActivated(feature.FeatureDigest); err != nil {
+				return err
+			} else if !activated {
+				features = append(features, feature.FeatureDigest)
+			}
+		}
+
+		log.Debug("applied network upgrade", "name", upgrade.Name, "height", block.Height())
+	}
+
+	if err := c.updateBlockSummary(session, parent); err != nil {
+		return err
+	}
+
+	if err := c.clearExpiredInputTransactions(session, blockTime); err != nil {
+		return err
+	}
+
+	// Features preactivated in the parent block are activated here as well
+	if err := protocolFeatures.ActivateFeatures(features, uint32(block.Height()), blockTime); err != nil {
+		return err
+	}
+
+	return c.pushOnBlockTransaction(session, block, parent)
+}
+
+// pushOnBlockTransaction executes the implicit eosio::onblock action carrying
+// the header of [parent], the system contract relies on it for its bookkeeping.
+// Like Antelope a failing onblock action does not invalidate the block
+func (c *Controller) pushOnBlockTransaction(session *state.Session, pending *state.Block, parent *state.Block) error {
+	trx, err := c.getOnBlockTransaction(session, pending, parent)
+
+	if err != nil {
+		return err
+	}
+
+	gpo, err := session.FindGlobalPropertyObject(0)
+
+	if err != nil {
+		return err
+	}
+
+	packedTrx, err := transaction.NewPackedTransactionFromSignedTransaction(*transaction.NewSignedTransaction(trx, nil, nil), transaction.CompressionNone)
+
+	if err != nil {
+		return err
+	}
+
+	trxMeta := transaction.NewImplicitTransactionMetaData(packedTrx)
+	trxMeta.BilledCpuTimeUs = gpo.Configuration.MinTransactionCpuUsage
+
+	if _, err := c.PushTransaction(*trxMeta, pending, session); err != nil {
+		log.Warn("onblock is rejecting", "height", pending.Height(), "error", err)
+	}
+
+	return nil
+}
+
+func (c *Controller) getOnBlockTransaction(session *state.Session, pending *state.Block, parent *state.Block) (*transaction.Transaction, error) {
+	header, err := rlp.EncodeToBytes(parent.Header)
+
+	if err != nil {
+		return nil, err
+	}
+
+	trx := &transaction.Transaction{
+		Actions: []*transaction.Action{
+			{
+				Account:       config.SystemAccountName,
+				Name:          name.ActionName(name.StringToName("onblock")),
+				Authorization: []authority.PermissionLevel{{Actor: config.SystemAccountName, Permission: config.ActiveName}},
+				Data:          header,
+			},
+		},
+	}
+
+	noDuplicateDeferredId, err := c.GetProtocolFeatureManager(session).IsBuiltinFeatureActivated(protocol.NoDuplicateDeferredId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Without unique deferred IDs the onblock transaction has to differ
+	// between blocks, so it expires right after the pending block
+	if !noDuplicateDeferredId {
+		pendingBlockTime := pending.Header.Timestamp.ToTimePoint()
+		trx.Expiration = time.NewTimePointSecTp(pendingBlockTime.AddUs(999999))
+		trx.RefBlockNum, trx.RefBlockPrefix = headBlockReference(pending)
+	}
+
+	return trx, nil
+}
+
+// headBlockReference returns the TaPoS header referring to the parent of
+// [pending], implicit and generated transactions refer to it
+func headBlockReference(pending *state.Block) (uint16, uint32) {
+	parentId := block.BlockHash(pending.Header.Previous.FixedBytes())
+
+	return uint16(pending.Header.BlockNum() - 1), parentId.RefBlockPrefix()
+}
+
+// ScheduledTransactions returns the IDs of the deferred transactions whose
+// delay has passed by the time of [pending], in the order they are retired
+func (c *Controller) ScheduledTransactions(session *state.Session, pending *state.Block) ([]transaction.TransactionIdType, error) {
+	blockTime := pending.Header.Timestamp.ToTimePoint()
+	iterator := session.FindGeneratedTransactionsByDelay()
+	defer iterator.Close()
+	ids := make([]transaction.TransactionIdType, 0)
+
+	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+		generated, err := iterator.Item()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if generated.DelayUntil > blockTime {
+			break
+		}
+
+		ids = append(ids, generated.TrxId)
+	}
+
+	return ids, nil
+}
+
+// PushScheduledTransaction retires the deferred transaction [trxId] in
+// [pending]. A transaction that fails is handed to the eosio::onerror handler
+// of its sender, when that fails as well the transaction is only billed
+func (c *Controller) PushScheduledTransaction(trxId transaction.TransactionIdType, billedCpuTimeUs uint32, pending *state.Block, session *state.Session) (*transaction.TransactionTrace, error) {
+	c.transactionMutex.Lock()
+	defer c.transactionMutex.Unlock()
+	c.pendingBlock = pending
+	defer func() { c.pendingBlock = nil }()
+
+	undoSession := session.StartUndoSession()
+	defer undoSession.Undo()
+
+	generated, err := session.FindGeneratedTransactionByTrxId(trxId)
+
+	if err != nil {
+		return nil, fmt.Errorf("unknown transaction %s: %w", trxId, err)
+	}
+
+	if generated.DelayUntil > c.PendingBlockTime() {
+		return nil, fmt.Errorf("this transaction isn't ready")
+	}
+
+	if err := session.RemoveGeneratedTransaction(generated); err != nil {
+		return nil, err
+	}
+
+	// The payer gets the RAM of the retired transaction back whatever happens to it
+	ramDelta := transaction.RamDelta{Account: generated.Payer, Delta: -generated.BillableSize()}
+
+	if err := c.GetResourceLimitsManager(session).AddPendingRamUsage(ramDelta.Account, ramDelta.Delta); err != nil {
+		return nil, err
+	}
+
+	trace := &transaction.TransactionTrace{
+		Hash:            trxId,
+		BlockNum:        uint64(pending.Header.BlockNum()),
+		BlockTime:       c.PendingBlockTime(),
+		Scheduled:       true,
+		ActionTraces:    make([]transaction.ActionTrace, 0),
+		AccountRamDelta: ramDelta,
+	}
+
+	// Expired transactions are dropped without being executed
+	if generated.Expiration < c.PendingBlockTime() {
+		trace.Receipt = newScheduledReceipt(transaction.TransactionStatusExpired, billedCpuTimeUs, 0)
+		undoSession.Squash()
+
+		return trace, nil
+	}
+
+	trx, err := generated.GetTransaction()
+
+	if err != nil {
+		return nil, err
+	}
+
+	packedTrx, err := transaction.NewPackedTransactionFromSignedTransaction(*transaction.NewSignedTransaction(trx, nil, nil), transaction.CompressionNone)
+
+	if err != nil {
+		return nil, err
+	}
+
+	trxContext := NewTransactionContext(c, session, packedTrx, trxId, pending)
+
+	if billedCpuTimeUs > 0 {
+		trxContext.ExplicitBilledCpuTime = true
+		trxContext.BilledCpuTimeUs = int64(billedCpuTimeUs)
+	}
+
+	// The writes of a failed transaction are reverted before its failure is
+	// handled, the removal of the generated transaction is kept
+	trxUndoSession := session.StartUndoSession()
+	err = trxContext.InitForDeferredTransaction(generated.Published)
+
+	if err == nil {
+		err = trxContext.Exec()
+	}
+
+	if err == nil {
+		err = trxContext.Finalize()
+	}
+
+	if err == nil {
+		trxUndoSession.Squash()
+		trxContext.Trace.Receipt = newScheduledReceipt(transaction.TransactionStatusExecuted, uint32(trxContext.BilledCpuTimeUs), trxContext.NetUsage/8)
+		trxContext.Trace.AccountRamDelta = ramDelta
+		undoSession.Squash()
+
+		return trxContext.Trace, nil
+	}
+
+	if err := trxUndoSession.Undo(); err != nil {
+		return nil, err
+	}
+
+	log.Debug("deferred transaction failed", "id", trxId, "error", err)
+	trace.Except = err
+
+	if generated.Sender != 0 {
+		onErrorTrace, onErrorErr := c.applyOnError(session, generated, billedCpuTimeUs, pending)
+
+		if onErrorErr == nil {
+			onErrorTrace.AccountRamDelta = ramDelta
+			undoSession.Squash()
+
+			return onErrorTrace, nil
+		}
+
+		log.Debug("onerror handler failed", "id", trxId, "error", onErrorErr)
+	}
+
+	trace, err = c.hardFailScheduledTransaction(session, trace, trxContext, billedCpuTimeUs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	undoSession.Squash()
+
+	return trace, nil
+}
+
+// applyOnError lets the sender of the failed deferred transaction [generated]
+// handle the failure through its eosio::onerror action
+func (c *Controller) applyOnError(session *state.Session, generated *transaction.GeneratedTransactionObject, billedCpuTimeUs uint32, pending *state.Block) (*transaction.TransactionTrace, error) {
+	data, err := rlp.EncodeToBytes(OnError{SenderId: generated.SenderId, SentTrx: generated.PackedTrx})
+
+	if err != nil {
+		return nil, err
+	}
+
+	action := transaction.Action{
+		Account:       config.SystemAccountName,
+		Name:          name.ActionName(name.StringToName("onerror")),
+		Authorization: []authority.PermissionLevel{{Actor: generated.Sender, Permission: config.ActiveName}},
+		Data:          data,
+	}
+	trx := &transaction.Transaction{Actions: []*transaction.Action{&action}}
+	trx.Expiration = time.NewTimePointSecTp(c.PendingBlockTime().AddUs(999999))
+	trx.RefBlockNum, trx.RefBlockPrefix = headBlockReference(pending)
+	packedTrx, err := transaction.NewPackedTransactionFromSignedTransaction(*transaction.NewSignedTransaction(trx, nil, nil), transaction.CompressionNone)
+
+	if err != nil {
+		return nil, err
+	}
+
+	undoSession := session.StartUndoSession()
+	defer undoSession.Undo()
+
+	trxContext := NewTransactionContext(c, session, packedTrx, generated.TrxId, pending)
+	trxContext.Trace.Scheduled = true
+
+	if billedCpuTimeUs > 0 {
+		trxContext.ExplicitBilledCpuTime = true
+		trxContext.BilledCpuTimeUs = int64(billedCpuTimeUs)
+	}
+
+	if err := trxContext.InitForImplicitTransaction(0); err != nil {
+		return nil, err
+	}
+
+	trxContext.Published = generated.Published
+	// The handler runs on the sender, so it is not allowed to notify others
+	trxContext.ScheduleAction(action, generated.Sender, false, 0)
+
+	if err := trxContext.ExecuteAction(1, 0); err != nil {
+		return nil, err
+	}
+
+	if err := trxContext.Finalize(); err != nil {
+		return nil, err
+	}
+
+	trxContext.Trace.Receipt = newScheduledReceipt(transaction.TransactionStatusSoftFail, uint32(trxContext.BilledCpuTimeUs), trxContext.NetUsage/8)
+	undoSession.Squash()
+
+	return trxContext.Trace, nil
+}
+
+// hardFailScheduledTransaction bills the accounts of a deferred transaction
+// that failed without being handled, the CPU it used is still charged
+func (c *Controller) hardFailScheduledTransaction(session *state.Session, trace *transaction.TransactionTrace, trxContext *TransactionContext, billedCpuTimeUs uint32) (*transaction.TransactionTrace, error) {
+	gpo, err := session.FindGlobalPropertyObject(0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cpuTimeToBillUs := int64(billedCpuTimeUs)
+
+	if cpuTimeToBillUs == 0 {
+		cpuTimeToBillUs = int64(time.Now() - trxContext.start)
+
+		if minCpu := int64(gpo.Configuration.MinTransactionCpuUsage); cpuTimeToBillUs < minCpu {
+			cpuTimeToBillUs = minCpu
+		}
+
+		resourceLimits := c.GetResourceLimitsManager(session)
+
+		// Producers only bill what the accounts can still pay for
+		for _, account := range trxContext.BillToAccounts {
+			limit, err := resourceLimits.GetAccountCpuLimit(account)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if limit.Available >= 0 && cpuTimeToBillUs > limit.Available {
+				cpuTimeToBillUs = limit.Available
+			}
+		}
+	}
+
+	timeSlot := block.NewBlockTimeStampFromTimePoint(c.PendingBlockTime())
+
+	if err := c.GetResourceLimitsManager(session).AddTransactionUsage(trxContext.BillToAccounts, uint64(cpuTimeToBillUs), 0, uint32(timeSlot)); err != nil {
+		return nil, err
+	}
+
+	trace.Receipt = newScheduledReceipt(transaction.TransactionStatusHardFail, uint32(cpuTimeToBillUs), 0)
+
+	return trace, nil
+}
+
+func newScheduledReceipt(status transaction.TransactionStatus, cpuUsageUs uint32, netUsageWords uint64) transaction.TransactionReceipt {
+	return transaction.TransactionReceipt{
+		TransactionReceiptHeader: transaction.TransactionReceiptHeader{
+			Status:        status,
+			CpuUsageUs:    cpuUsageUs,
+			NetUsageWords: fc.UnsignedInt(netUsageWords),
+		},
+	}
+}
+
+// FinalizeBlock applies the changes taking effect at the end of [block] once
+// all of its transactions are executed, the account limits set during the
+// block are applied and the elastic block limits are updated with its usage
+func (c *Controller) FinalizeBlock(session *state.Session, block *state.Block) error {
+	resourceLimits := c.GetResourceLimitsManager(session)
+
+	if err := resourceLimits.ProcessAccountLimitUpdates(); err != nil {
+		return err
+	}
+
+	gpo, err := session.FindGlobalPropertyObject(0)
+
+	if err != nil {
+		return err
+	}
+
+	if err := resourceLimits.SetBlockParameters(blockLimitParameters(gpo.Configuration)); err != nil {
+		return err
+	}
+
+	return resourceLimits.ProcessBlockUsage(uint32(block.Height()))
+}
+
+// updateBlockSummary stores the ID of [parent] in the block summary ring so
+// transactions can refer to it in their TaPoS header
+func (c *Controller) updateBlockSummary(session *state.Session, parent *state.Block) error {
+	id := types.IdType(parent.Header.BlockNum() & 0xffff)
+	summary, err := session.FindBlockSummaryObject(id)
+
+	if err == badger.ErrKeyNotFound {
+		return session.CreateBlockSummaryObject(&block.BlockSummaryObject{ID: id, BlockId: parent.Hash})
+	} else if err != nil {
+		return err
+	}
+
+	return session.ModifyBlockSummaryObject(summary, func() {
+		summary.BlockId = parent.Hash
+	})
+}
+
+// clearExpiredInputTransactions removes the transactions that expired before
+// [now], they can no longer be included so there is nothing to deduplicate
+func (c *Controller) clearExpiredInputTransactions(session *state.Session, now time.TimePoint) error {
+	iterator := session.FindTransactionObjectsByExpiration()
+	expired := make([]*transaction.TransactionObject, 0)
+
+	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+		trx, err := iterator.Item()
+
+		if err != nil {
+			iterator.Close()
+			return err
+		}
+
+		if trx.Expiration.ToTimePoint() >= now {
+			break
+		}
+
+		expired = append(expired, trx)
+	}
+
+	iterator.Close()
+
+	for _, trx := range expired {
+		if err := session.RemoveTransactionObject(trx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Controller) applyUpgrade(session *state.Session, upgrade NetworkUpgrade) error {
+	if upgrade.ChainConfig == nil {
+		return nil
+	}
+
+	gpo, err := session.FindGlobalPropertyObject(0)
+
+	if err != nil {
+		return err
+	}
+
+	return session.ModifyGlobalPropertyObject(gpo, func() {
+		gpo.Configuration = *upgrade.ChainConfig
+	})
+}
+
 func (c *Controller) PushTransaction(trx transaction.TransactionMetaData, block *state.Block, session *state.Session) (*transaction.TransactionTrace, error) {
 	c.transactionMutex.Lock()
 	defer c.transactionMutex.Unlock()
+	c.pendingBlock = block
+	defer func() { c.pendingBlock = nil }()
 	//start := core.Now()
-	checkAuth := !trx.Implicit()
+	// Dry runs and read-only transactions do not need to be signed
+	checkAuth := !trx.Implicit() && !trx.IsDryRun() && !trx.IsReadOnly()
 	signedTransaction, err := trx.PackedTrx().GetSignedTransaction()
 
 	if err != nil {
 		return nil, err
 	}
 
+	// A failed transaction leaves no writes behind in the block
+	undoSession := session.StartUndoSession()
+	defer undoSession.Undo()
+
 	trxContext := NewTransactionContext(c, session, trx.PackedTrx(), *trx.Id(), block)
+	trxContext.ReadOnly = trx.IsReadOnly()
+
+	// Validators bill the CPU time recorded in the receipt of the block producer
+	if trx.BilledCpuTimeUs > 0 {
+		trxContext.ExplicitBilledCpuTime = true
+		trxContext.BilledCpuTimeUs = int64(trx.BilledCpuTimeUs)
+	}
 
 	if trx.Implicit() {
 		if err := trxContext.InitForImplicitTransaction(0); err != nil {
@@ -267,15 +779,36 @@ func (c *Controller) PushTransaction(trx transaction.TransactionMetaData, block
 	if checkAuth {
 		authorizationManager := c.GetAuthorizationManager(session)
 
-		if err := authorizationManager.CheckAuthorization(signedTransaction.Actions, trx.RecoveredKeys(), []authority.PermissionLevel{}, false, nil); err != nil {
+		if err := authorizationManager.CheckAuthorization(signedTransaction.Actions, trx.RecoveredKeys(), []authority.PermissionLevel{}, trxContext.delay, false, nil); err != nil {
 			log.Error("failed to check transaction authorization", "error", err)
 			return nil, err
 		}
+
+		if trxContext.checksAccessLists() {
+			actors := name.NewNameSet(0)
+
+			for _, action := range signedTransaction.Actions {
+				for _, auth := range action.Authorization {
+					actors.Insert(auth.Actor)
+				}
+			}
+
+			if err := c.CheckActorList(actors); err != nil {
+				return nil, err
+			}
+
+			if err := c.CheckKeyList(trx.RecoveredKeys()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	if err := trxContext.Exec(); err != nil {
 		log.Error("failed to exec transaction context", "error", err)
-		return nil, err
+		trxContext.Trace.Hash = *trx.Id()
+		trxContext.Trace.Except = err
+
+		return trxContext.Trace, err
 	}
 
 	if err := trxContext.Finalize(); err != nil {
@@ -283,20 +816,179 @@ func (c *Controller) PushTransaction(trx transaction.TransactionMetaData, block
 		return nil, err
 	}
 
+	status := transaction.TransactionStatusExecuted
+
+	if trxContext.delay != 0 {
+		status = transaction.TransactionStatusDelayed
+	}
+
 	trxContext.Trace.Hash = *trx.Id()
 	trxContext.Trace.Receipt = transaction.TransactionReceipt{
 		TransactionReceiptHeader: transaction.TransactionReceiptHeader{
-			Status:        transaction.TransactionStatusExecuted,
+			Status:        status,
 			CpuUsageUs:    uint32(trxContext.BilledCpuTimeUs),
-			NetUsageWords: fc.UnsignedInt(0),
+			NetUsageWords: fc.UnsignedInt(trxContext.NetUsage / 8),
 		},
 		Transaction: *trx.PackedTrx(),
 	}
+	undoSession.Squash()
 
 	return trxContext.Trace, nil
 }
 
+// ValidateTransaction checks whether [trx] can still be included in a block
+// produced at [now], it is used to reject transactions before they enter the
+// mempool and is repeated when the transaction is executed
+func (c *Controller) ValidateTransaction(session *state.Session, trx *transaction.PackedTransaction, now time.TimePoint) error {
+	unpacked, err := trx.GetTransaction()
+
+	if err != nil {
+		return err
+	}
+
+	if err := c.ValidateExpiration(session, unpacked, now); err != nil {
+		return err
+	}
+
+	if err := c.ValidateTapos(session, unpacked); err != nil {
+		return err
+	}
+
+	id, err := trx.ID()
+
+	if err != nil {
+		return err
+	}
+
+	return c.ValidateUniqueness(session, *id)
+}
+
+// ValidateExpiration checks that [trx] has not expired at [now] and does not
+// expire further in the future than the maximum transaction lifetime
+func (c *Controller) ValidateExpiration(session *state.Session, trx *transaction.Transaction, now time.TimePoint) error {
+	gpo, err := session.FindGlobalPropertyObject(0)
+
+	if err != nil {
+		return err
+	}
+
+	expiration := trx.Expiration.ToTimePoint()
+
+	if expiration < now {
+		return fmt.Errorf("transaction has expired, expiration is %s and pending block time is %s", expiration, now)
+	}
+
+	if expiration > now.AddUs(time.Seconds(int64(gpo.Configuration.MaxTrxLifetime))) {
+		return fmt.Errorf("transaction expiration is too far in the future relative to the reference time of %s, expiration is %s and the maximum transaction lifetime is %d seconds", now, expiration, gpo.Configuration.MaxTrxLifetime)
+	}
+
+	return nil
+}
+
+// ValidateTapos checks that the block [trx] refers to is part of this chain
+func (c *Controller) ValidateTapos(session *state.Session, trx *transaction.Transaction) error {
+	var blockId block.BlockHash
+	summary, err := session.FindBlockSummaryObject(types.IdType(trx.RefBlockNum))
+
+	if err == nil {
+		blockId = summary.BlockId
+	} else if err != badger.ErrKeyNotFound {
+		return err
+	}
+
+	if trx.RefBlockPrefix != blockId.RefBlockPrefix() {
+		return fmt.Errorf("transaction's reference block did not match, is this transaction from a different fork? ref_block_num %d, ref_block_prefix %d", trx.RefBlockNum, trx.RefBlockPrefix)
+	}
+
+	return nil
+}
+
+// ValidateUniqueness checks that no transaction with [id] was included before
+func (c *Controller) ValidateUniqueness(session *state.Session, id transaction.TransactionIdType) error {
+	if _, err := session.FindTransactionObjectByTrxId(id); err == nil {
+		return fmt.Errorf("duplicate transaction %s", id)
+	} else if err != badger.ErrKeyNotFound {
+		return err
+	}
+
+	return nil
+}
+
+// AccessLists are the node-local lists of accounts and keys the transactions
+// this node admits or produces are checked against. Blocks of other producers
+// are not checked as the lists are not part of consensus
+type AccessLists struct {
+	ActorWhitelist    name.NameSet
+	ActorBlacklist    name.NameSet
+	ContractWhitelist name.NameSet
+	ContractBlacklist name.NameSet
+	KeyBlacklist      ecc.PublicKeySet
+}
+
+// SetAccessLists replaces the account and key lists, it is safe to call while
+// transactions are executed
+func (c *Controller) SetAccessLists(lists AccessLists) {
+	c.listsMutex.Lock()
+	defer c.listsMutex.Unlock()
+
+	c.ActorWhitelist = lists.ActorWhitelist
+	c.ActorBlacklist = lists.ActorBlacklist
+	c.ContractWhitelist = lists.ContractWhitelist
+	c.ContractBlacklist = lists.ContractBlacklist
+	c.KeyBlackist = lists.KeyBlacklist
+}
+
+// GetAccessLists returns copies of the account and key lists
+func (c *Controller) GetAccessLists() AccessLists {
+	c.listsMutex.RLock()
+	defer c.listsMutex.RUnlock()
+
+	return AccessLists{
+		ActorWhitelist:    c.ActorWhitelist.Copy(),
+		ActorBlacklist:    c.ActorBlacklist.Copy(),
+		ContractWhitelist: c.ContractWhitelist.Copy(),
+		ContractBlacklist: c.ContractBlacklist.Copy(),
+		KeyBlacklist:      c.KeyBlackist.Copy(),
+	}
+}
+
+// CheckActorList rejects [actors] when one of them is missing from the actor
+// whitelist or is on the actor blacklist
+func (c *Controller) CheckActorList(actors name.NameSet) error {
+	c.listsMutex.RLock()
+	defer c.listsMutex.RUnlock()
+
+	if c.ActorWhitelist.Size() > 0 {
+		if missing := actors.Difference(c.ActorWhitelist); missing.Size() > 0 {
+			return fmt.Errorf("authorizing actor(s) in transaction are not on the actor whitelist: %s", missing)
+		}
+	} else if c.ActorBlacklist.Size() > 0 {
+		if blacklisted := actors.Intersect(c.ActorBlacklist); blacklisted.Size() > 0 {
+			return fmt.Errorf("authorizing actor(s) in transaction are on the actor blacklist: %s", blacklisted)
+		}
+	}
+
+	return nil
+}
+
+// CheckKeyList rejects [keys] when one of them is on the key blacklist
+func (c *Controller) CheckKeyList(keys ecc.PublicKeySet) error {
+	c.listsMutex.RLock()
+	defer c.listsMutex.RUnlock()
+
+	if c.KeyBlackist.Size() > 0 {
+		if blacklisted := keys.Intersect(c.KeyBlackist); blacklisted.Size() > 0 {
+			return fmt.Errorf("public key(s) in transaction are on the key blacklist: %s", blacklisted)
+		}
+	}
+
+	return nil
+}
+
 func (c *Controller) CheckContractList(code name.AccountName) error {
+	c.listsMutex.RLock()
+	defer c.listsMutex.RUnlock()
+
 	if c.ContractWhitelist.Size() > 0 {
 		if !c.ContractWhitelist.Contains(code) {
 			return fmt.Errorf("account %s is not on the contract whitelist", code)
@@ -310,8 +1002,24 @@ func (c *Controller) CheckContractList(code name.AccountName) error {
 	return nil
 }
 
+// PendingBlockTime returns the timestamp of the block that is being built or
+// verified, every node has to agree on it so the wall clock is never used
 func (c *Controller) PendingBlockTime() time.TimePoint {
-	return time.Now()
+	if c.pendingBlock == nil {
+		panic("no pending block")
+	}
+
+	return c.pendingBlock.Header.Timestamp.ToTimePoint()
+}
+
+// PendingBlockNum returns the number of the block that is being built or
+// verified
+func (c *Controller) PendingBlockNum() uint32 {
+	if c.pendingBlock == nil {
+		panic("no pending block")
+	}
+
+	return c.pendingBlock.Header.BlockNum()
 }
 
 func (c *Controller) GetChainId() types.ChainIdType {
@@ -323,18 +1031,6 @@ func (c *Controller) GetActiveProducers() ([]name.Name, error) {
 	return []name.Name{config.SystemAccountName}, nil
 }
 
-func (c *Controller) CalculateTransactionMerkle(trxs []transaction.TransactionReceipt) (*crypto.Sha256, error) {
-	digests := make([]crypto.Sha256, 0)
-
-	for _, trx := range trxs {
-		if digest, err := trx.Digest(); err != nil {
-			return nil, err
-		} else {
-			digests = append(digests, *digest)
-		}
-	}
-
-	merkle := utils.Merkle(digests)
-
-	return &merkle, nil
+func (c *Controller) CalculateTransactionMerkle(scheduled []transaction.ScheduledTransactionReceipt, trxs []transaction.TransactionReceipt) (*crypto.Sha256, error) {
+	return state.CalculateTransactionMerkle(scheduled, trxs)
 }
@@ -0,0 +1,181 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/MetalBlockchain/antelopevm/chain/account"
+	"github.com/MetalBlockchain/antelopevm/chain/authority"
+	"github.com/MetalBlockchain/antelopevm/chain/block"
+	"github.com/MetalBlockchain/antelopevm/chain/global"
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/protocol"
+	"github.com/MetalBlockchain/antelopevm/chain/time"
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
+	"github.com/MetalBlockchain/antelopevm/config"
+	"github.com/MetalBlockchain/antelopevm/crypto"
+	"github.com/MetalBlockchain/antelopevm/crypto/ecc"
+	"github.com/MetalBlockchain/antelopevm/math"
+	"github.com/MetalBlockchain/antelopevm/state"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTransaction(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	session := state.NewState(nil, db).CreateSession(true)
+	t.Cleanup(session.Discard)
+	controller := NewController(types.ChainIdType{}, nil)
+
+	assert.NoError(t, session.CreateGlobalPropertyObject(&global.GlobalPropertyObject{
+		Configuration: config.ChainConfig{MaxTrxLifetime: 3600},
+	}))
+
+	parent := &state.Block{Hash: block.BlockHash{8: 1, 9: 2, 10: 3, 11: 4}}
+	assert.NoError(t, controller.updateBlockSummary(session, parent))
+	now := time.TimePointSec(1000000).ToTimePoint()
+	trx := &transaction.Transaction{
+		TransactionHeader: transaction.TransactionHeader{
+			Expiration:     time.TimePointSec(1000060),
+			RefBlockNum:    uint16(parent.Header.BlockNum()),
+			RefBlockPrefix: 0x04030201,
+		},
+	}
+
+	assert.NoError(t, controller.ValidateExpiration(session, trx, now))
+	assert.NoError(t, controller.ValidateTapos(session, trx))
+	assert.ErrorContains(t, controller.ValidateExpiration(session, trx, now.AddUs(time.Seconds(61))), "transaction has expired")
+	assert.ErrorContains(t, controller.ValidateExpiration(session, trx, now.SubUs(time.Seconds(3541))), "too far in the future")
+
+	trx.RefBlockPrefix++
+	assert.ErrorContains(t, controller.ValidateTapos(session, trx), "reference block did not match")
+
+	// Transactions are unique until they expire
+	id := *trx.ID()
+	assert.NoError(t, session.CreateTransactionObject(&transaction.TransactionObject{TrxId: id, Expiration: trx.Expiration}))
+	assert.ErrorContains(t, controller.ValidateUniqueness(session, id), "duplicate transaction")
+	assert.NoError(t, controller.clearExpiredInputTransactions(session, trx.Expiration.ToTimePoint()))
+	assert.Error(t, controller.ValidateUniqueness(session, id))
+	assert.NoError(t, controller.clearExpiredInputTransactions(session, trx.Expiration.ToTimePoint().AddUs(1)))
+	assert.NoError(t, controller.ValidateUniqueness(session, id))
+}
+
+func TestScheduledTransactions(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	session := state.NewState(nil, db).CreateSession(true)
+	t.Cleanup(session.Discard)
+	controller := NewController(types.ChainIdType{}, nil)
+	blockTime := time.TimePointSec(1700000000).ToTimePoint()
+	pending := state.NewBlock(nil, blockTime, block.BlockHash{}, 2)
+
+	alice := name.StringToName("alice")
+	resourceLimits := NewResourceLimitsManager(session)
+	assert.NoError(t, resourceLimits.InitializeAccount(alice))
+
+	// An expired transaction is dropped, one that is not due stays scheduled
+	expired := &transaction.GeneratedTransactionObject{
+		TrxId:      *crypto.Hash256("expired"),
+		Payer:      alice,
+		DelayUntil: blockTime.SubUs(time.Seconds(20)),
+		Expiration: blockTime.SubUs(time.Seconds(10)),
+	}
+	later := &transaction.GeneratedTransactionObject{
+		TrxId:      *crypto.Hash256("later"),
+		SenderId:   math.Uint128{Low: 1},
+		Payer:      alice,
+		DelayUntil: blockTime.AddUs(time.Seconds(10)),
+		Expiration: blockTime.AddUs(time.Seconds(20)),
+	}
+	assert.NoError(t, session.CreateGeneratedTransaction(later))
+	assert.NoError(t, session.CreateGeneratedTransaction(expired))
+	assert.NoError(t, resourceLimits.AddPendingRamUsage(alice, later.BillableSize()+expired.BillableSize()))
+
+	due, err := controller.ScheduledTransactions(session, pending)
+	assert.NoError(t, err)
+	assert.Equal(t, []transaction.TransactionIdType{expired.TrxId}, due)
+
+	_, err = controller.PushScheduledTransaction(later.TrxId, 0, pending, session)
+	assert.ErrorContains(t, err, "isn't ready")
+
+	trace, err := controller.PushScheduledTransaction(expired.TrxId, 0, pending, session)
+	assert.NoError(t, err)
+	assert.True(t, trace.Scheduled)
+	assert.Equal(t, transaction.TransactionStatusExpired, trace.Receipt.Status)
+
+	_, err = session.FindGeneratedTransactionByTrxId(expired.TrxId)
+	assert.ErrorIs(t, err, badger.ErrKeyNotFound)
+
+	// The payer gets the RAM of the retired transaction back
+	assert.Equal(t, transaction.RamDelta{Account: alice, Delta: -expired.BillableSize()}, trace.AccountRamDelta)
+	usage, err := session.FindResourceUsageByOwner(alice)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(later.BillableSize()), usage.RamUsage)
+}
+
+func TestScheduleDeferredTransactionUniqueId(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	session := state.NewState(nil, db).CreateSession(true)
+	t.Cleanup(session.Discard)
+
+	alice := name.StringToName("alice")
+	active := authority.PermissionLevel{Actor: alice, Permission: config.ActiveName}
+	assert.NoError(t, session.CreateGlobalPropertyObject(&global.GlobalPropertyObject{}))
+	assert.NoError(t, session.CreateProtocolStateObject(&protocol.ProtocolStateObject{}))
+	assert.NoError(t, session.CreateAccount(&account.Account{Name: alice}))
+	assert.NoError(t, NewResourceLimitsManager(session).InitializeAccount(alice))
+
+	controller := NewController(types.ChainIdType{}, nil)
+	controller.pendingBlock = state.NewBlock(nil, time.TimePointSec(1700000000).ToTimePoint(), block.BlockHash{}, 2)
+	action := transaction.Action{Account: alice, Name: name.StringToName("transfer"), Authorization: []authority.PermissionLevel{active}}
+	trxContext := NewTransactionContext(controller, session, nil, *crypto.Hash256("sender"), controller.pendingBlock)
+	trxContext.netLimit, trxContext.eagerNetLimit = 1<<20, 1<<20
+	trxContext.Trace.ActionTraces = []transaction.ActionTrace{{ActionOrdinal: 1, Receiver: alice, Action: action}}
+	_, err = trxContext.AuthorizationManager.CreatePermission(alice, config.ActiveName, 0, authority.Authority{}, 0)
+	assert.NoError(t, err)
+	applyContext, err := NewApplyContext(trxContext, 1, 0)
+	assert.NoError(t, err)
+	applyContext.Privileged = true
+
+	// Without NO_DUPLICATE_DEFERRED_ID the same transaction sent twice in a
+	// block gets the same ID, even under another sender ID
+	newTrx := func() *transaction.Transaction {
+		return &transaction.Transaction{Actions: []*transaction.Action{&action}}
+	}
+	assert.NoError(t, applyContext.ScheduleDeferredTransaction(math.Uint128{Low: 1}, alice, newTrx(), false))
+	assert.ErrorContains(t, applyContext.ScheduleDeferredTransaction(math.Uint128{Low: 2}, alice, newTrx(), false), "already exists")
+}
+
+func TestAccessLists(t *testing.T) {
+	controller := NewController(types.ChainIdType{}, nil)
+	alice, bob := name.StringToName("alice"), name.StringToName("bob")
+	key, err := ecc.NewRandomPrivateKey()
+	assert.NoError(t, err)
+	keys := ecc.NewPublicKeySetFromArray([]ecc.PublicKey{key.PublicKey()})
+
+	// Empty lists let everything through
+	assert.NoError(t, controller.CheckActorList(name.NewNameSet(0)))
+	assert.NoError(t, controller.CheckKeyList(keys))
+
+	lists := controller.GetAccessLists()
+	lists.ActorBlacklist.Insert(bob)
+	lists.KeyBlacklist.Insert(key.PublicKey())
+	controller.SetAccessLists(lists)
+
+	actors := name.NewNameSet(0)
+	actors.InsertAll([]name.Name{alice, bob})
+	assert.ErrorContains(t, controller.CheckActorList(actors), "on the actor blacklist: [bob]")
+	assert.ErrorContains(t, controller.CheckKeyList(keys), "on the key blacklist")
+
+	// The whitelist takes precedence over the blacklist
+	lists.ActorWhitelist.Insert(bob)
+	controller.SetAccessLists(lists)
+	assert.ErrorContains(t, controller.CheckActorList(actors), "not on the actor whitelist: [alice]")
+	actors.Remove(alice)
+	assert.NoError(t, controller.CheckActorList(actors))
+}
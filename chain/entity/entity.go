@@ -15,6 +15,12 @@ const (
 	AccountMetaDataObjectType
 	AccountRamCorrectionObjectType
 	CodeObjectType
+	ProtocolStateObjectType
+	ResourceLimitsConfigObjectType
+	ResourceLimitsStateObjectType
+	BlockSummaryObjectType
+	TransactionTraceType
+	GeneratedTransactionObjectType
 )
 
 type EntityIndex struct {
@@ -8,11 +8,14 @@ import (
 	"github.com/MetalBlockchain/antelopevm/chain/authority"
 	"github.com/MetalBlockchain/antelopevm/chain/block"
 	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/protocol"
 	"github.com/MetalBlockchain/antelopevm/chain/time"
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
 	"github.com/MetalBlockchain/antelopevm/chain/types"
 	"github.com/MetalBlockchain/antelopevm/config"
 	"github.com/MetalBlockchain/antelopevm/crypto"
 	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
+	"github.com/MetalBlockchain/antelopevm/state"
 	"github.com/dgraph-io/badger/v3"
 )
 
@@ -72,8 +75,7 @@ func applyEosioNewaccount(context ApplyContext) error {
 		return fmt.Errorf("cannot create account named %s, as that name is already taken", create.Name.String())
 	}
 
-	//blockTime := context.Control.PendingBlockTime()
-	newAccountObject := account.Account{Name: create.Name, CreationDate: block.NewBlockTimeStampFromTimePoint(time.Now())}
+	newAccountObject := account.Account{Name: create.Name, CreationDate: block.NewBlockTimeStampFromTimePoint(context.PendingBlockTime())}
 	if err := context.GetSession().CreateAccount(&newAccountObject); err != nil {
 		return err
 	}
@@ -99,14 +101,16 @@ func applyEosioNewaccount(context ApplyContext) error {
 		return err
 	}
 
-	// TODO: initialize account for resources
+	if err := NewResourceLimitsManager(context.GetSession()).InitializeAccount(create.Name); err != nil {
+		return err
+	}
+
 	ramDelta := int64(config.OverheadPerAccountRamBytes)
 	ramDelta += int64(2 * authority.PermissionObjectBillableSize)
 	ramDelta += int64(ownerPermission.Auth.GetBillableSize())
 	ramDelta += int64(activePermission.Auth.GetBillableSize())
-	context.AddRamUsage(create.Name, ramDelta)
 
-	return nil
+	return context.AddRamUsage(create.Name, ramDelta)
 }
 
 func applyEosioSetCode(context ApplyContext) error {
@@ -157,6 +161,8 @@ func applyEosioSetCode(context ApplyContext) error {
 				if err := context.GetSession().RemoveCodeObject(oldCodeEntry); err != nil {
 					return err
 				}
+
+				context.EvictCode(oldCodeEntry.CodeHash, oldCodeEntry.VmType, oldCodeEntry.VmVersion)
 			} else {
 				if err := context.GetSession().ModifyCodeObject(oldCodeEntry, func() {
 					oldCodeEntry.CodeRefCount -= 1
@@ -200,13 +206,13 @@ func applyEosioSetCode(context ApplyContext) error {
 		existingAccount.CodeSequence += 1
 		existingAccount.CodeHash = codeHash
 		existingAccount.VmType = act.VmType
-		existingAccount.LastCodeUpdate = time.Now()
+		existingAccount.LastCodeUpdate = context.PendingBlockTime()
 	}); err != nil {
 		return err
 	}
 
 	if newSize != oldSize {
-		context.AddRamUsage(act.Account, newSize-oldSize)
+		return context.AddRamUsage(act.Account, newSize-oldSize)
 	}
 
 	return nil
@@ -246,7 +252,7 @@ func applyEosioSetAbi(context ApplyContext) error {
 	}
 
 	if newSize != oldSize {
-		context.AddRamUsage(act.Account, newSize-oldSize)
+		return context.AddRamUsage(act.Account, newSize-oldSize)
 	}
 
 	return nil
@@ -292,10 +298,6 @@ func applyEosioUpdateAuth(context ApplyContext) error {
 		}
 	}
 
-	if len(update.Auth.Waits) > 0 {
-		return fmt.Errorf("delayed authority is currently not supported")
-	}
-
 	if err := validateAuthorityPrecondition(context, update.Auth); err != nil {
 		return err
 	}
@@ -325,17 +327,16 @@ func applyEosioUpdateAuth(context ApplyContext) error {
 			return err
 		}
 		newSize := int64(authority.PermissionObjectBillableSize + permission.Auth.GetBillableSize())
-		context.AddRamUsage(permission.Owner, newSize-oldSize)
-	} else {
-		if p, err := context.GetAuthorizationManager().CreatePermission(update.Account, update.Permission, parentId, update.Auth, time.TimePoint(0)); err != nil {
-			return err
-		} else {
-			newSize := int64(authority.PermissionObjectBillableSize + p.Auth.GetBillableSize())
-			context.AddRamUsage(permission.Owner, newSize)
-		}
+
+		return context.AddRamUsage(permission.Owner, newSize-oldSize)
 	}
 
-	return nil
+	p, err := context.GetAuthorizationManager().CreatePermission(update.Account, update.Permission, parentId, update.Auth, time.TimePoint(0))
+	if err != nil {
+		return err
+	}
+
+	return context.AddRamUsage(update.Account, int64(authority.PermissionObjectBillableSize+p.Auth.GetBillableSize()))
 }
 
 func applyEosioDeleteAuth(context ApplyContext) error {
@@ -385,8 +386,39 @@ func applyEosioLinkAuth(context ApplyContext) error {
 		return err
 	}
 
+	fixRestriction, err := context.IsBuiltinActivated(protocol.FixLinkauthRestriction)
+
+	if err != nil {
+		return err
+	}
+
+	// Native actions cannot be linked, before FIX_LINKAUTH_RESTRICTION this was
+	// applied to every contract rather than just eosio
+	if act.Code == config.SystemAccountName || !fixRestriction {
+		switch act.Type {
+		case name.StringToName("updateauth"),
+			name.StringToName("deleteauth"),
+			name.StringToName("linkauth"),
+			name.StringToName("unlinkauth"),
+			name.StringToName("canceldelay"):
+			return fmt.Errorf("cannot link eosio::%s to a minimum permission", name.NameToString(uint64(act.Type)))
+		}
+	}
+
 	if act.Requirement != name.PermissionName(config.EosioAnyName) {
-		if _, err := context.GetSession().FindPermissionByOwner(act.Account, act.Requirement); err != nil {
+		onlyExisting, err := context.IsBuiltinActivated(protocol.OnlyLinkToExistingPermission)
+
+		if err != nil {
+			return err
+		}
+
+		if onlyExisting {
+			if _, err := context.GetSession().FindPermissionByOwner(act.Account, act.Requirement); err != nil {
+				return fmt.Errorf("failed to retrieve permission %s", name.NameToString(uint64(act.Requirement)))
+			}
+		} else if !permissionNameExists(context.GetSession(), act.Requirement) {
+			// Before ONLY_LINK_TO_EXISTING_PERMISSION any account's permission
+			// with the same name was accepted
 			return fmt.Errorf("failed to retrieve permission %s", name.NameToString(uint64(act.Requirement)))
 		}
 	}
@@ -419,6 +451,16 @@ func applyEosioLinkAuth(context ApplyContext) error {
 	return nil
 }
 
+// permissionNameExists reports whether any account has a permission called
+// [permission]
+func permissionNameExists(session *state.Session, permission name.PermissionName) bool {
+	iterator := session.FindPermissionsByName(permission)
+	defer iterator.Close()
+	iterator.Rewind()
+
+	return iterator.Valid()
+}
+
 func applyEosioUnlinkAuth(context ApplyContext) error {
 	act := UnLinkAuth{}
 	if err := rlp.DecodeBytes(context.GetAction().Data, &act); err != nil {
@@ -441,3 +483,30 @@ func applyEosioUnlinkAuth(context ApplyContext) error {
 
 	return nil
 }
+
+func applyEosioCancelDelay(context ApplyContext) error {
+	cancel := CancelDelay{}
+	if err := rlp.DecodeBytes(context.GetAction().Data, &cancel); err != nil {
+		return errDecode
+	}
+
+	// Only here to mark the single authority on this action as used
+	if err := context.RequireAuthorization(cancel.CancelingAuth.Actor); err != nil {
+		return err
+	}
+
+	// Delayed transactions have no sender, their sender ID comes from their ID
+	generated, err := context.GetSession().FindGeneratedTransactionBySenderId(name.AccountName(0), transaction.TransactionIdToSenderId(cancel.TrxId))
+
+	if err == badger.ErrKeyNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := context.AddRamUsage(generated.Payer, -generated.BillableSize()); err != nil {
+		return err
+	}
+
+	return context.GetSession().RemoveGeneratedTransaction(generated)
+}
@@ -3,6 +3,8 @@ package chain
 import (
 	"github.com/MetalBlockchain/antelopevm/chain/authority"
 	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/MetalBlockchain/antelopevm/math"
 )
 
 type NewAccount struct {
@@ -48,3 +50,14 @@ type UnLinkAuth struct {
 	Code    name.AccountName `json:"code"`
 	Type    name.ActionName  `json:"type"`
 }
+
+type CancelDelay struct {
+	CancelingAuth authority.PermissionLevel     `json:"canceling_auth"`
+	TrxId         transaction.TransactionIdType `json:"trx_id"`
+}
+
+// OnError is delivered to the sender of a deferred transaction that failed
+type OnError struct {
+	SenderId math.Uint128 `json:"sender_id"`
+	SentTrx  []byte       `json:"sent_trx"`
+}
@@ -1,5 +1,11 @@
 package chain
 
+import (
+	"fmt"
+
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+)
+
 type DeadlineException struct{}
 
 func (e DeadlineException) Code() int64 { return 3080006 }
@@ -15,3 +21,19 @@ func (e TxCpuUsageExceededException) Code() int64 { return 3080004 }
 type LeewayDeadlineException struct{}
 
 func (e LeewayDeadlineException) Code() int64 { return 3081001 }
+
+// ActionException is the error of the action that aborted its transaction,
+// the innermost failing inline action is reported
+type ActionException struct {
+	ActionOrdinal int
+	Receiver      name.AccountName
+	Account       name.AccountName
+	Name          name.ActionName
+	Err           error
+}
+
+func (e *ActionException) Error() string {
+	return fmt.Sprintf("action #%d %s::%s on %s failed: %s", e.ActionOrdinal, e.Account, e.Name, e.Receiver, e.Err)
+}
+
+func (e *ActionException) Unwrap() error { return e.Err }
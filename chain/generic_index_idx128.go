@@ -16,6 +16,10 @@ type Idx128 struct {
 }
 
 func (i *Idx128) Store(scope name.ScopeName, tableName name.TableName, payer name.AccountName, primaryKey uint64, secondaryKey math.Uint128) (int, error) {
+	if i.Context.TrxContext.ReadOnly {
+		return -1, errReadOnlyTableOperation
+	}
+
 	if payer.IsEmpty() {
 		return -1, errInvalidTablePayer
 	}
@@ -51,6 +55,10 @@ func (i *Idx128) Store(scope name.ScopeName, tableName name.TableName, payer nam
 }
 
 func (i *Idx128) Remove(iterator int) error {
+	if i.Context.TrxContext.ReadOnly {
+		return errReadOnlyTableOperation
+	}
+
 	obj, ok := i.Context.KeyValueCache.get(iterator).(*table.Index128Object)
 
 	if !ok {
@@ -85,6 +93,10 @@ func (i *Idx128) Remove(iterator int) error {
 }
 
 func (i *Idx128) Update(iterator int, payer name.AccountName, secondaryKey math.Uint128) error {
+	if i.Context.TrxContext.ReadOnly {
+		return errReadOnlyTableOperation
+	}
+
 	obj, ok := i.Context.KeyValueCache.get(iterator).(*table.Index128Object)
 
 	if !ok {
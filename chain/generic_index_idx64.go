@@ -18,6 +18,10 @@ type Idx64 struct {
 }
 
 func (i *Idx64) Store(scope name.ScopeName, tableName name.TableName, payer name.AccountName, primaryKey uint64, secondaryKey uint64) (int, error) {
+	if i.Context.TrxContext.ReadOnly {
+		return -1, errReadOnlyTableOperation
+	}
+
 	if payer.IsEmpty() {
 		return -1, errInvalidTablePayer
 	}
@@ -53,6 +57,10 @@ func (i *Idx64) Store(scope name.ScopeName, tableName name.TableName, payer name
 }
 
 func (i *Idx64) Remove(iterator int) error {
+	if i.Context.TrxContext.ReadOnly {
+		return errReadOnlyTableOperation
+	}
+
 	obj, ok := i.Context.KeyValueCache.get(iterator).(*table.Index64Object)
 
 	if !ok {
@@ -87,6 +95,10 @@ func (i *Idx64) Remove(iterator int) error {
 }
 
 func (i *Idx64) Update(iterator int, payer name.AccountName, secondaryKey uint64) error {
+	if i.Context.TrxContext.ReadOnly {
+		return errReadOnlyTableOperation
+	}
+
 	obj, ok := i.Context.KeyValueCache.get(iterator).(*table.Index64Object)
 
 	if !ok {
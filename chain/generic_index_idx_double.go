@@ -15,6 +15,10 @@ type IdxDouble struct {
 }
 
 func (i *IdxDouble) Store(scope name.ScopeName, tableName name.TableName, payer name.AccountName, primaryKey uint64, secondaryKey float64) (int, error) {
+	if i.Context.TrxContext.ReadOnly {
+		return -1, errReadOnlyTableOperation
+	}
+
 	if payer.IsEmpty() {
 		return -1, errInvalidTablePayer
 	}
@@ -50,6 +54,10 @@ func (i *IdxDouble) Store(scope name.ScopeName, tableName name.TableName, payer
 }
 
 func (i *IdxDouble) Remove(iterator int) error {
+	if i.Context.TrxContext.ReadOnly {
+		return errReadOnlyTableOperation
+	}
+
 	obj, ok := i.Context.KeyValueCache.get(iterator).(*table.IndexDoubleObject)
 
 	if !ok {
@@ -84,6 +92,10 @@ func (i *IdxDouble) Remove(iterator int) error {
 }
 
 func (i *IdxDouble) Update(iterator int, payer name.AccountName, secondaryKey float64) error {
+	if i.Context.TrxContext.ReadOnly {
+		return errReadOnlyTableOperation
+	}
+
 	obj, ok := i.Context.KeyValueCache.get(iterator).(*table.IndexDoubleObject)
 
 	if !ok {
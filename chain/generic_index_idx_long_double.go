@@ -16,6 +16,10 @@ type IdxLongDouble struct {
 }
 
 func (i *IdxLongDouble) Store(scope name.ScopeName, tableName name.TableName, payer name.AccountName, primaryKey uint64, secondaryKey math.Float128) (int, error) {
+	if i.Context.TrxContext.ReadOnly {
+		return -1, errReadOnlyTableOperation
+	}
+
 	if payer.IsEmpty() {
 		return -1, errInvalidTablePayer
 	}
@@ -51,6 +55,10 @@ func (i *IdxLongDouble) Store(scope name.ScopeName, tableName name.TableName, pa
 }
 
 func (i *IdxLongDouble) Remove(iterator int) error {
+	if i.Context.TrxContext.ReadOnly {
+		return errReadOnlyTableOperation
+	}
+
 	obj, ok := i.Context.KeyValueCache.get(iterator).(*table.IndexLongDoubleObject)
 
 	if !ok {
@@ -85,6 +93,10 @@ func (i *IdxLongDouble) Remove(iterator int) error {
 }
 
 func (i *IdxLongDouble) Update(iterator int, payer name.AccountName, secondaryKey math.Float128) error {
+	if i.Context.TrxContext.ReadOnly {
+		return errReadOnlyTableOperation
+	}
+
 	obj, ok := i.Context.KeyValueCache.get(iterator).(*table.IndexLongDoubleObject)
 
 	if !ok {
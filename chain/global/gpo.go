@@ -11,12 +11,12 @@ var _ entity.Entity = &GlobalPropertyObject{}
 
 //go:generate msgp
 type GlobalPropertyObject struct {
-	ID                       types.IdType
-	ProposedScheduleBlockNum uint64
-	ProposedSchedule         producer.ProducerSchedule
-	Configuration            config.ChainConfig
-	ChainId                  types.ChainIdType
-	WasmConfiguration        config.WasmConfig
+	ID                       types.IdType              `serialize:"true"`
+	ProposedScheduleBlockNum uint64                    `serialize:"true"`
+	ProposedSchedule         producer.ProducerSchedule `serialize:"true"`
+	Configuration            config.ChainConfig        `serialize:"true"`
+	ChainId                  types.ChainIdType         `serialize:"true"`
+	WasmConfiguration        config.WasmConfig         `serialize:"true"`
 }
 
 // GetId implements core.Entity
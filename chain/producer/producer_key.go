@@ -6,8 +6,8 @@ import (
 )
 
 type ProducerKey struct {
-	ProducerName    name.AccountName `json:"producer_name"`
-	BlockSigningKey ecc.PublicKey    `json:"block_signing_key"`
+	ProducerName    name.AccountName `serialize:"true" json:"producer_name"`
+	BlockSigningKey ecc.PublicKey    `serialize:"true" json:"block_signing_key"`
 }
 
 func (p ProducerKey) Equal(other ProducerKey) bool {
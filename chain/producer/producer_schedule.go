@@ -2,8 +2,8 @@ package producer
 
 //go:generate msgp
 type ProducerSchedule struct {
-	Version   uint32        `json:"version"`
-	Producers []ProducerKey `json:"producers"`
+	Version   uint32        `serialize:"true" json:"version"`
+	Producers []ProducerKey `serialize:"true" json:"producers"`
 }
 
 func (p ProducerSchedule) Equal(other ProducerSchedule) bool {
@@ -14,11 +14,28 @@ const (
 	Builtin ProtocolFeatureType = 0
 )
 
+// Codenames of the builtin features, the values are part of the feature digests
+// and match Leap. 14 and 15 belonged to features Leap has since retired.
 const (
-	PreactivateFeature           BuiltinProtocolFeatureType = 0
-	OnlyLinkToExistingPermission BuiltinProtocolFeatureType = 1
-	ReplaceDeferred              BuiltinProtocolFeatureType = 2
-	NoDuplicateDeferredId        BuiltinProtocolFeatureType = 3
+	PreactivateFeature            BuiltinProtocolFeatureType = 0
+	OnlyLinkToExistingPermission  BuiltinProtocolFeatureType = 1
+	ReplaceDeferred               BuiltinProtocolFeatureType = 2
+	NoDuplicateDeferredId         BuiltinProtocolFeatureType = 3
+	FixLinkauthRestriction        BuiltinProtocolFeatureType = 4
+	DisallowEmptyProducerSchedule BuiltinProtocolFeatureType = 5
+	RestrictActionToSelf          BuiltinProtocolFeatureType = 6
+	OnlyBillFirstAuthorizer       BuiltinProtocolFeatureType = 7
+	ForwardSetcode                BuiltinProtocolFeatureType = 8
+	GetSender                     BuiltinProtocolFeatureType = 9
+	RamRestrictions               BuiltinProtocolFeatureType = 10
+	WebauthnKey                   BuiltinProtocolFeatureType = 11
+	WtmsigBlockSignatures         BuiltinProtocolFeatureType = 12
+	ActionReturnValue             BuiltinProtocolFeatureType = 13
+	BlockchainParameters          BuiltinProtocolFeatureType = 16
+	GetCodeHash                   BuiltinProtocolFeatureType = 17
+	ConfigurableWasmLimits        BuiltinProtocolFeatureType = 18
+	CryptoPrimitives              BuiltinProtocolFeatureType = 19
+	GetBlockNum                   BuiltinProtocolFeatureType = 20
 )
 
 type ProtocolFeature struct {
@@ -39,7 +56,7 @@ type ProtocolFeatureSubjectiveRestrictions struct {
 type BuiltinProtocolFeatureSpec struct {
 	CodeName               string
 	DescriptionDigest      types.DigestType
-	BuiltinDependencies    []types.DigestType
+	BuiltinDependencies    []BuiltinProtocolFeatureType
 	SubjectiveRestrictions ProtocolFeatureSubjectiveRestrictions
 	Type                   BuiltinProtocolFeatureType
 }
@@ -0,0 +1,40 @@
+package protocol
+
+import (
+	"github.com/MetalBlockchain/antelopevm/chain/entity"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
+)
+
+var _ entity.Entity = &ProtocolStateObject{}
+
+type ActivatedProtocolFeature struct {
+	FeatureDigest      types.DigestType `serialize:"true" json:"feature_digest"`
+	ActivationBlockNum uint32           `serialize:"true" json:"activation_block_num"`
+}
+
+// ProtocolStateObject tracks the protocol features activated on chain, in
+// order of activation, and the features preactivated for the next block
+type ProtocolStateObject struct {
+	ID                           types.IdType               `serialize:"true"`
+	ActivatedProtocolFeatures    []ActivatedProtocolFeature `serialize:"true"`
+	PreactivatedProtocolFeatures []types.DigestType         `serialize:"true"`
+}
+
+// GetId implements core.Entity
+func (p *ProtocolStateObject) GetId() []byte {
+	return p.ID.ToBytes()
+}
+
+// GetIndexes implements core.Entity
+func (p *ProtocolStateObject) GetIndexes() map[string]entity.EntityIndex {
+	return map[string]entity.EntityIndex{
+		"id": {
+			Fields: []string{"ID"},
+		},
+	}
+}
+
+// GetObjectType implements core.Entity
+func (p *ProtocolStateObject) GetObjectType() uint8 {
+	return entity.ProtocolStateObjectType
+}
@@ -1,9 +1,16 @@
 package chain
 
 import (
+	"bytes"
+	"fmt"
+	"sort"
+
 	"github.com/MetalBlockchain/antelopevm/chain/protocol"
 	"github.com/MetalBlockchain/antelopevm/chain/time"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
 	"github.com/MetalBlockchain/antelopevm/crypto"
+	"github.com/MetalBlockchain/antelopevm/state"
+	wasmApi "github.com/MetalBlockchain/antelopevm/wasm/api"
 )
 
 var BuiltinProtocolFeatureCodenames = map[protocol.BuiltinProtocolFeatureType]protocol.BuiltinProtocolFeatureSpec{
@@ -25,4 +32,453 @@ var BuiltinProtocolFeatureCodenames = map[protocol.BuiltinProtocolFeatureType]pr
 			Enabled:                       true,
 		},
 	},
+	protocol.ReplaceDeferred: {
+		CodeName:          "REPLACE_DEFERRED",
+		DescriptionDigest: *crypto.NewSha256String("9908b3f8413c8474ab2a6be149d3f4f6d0421d37886033f27d4759c47a26d944"),
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       true,
+		},
+	},
+	protocol.NoDuplicateDeferredId: {
+		CodeName:            "NO_DUPLICATE_DEFERRED_ID",
+		DescriptionDigest:   *crypto.NewSha256String("45967387ee92da70171efd9fefd1ca8061b5efe6f124d269cd2468b47f1575a0"),
+		BuiltinDependencies: []protocol.BuiltinProtocolFeatureType{protocol.ReplaceDeferred},
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       true,
+		},
+	},
+	protocol.FixLinkauthRestriction: {
+		CodeName:          "FIX_LINKAUTH_RESTRICTION",
+		DescriptionDigest: *crypto.NewSha256String("a98241c83511dc86c857221b9372b4aa7cea3aaebc567a48604e1d3db3557050"),
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       true,
+		},
+	},
+	protocol.DisallowEmptyProducerSchedule: {
+		CodeName:          "DISALLOW_EMPTY_PRODUCER_SCHEDULE",
+		DescriptionDigest: *crypto.NewSha256String("2853617cec3eabd41881eb48882e6fc5e81a0db917d375057864b3befbe29acd"),
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       true,
+		},
+	},
+	protocol.RestrictActionToSelf: {
+		CodeName:          "RESTRICT_ACTION_TO_SELF",
+		DescriptionDigest: *crypto.NewSha256String("e71b6712188391994c78d8c722c1d42c477cf091e5601b5cf1befd05721a57f3"),
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       true,
+		},
+	},
+	protocol.OnlyBillFirstAuthorizer: {
+		CodeName:          "ONLY_BILL_FIRST_AUTHORIZER",
+		DescriptionDigest: *crypto.NewSha256String("2f1f13e291c79da5a2bbad259ed7c1f2d34f697ea460b14b565ac33b063b73e2"),
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       true,
+		},
+	},
+	protocol.ForwardSetcode: {
+		CodeName:          "FORWARD_SETCODE",
+		DescriptionDigest: *crypto.NewSha256String("898082c59f921d0042e581f00a59d5ceb8be6f1d9c7a45b6f07c0e26eaee0222"),
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       true,
+		},
+	},
+	protocol.GetSender: {
+		CodeName:          "GET_SENDER",
+		DescriptionDigest: *crypto.NewSha256String("1eab748b95a2e6f4d7cb42065bdee5566af8efddf01a55a0a8d831b823f8828a"),
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       true,
+		},
+	},
+	protocol.RamRestrictions: {
+		CodeName:          "RAM_RESTRICTIONS",
+		DescriptionDigest: *crypto.NewSha256String("1812fdb5096fd854a4958eb9d53b43219d114de0e858ce00255bd46569ad2c68"),
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       true,
+		},
+	},
+	protocol.WebauthnKey: {
+		CodeName:          "WEBAUTHN_KEY",
+		DescriptionDigest: *crypto.NewSha256String("927fdf78c51e77a899f2db938249fb1f8bb38f4e43d9c1f75b190492080cbc34"),
+		// Webauthn keys are not supported by this chain's key types, so the feature
+		// cannot be activated
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       false,
+		},
+	},
+	protocol.WtmsigBlockSignatures: {
+		CodeName:          "WTMSIG_BLOCK_SIGNATURES",
+		DescriptionDigest: *crypto.NewSha256String("ab76031cad7a457f4fd5f5fca97a3f03b8a635278e0416f77dcc91eb99a48e10"),
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       true,
+		},
+	},
+	protocol.ActionReturnValue: {
+		CodeName:          "ACTION_RETURN_VALUE",
+		DescriptionDigest: *crypto.NewSha256String("69b064c5178e2738e144ed6caa9349a3995370d78db29e494b3126ebd9111966"),
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       true,
+		},
+	},
+	protocol.BlockchainParameters: {
+		CodeName:          "BLOCKCHAIN_PARAMETERS",
+		DescriptionDigest: *crypto.NewSha256String("70787548dcea1a2c52c913a37f74ce99e6caae79110d7ca7b859936a0075b314"),
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       true,
+		},
+	},
+	protocol.GetCodeHash: {
+		CodeName:          "GET_CODE_HASH",
+		DescriptionDigest: *crypto.NewSha256String("d2596697fed14a0840013647b99045022ae6a885089f35a7e78da7a43ad76ed4"),
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       true,
+		},
+	},
+	protocol.ConfigurableWasmLimits: {
+		CodeName:          "CONFIGURABLE_WASM_LIMITS2",
+		DescriptionDigest: *crypto.NewSha256String("8139e99247b87f18ef7eae99f07f00ea3adf39ed53f4d2da3f44e6aa0bfd7c62"),
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       true,
+		},
+	},
+	protocol.CryptoPrimitives: {
+		CodeName:          "CRYPTO_PRIMITIVES",
+		DescriptionDigest: *crypto.NewSha256String("68d6405cb8df3de95bd834ebb408196578500a9f818ff62ccc68f60b932f7d82"),
+		// The alt_bn128, mod_exp, blake2_f, sha3 and k1_recover intrinsics are not
+		// implemented, so the feature cannot be activated
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       false,
+		},
+	},
+	protocol.GetBlockNum: {
+		CodeName:          "GET_BLOCK_NUM",
+		DescriptionDigest: *crypto.NewSha256String("e5d7992006e628a38c5e6c28dd55ff5e57ea682079bf41fef9b3cced0f46b491"),
+		SubjectiveRestrictions: protocol.ProtocolFeatureSubjectiveRestrictions{
+			EarliestAllowedActivationTime: time.MinTimePoint(),
+			PreactivationRequired:         true,
+			Enabled:                       true,
+		},
+	},
+}
+
+// RecognizedProtocolFeature is a builtin protocol feature together with its
+// digest, the digest is what contracts use to refer to the feature
+type RecognizedProtocolFeature struct {
+	protocol.BuiltinProtocolFeature
+	FeatureDigest types.DigestType
+}
+
+var (
+	recognizedFeatures           = make(map[types.DigestType]*RecognizedProtocolFeature)
+	recognizedFeaturesByCodename = make(map[protocol.BuiltinProtocolFeatureType]*RecognizedProtocolFeature)
+)
+
+func init() {
+	for codename := range BuiltinProtocolFeatureCodenames {
+		if _, err := recognizeBuiltinFeature(codename); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// recognizeBuiltinFeature calculates the digest of [codename], its
+// dependencies are recognized first as their digests are part of it
+func recognizeBuiltinFeature(codename protocol.BuiltinProtocolFeatureType) (*RecognizedProtocolFeature, error) {
+	if feature, ok := recognizedFeaturesByCodename[codename]; ok {
+		return feature, nil
+	}
+
+	spec, ok := BuiltinProtocolFeatureCodenames[codename]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown builtin protocol feature %d", codename)
+	}
+
+	dependencies := make([]types.DigestType, 0, len(spec.BuiltinDependencies))
+
+	for _, dependency := range spec.BuiltinDependencies {
+		recognized, err := recognizeBuiltinFeature(dependency)
+
+		if err != nil {
+			return nil, fmt.Errorf("dependency of %s: %w", spec.CodeName, err)
+		}
+
+		dependencies = append(dependencies, recognized.FeatureDigest)
+	}
+
+	// Dependencies are a sorted set in Leap, the order is part of the digest
+	sort.Slice(dependencies, func(i, j int) bool {
+		return bytes.Compare(dependencies[i].Bytes(), dependencies[j].Bytes()) < 0
+	})
+
+	feature := &RecognizedProtocolFeature{
+		BuiltinProtocolFeature: protocol.BuiltinProtocolFeature{
+			ProtocolFeatureType:    "builtin",
+			DescriptionDigest:      spec.DescriptionDigest,
+			Dependencies:           dependencies,
+			SubjectiveRestrictions: spec.SubjectiveRestrictions,
+			Type:                   protocol.Builtin,
+			CodeName:               codename,
+		},
+	}
+	feature.FeatureDigest = *crypto.NewSha256Byte(feature.Digest())
+	recognizedFeatures[feature.FeatureDigest] = feature
+	recognizedFeaturesByCodename[codename] = feature
+
+	return feature, nil
+}
+
+// GetRecognizedProtocolFeature returns the feature with [digest], or nil when
+// this node does not know about it
+func GetRecognizedProtocolFeature(digest types.DigestType) *RecognizedProtocolFeature {
+	return recognizedFeatures[digest]
+}
+
+// GetBuiltinProtocolFeature returns the feature with [codename]
+func GetBuiltinProtocolFeature(codename protocol.BuiltinProtocolFeatureType) *RecognizedProtocolFeature {
+	return recognizedFeaturesByCodename[codename]
+}
+
+// GetBuiltinProtocolFeatureByName returns the feature with the codename
+// [name], such as PREACTIVATE_FEATURE
+func GetBuiltinProtocolFeatureByName(name string) *RecognizedProtocolFeature {
+	for codename, spec := range BuiltinProtocolFeatureCodenames {
+		if spec.CodeName == name {
+			return recognizedFeaturesByCodename[codename]
+		}
+	}
+
+	return nil
+}
+
+var _ wasmApi.ProtocolFeatureManager = &ProtocolFeatureManager{}
+
+// ProtocolFeatureManager activates protocol features and answers whether they
+// are active, the activated and preactivated features are kept in state
+type ProtocolFeatureManager struct {
+	session *state.Session
+}
+
+func NewProtocolFeatureManager(session *state.Session) *ProtocolFeatureManager {
+	return &ProtocolFeatureManager{
+		session: session,
+	}
+}
+
+func (m *ProtocolFeatureManager) InitializeProtocolState() error {
+	return m.session.CreateProtocolStateObject(&protocol.ProtocolStateObject{
+		ActivatedProtocolFeatures:    make([]protocol.ActivatedProtocolFeature, 0),
+		PreactivatedProtocolFeatures: make([]types.DigestType, 0),
+	})
+}
+
+// GetActivatedFeatures returns the activated features in activation order
+func (m *ProtocolFeatureManager) GetActivatedFeatures() ([]protocol.ActivatedProtocolFeature, error) {
+	pso, err := m.session.FindProtocolStateObject(0)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not find protocol state: %w", err)
+	}
+
+	return pso.ActivatedProtocolFeatures, nil
+}
+
+func (m *ProtocolFeatureManager) IsFeatureActivated(digest types.DigestType) (bool, error) {
+	activated, err := m.GetActivatedFeatures()
+
+	if err != nil {
+		return false, err
+	}
+
+	return isActivated(activated, digest), nil
+}
+
+// IsBuiltinFeatureActivated reports whether the builtin feature [codename] is
+// active, behaviour introduced by a feature is gated on this
+func (m *ProtocolFeatureManager) IsBuiltinFeatureActivated(codename protocol.BuiltinProtocolFeatureType) (bool, error) {
+	feature := GetBuiltinProtocolFeature(codename)
+
+	if feature == nil {
+		return false, fmt.Errorf("unknown builtin protocol feature %d", codename)
+	}
+
+	return m.IsFeatureActivated(feature.FeatureDigest)
+}
+
+// PreactivateFeature marks [digest] to be activated at the start of the next
+// block, it is called by privileged contracts through preactivate_feature
+func (m *ProtocolFeatureManager) PreactivateFeature(digest types.DigestType, pendingBlockTime time.TimePoint) error {
+	pso, err := m.session.FindProtocolStateObject(0)
+
+	if err != nil {
+		return fmt.Errorf("could not find protocol state: %w", err)
+	}
+
+	if !isActivated(pso.ActivatedProtocolFeatures, GetBuiltinProtocolFeature(protocol.PreactivateFeature).FeatureDigest) {
+		return fmt.Errorf("PREACTIVATE_FEATURE has not been activated")
+	}
+
+	feature, err := checkFeatureCanActivate(digest, pendingBlockTime)
+
+	if err != nil {
+		return err
+	}
+
+	if isActivated(pso.ActivatedProtocolFeatures, digest) {
+		return fmt.Errorf("protocol feature %s is already activated", digest)
+	}
+
+	if containsDigest(pso.PreactivatedProtocolFeatures, digest) {
+		return fmt.Errorf("protocol feature %s is already pre-activated", digest)
+	}
+
+	// Dependencies may be preactivated in the same block as they are activated
+	// in order at the start of the next block
+	for _, dependency := range feature.Dependencies {
+		if !isActivated(pso.ActivatedProtocolFeatures, dependency) && !containsDigest(pso.PreactivatedProtocolFeatures, dependency) {
+			return fmt.Errorf("not all dependencies of protocol feature %s have been activated or pre-activated", digest)
+		}
+	}
+
+	return m.session.ModifyProtocolStateObject(pso, func() {
+		pso.PreactivatedProtocolFeatures = append(pso.PreactivatedProtocolFeatures, digest)
+	})
+}
+
+// ActivateFeatures activates [digests] followed by all preactivated features
+// in the block at [blockNum] and clears the preactivation list. Only features
+// that do not require preactivation may be passed in [digests].
+func (m *ProtocolFeatureManager) ActivateFeatures(digests []types.DigestType, blockNum uint32, blockTime time.TimePoint) error {
+	pso, err := m.session.FindProtocolStateObject(0)
+
+	if err != nil {
+		return fmt.Errorf("could not find protocol state: %w", err)
+	}
+
+	activated := pso.ActivatedProtocolFeatures
+
+	for _, digest := range digests {
+		feature, err := checkFeatureCanActivate(digest, blockTime)
+
+		if err != nil {
+			return err
+		}
+
+		if feature.SubjectiveRestrictions.PreactivationRequired {
+			return fmt.Errorf("protocol feature %s requires pre-activation", digest)
+		}
+
+		if activated, err = activateFeature(activated, feature, blockNum); err != nil {
+			return err
+		}
+	}
+
+	for _, digest := range pso.PreactivatedProtocolFeatures {
+		feature, err := checkFeatureCanActivate(digest, blockTime)
+
+		if err != nil {
+			return err
+		}
+
+		if activated, err = activateFeature(activated, feature, blockNum); err != nil {
+			return err
+		}
+	}
+
+	if len(activated) == len(pso.ActivatedProtocolFeatures) && len(pso.PreactivatedProtocolFeatures) == 0 {
+		return nil
+	}
+
+	return m.session.ModifyProtocolStateObject(pso, func() {
+		pso.ActivatedProtocolFeatures = activated
+		pso.PreactivatedProtocolFeatures = make([]types.DigestType, 0)
+	})
+}
+
+// checkFeatureCanActivate returns the feature with [digest] if this node
+// recognizes it and allows it to be activated at [at]
+func checkFeatureCanActivate(digest types.DigestType, at time.TimePoint) (*RecognizedProtocolFeature, error) {
+	feature := GetRecognizedProtocolFeature(digest)
+
+	if feature == nil {
+		return nil, fmt.Errorf("unrecognized protocol feature %s", digest)
+	}
+
+	if !feature.SubjectiveRestrictions.Enabled {
+		return nil, fmt.Errorf("protocol feature %s is disabled", digest)
+	}
+
+	if at < feature.SubjectiveRestrictions.EarliestAllowedActivationTime {
+		return nil, fmt.Errorf("protocol feature %s cannot be activated before %s", digest, feature.SubjectiveRestrictions.EarliestAllowedActivationTime)
+	}
+
+	return feature, nil
+}
+
+func activateFeature(activated []protocol.ActivatedProtocolFeature, feature *RecognizedProtocolFeature, blockNum uint32) ([]protocol.ActivatedProtocolFeature, error) {
+	if isActivated(activated, feature.FeatureDigest) {
+		return nil, fmt.Errorf("protocol feature %s is already activated", feature.FeatureDigest)
+	}
+
+	for _, dependency := range feature.Dependencies {
+		if !isActivated(activated, dependency) {
+			return nil, fmt.Errorf("dependency %s of protocol feature %s is not activated", dependency, feature.FeatureDigest)
+		}
+	}
+
+	return append(activated, protocol.ActivatedProtocolFeature{
+		FeatureDigest:      feature.FeatureDigest,
+		ActivationBlockNum: blockNum,
+	}), nil
+}
+
+func isActivated(activated []protocol.ActivatedProtocolFeature, digest types.DigestType) bool {
+	for _, feature := range activated {
+		if feature.FeatureDigest == digest {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsDigest(digests []types.DigestType, digest types.DigestType) bool {
+	for _, d := range digests {
+		if d == digest {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,98 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/MetalBlockchain/antelopevm/chain/protocol"
+	"github.com/MetalBlockchain/antelopevm/chain/time"
+	"github.com/MetalBlockchain/antelopevm/crypto"
+	"github.com/MetalBlockchain/antelopevm/state"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuiltinProtocolFeatureDigests(t *testing.T) {
+	// Digests of the features as activated on Leap networks
+	digests := map[protocol.BuiltinProtocolFeatureType]string{
+		protocol.PreactivateFeature:            "0ec7e080177b2c02b278d5088611686b49d739925a92d9bfcacd7fc6b74053bd",
+		protocol.OnlyLinkToExistingPermission:  "1a99a59d87e06e09ec5b028a9cbb7749b4a5ad8819004365d02dc4379a8b7241",
+		protocol.ReplaceDeferred:               "ef43112c6543b88db2283a2e077278c315ae2c84719a8b25f25cc88565fbea99",
+		protocol.NoDuplicateDeferredId:         "4a90c00d55454dc5b059055ca213579c6ea856967712a56017487886a4d4cc0f",
+		protocol.FixLinkauthRestriction:        "e0fb64b1085cc5538970158d05a009c24e276fb94e1a0bf6a528b48fbc4ff526",
+		protocol.DisallowEmptyProducerSchedule: "68dcaa34c0517d19666e6b33add67351d8c5f69e999ca1e37931bc410a297428",
+		protocol.RestrictActionToSelf:          "ad9e3d8f650687709fd68f4b90b41f7d825a365b02c23a636cef88ac2ac00c43",
+		protocol.OnlyBillFirstAuthorizer:       "8ba52fe7a3956c5cd3a656a3174b931d3bb2abb45578befc59f283ecd816a405",
+		protocol.ForwardSetcode:                "2652f5f96006294109b3dd0bbde63693f55324af452b799ee137a81a905eed25",
+		protocol.GetSender:                     "f0af56d2c5a48d60a4a5b5c903edfb7db3a736a94ed589d0b797df33ff9d3e1d",
+		protocol.RamRestrictions:               "4e7bf348da00a945489b2a681749eb56f5de00b900014e137ddae39f48f69d67",
+		protocol.WebauthnKey:                   "4fca8bd82bbd181e714e283f83e1b45d95ca5af40fb89ad3977b653c448f78c2",
+		protocol.WtmsigBlockSignatures:         "299dcb6af692324b899b39f16d5a530a33062804e41f09dc97e9f156b4476707",
+		protocol.ActionReturnValue:             "c3a6138c5061cf291310887c0b5c71fcaffeab90d5deb50d3b9e687cead45071",
+		protocol.BlockchainParameters:          "5443fcf88330c586bc0e5f3dee10e7f63c76c00249c87fe4fbf7f38c082006b4",
+		protocol.GetCodeHash:                   "bcd2a26394b36614fd4894241d3c451ab0f6fd110958c3423073621a70826e99",
+		protocol.ConfigurableWasmLimits:        "d528b9f6e9693f45ed277af93474fd473ce7d831dae2180cca35d907bd10cb40",
+		protocol.CryptoPrimitives:              "6bcb40a24e49c26d0a60513b6aeb8551d264e4717f306b81a37a5afb3b47cedc",
+		protocol.GetBlockNum:                   "35c2186cc36f7bb4aeaf4487b36e57039ccf45a9136aa856a5d569ecca55ef2b",
+	}
+	assert.Len(t, BuiltinProtocolFeatureCodenames, len(digests))
+
+	for codename, digest := range digests {
+		feature := GetBuiltinProtocolFeature(codename)
+		assert.Equal(t, digest, feature.FeatureDigest.String(), feature.CodeName)
+		assert.Equal(t, feature, GetRecognizedProtocolFeature(feature.FeatureDigest))
+	}
+
+	assert.Equal(t, GetBuiltinProtocolFeature(protocol.PreactivateFeature), GetBuiltinProtocolFeatureByName("PREACTIVATE_FEATURE"))
+	assert.Nil(t, GetBuiltinProtocolFeatureByName("UNKNOWN"))
+}
+
+func TestPreactivateFeature(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	assert.NoError(t, err)
+	defer db.Close()
+	session := state.NewState(nil, db).CreateSession(true)
+	defer session.Discard()
+	manager := NewProtocolFeatureManager(session)
+	assert.NoError(t, manager.InitializeProtocolState())
+
+	preactivate := GetBuiltinProtocolFeature(protocol.PreactivateFeature).FeatureDigest
+	onlyLink := GetBuiltinProtocolFeature(protocol.OnlyLinkToExistingPermission).FeatureDigest
+	now := time.Now()
+
+	// Nothing can be preactivated before PREACTIVATE_FEATURE is active
+	assert.Error(t, manager.PreactivateFeature(onlyLink, now))
+
+	// Features that require preactivation cannot be activated directly
+	assert.Error(t, manager.ActivateFeatures([]crypto.Sha256{onlyLink}, 2, now))
+	assert.NoError(t, manager.ActivateFeatures([]crypto.Sha256{preactivate}, 2, now))
+	activated, err := manager.IsBuiltinFeatureActivated(protocol.PreactivateFeature)
+	assert.NoError(t, err)
+	assert.True(t, activated)
+
+	assert.Error(t, manager.PreactivateFeature(crypto.Sha256{}, now))
+	assert.Error(t, manager.PreactivateFeature(preactivate, now))
+	assert.NoError(t, manager.PreactivateFeature(onlyLink, now))
+	assert.Error(t, manager.PreactivateFeature(onlyLink, now))
+
+	// Features whose behaviour is not implemented cannot be activated
+	for _, codename := range []protocol.BuiltinProtocolFeatureType{protocol.WebauthnKey, protocol.CryptoPrimitives} {
+		assert.ErrorContains(t, manager.PreactivateFeature(GetBuiltinProtocolFeature(codename).FeatureDigest, now), "disabled")
+	}
+
+	activated, err = manager.IsFeatureActivated(onlyLink)
+	assert.NoError(t, err)
+	assert.False(t, activated)
+
+	// Preactivated features are activated at the start of the next block
+	assert.NoError(t, manager.ActivateFeatures(nil, 3, now))
+	features, err := manager.GetActivatedFeatures()
+	assert.NoError(t, err)
+	assert.Equal(t, []protocol.ActivatedProtocolFeature{
+		{FeatureDigest: preactivate, ActivationBlockNum: 2},
+		{FeatureDigest: onlyLink, ActivationBlockNum: 3},
+	}, features)
+
+	pso, err := session.FindProtocolStateObject(0)
+	assert.NoError(t, err)
+	assert.Empty(t, pso.PreactivatedProtocolFeatures)
+}
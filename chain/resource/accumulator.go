@@ -2,6 +2,7 @@ package resource
 
 import (
 	"fmt"
+	"math/bits"
 
 	"github.com/MetalBlockchain/antelopevm/config"
 	"github.com/MetalBlockchain/antelopevm/math"
@@ -9,17 +10,17 @@ import (
 )
 
 type Ratio struct {
-	Numerator   uint64 `json:"numerator"`
-	Denominator uint64 `json:"denominator"`
+	Numerator   uint64 `serialize:"true" json:"numerator"`
+	Denominator uint64 `serialize:"true" json:"denominator"`
 }
 
 type ElasticLimitParameters struct {
-	Target        uint64 `json:"target"`
-	Max           uint64 `json:"max"`
-	Periods       uint32 `json:"periods"`
-	MaxMultiplier uint32 `json:"max_multiplier"`
-	ContractRate  Ratio  `json:"contract_rate"`
-	ExpandRate    Ratio  `json:"expand_rate"`
+	Target        uint64 `serialize:"true" json:"target"`
+	Max           uint64 `serialize:"true" json:"max"`
+	Periods       uint32 `serialize:"true" json:"periods"`
+	MaxMultiplier uint32 `serialize:"true" json:"max_multiplier"`
+	ContractRate  Ratio  `serialize:"true" json:"contract_rate"`
+	ExpandRate    Ratio  `serialize:"true" json:"expand_rate"`
 }
 
 func (e ElasticLimitParameters) Validate() error {
@@ -57,32 +58,27 @@ func IntegerDivideCeil(num uint64, den uint64) uint64 {
 	}()
 }
 
-type exponentialMovingAverageAccumulator struct {
-	LastOrdinal uint32 `json:"last_ordinal"`
-	ValueEx     uint64 `json:"value_ex"`
-	Consumed    uint64 `json:"consumed"`
-
-	precision   uint64
-	maxRawValue uint64
+// UsageAccumulator is an exponential moving average of the usage over a window
+// of [windowSize] ordinals, ValueEx is the average scaled by the rate limiting
+// precision
+type UsageAccumulator struct {
+	LastOrdinal uint32 `serialize:"true" json:"last_ordinal"`
+	ValueEx     uint64 `serialize:"true" json:"value_ex"`
+	Consumed    uint64 `serialize:"true" json:"consumed"`
 }
 
-func NewExponentialMovingAverageAccumulator() exponentialMovingAverageAccumulator {
-	return exponentialMovingAverageAccumulator{
-		LastOrdinal: 0,
-		ValueEx:     0,
-		Consumed:    0,
-
-		precision:   config.RateLimitingPrecision,
-		maxRawValue: math.MaxUint64 / config.RateLimitingPrecision,
-	}
+func (ema *UsageAccumulator) Average() uint64 {
+	return IntegerDivideCeil(ema.ValueEx, config.RateLimitingPrecision)
 }
 
-func (ema *exponentialMovingAverageAccumulator) Average() uint64 {
-	return IntegerDivideCeil(ema.ValueEx, ema.precision)
+// ValueInWindow returns the usage accumulated over a window of [windowSize]
+// ordinals
+func (ema *UsageAccumulator) ValueInWindow(windowSize uint32) (uint64, error) {
+	return MulDivCeil(ema.ValueEx, uint64(windowSize), config.RateLimitingPrecision)
 }
 
-func (ema *exponentialMovingAverageAccumulator) Add(units uint64, ordinal uint32, windowSize uint32) error {
-	if units > ema.maxRawValue {
+func (ema *UsageAccumulator) Add(units uint64, ordinal uint32, windowSize uint32) error {
+	if units > math.MaxUint64/config.RateLimitingPrecision {
 		return fmt.Errorf("usage exceeds maximum value representable after extending for precision")
 	} else if math.MaxUint64-ema.Consumed < units {
 		return fmt.Errorf("overflow in tracked usage when adding usage!")
@@ -121,6 +117,41 @@ func (ema *exponentialMovingAverageAccumulator) Add(units uint64, ordinal uint32
 	return nil
 }
 
+// MulDiv returns a * b / c using a 128 bit intermediate product, it fails when
+// the result does not fit in 64 bits
+func MulDiv(a uint64, b uint64, c uint64) (uint64, error) {
+	hi, lo := bits.Mul64(a, b)
+
+	if hi >= c {
+		return 0, fmt.Errorf("usage exceeds maximum value representable after extending for precision")
+	}
+
+	quo, _ := bits.Div64(hi, lo, c)
+
+	return quo, nil
+}
+
+// MulDivCeil is MulDiv rounding up
+func MulDivCeil(a uint64, b uint64, c uint64) (uint64, error) {
+	hi, lo := bits.Mul64(a, b)
+
+	if hi >= c {
+		return 0, fmt.Errorf("usage exceeds maximum value representable after extending for precision")
+	}
+
+	quo, rem := bits.Div64(hi, lo, c)
+
+	if rem > 0 {
+		if quo == math.MaxUint64 {
+			return 0, fmt.Errorf("usage exceeds maximum value representable after extending for precision")
+		}
+
+		quo++
+	}
+
+	return quo, nil
+}
+
 func makeRatio(numerator uint64, denominator uint64) Ratio {
 	return Ratio{numerator, denominator}
 }
@@ -142,7 +173,3 @@ func DowngradeCast(val math.Uint128) int64 {
 
 	return int64(val.Low)
 }
-
-type UsageAccumulator struct {
-	exponentialMovingAverageAccumulator
-}
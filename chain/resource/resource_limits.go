@@ -9,12 +9,12 @@ import (
 var _ entity.Entity = &ResourceLimits{}
 
 type ResourceLimits struct {
-	ID        types.IdType
-	Owner     name.AccountName
-	Pending   bool
-	NetWeight int64
-	CpuWeight int64
-	RamBytes  int64
+	ID        types.IdType     `serialize:"true"`
+	Owner     name.AccountName `serialize:"true"`
+	Pending   bool             `serialize:"true"`
+	NetWeight int64            `serialize:"true"`
+	CpuWeight int64            `serialize:"true"`
+	RamBytes  int64            `serialize:"true"`
 }
 
 // GetId implements core.Entity
@@ -0,0 +1,37 @@
+package resource
+
+import (
+	"github.com/MetalBlockchain/antelopevm/chain/entity"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
+)
+
+var _ entity.Entity = &ResourceLimitsConfigObject{}
+
+// ResourceLimitsConfigObject holds the parameters of the elastic block limits
+// and the size of the windows account usage is averaged over, in blocks
+type ResourceLimitsConfigObject struct {
+	ID                           types.IdType           `serialize:"true"`
+	CpuLimitParameters           ElasticLimitParameters `serialize:"true"`
+	NetLimitParameters           ElasticLimitParameters `serialize:"true"`
+	AccountCpuUsageAverageWindow uint32                 `serialize:"true"`
+	AccountNetUsageAverageWindow uint32                 `serialize:"true"`
+}
+
+// GetId implements core.Entity
+func (c *ResourceLimitsConfigObject) GetId() []byte {
+	return c.ID.ToBytes()
+}
+
+// GetIndexes implements core.Entity
+func (*ResourceLimitsConfigObject) GetIndexes() map[string]entity.EntityIndex {
+	return map[string]entity.EntityIndex{
+		"id": {
+			Fields: []string{"ID"},
+		},
+	}
+}
+
+// GetObjectType implements core.Entity
+func (*ResourceLimitsConfigObject) GetObjectType() uint8 {
+	return entity.ResourceLimitsConfigObjectType
+}
@@ -0,0 +1,56 @@
+package resource
+
+import (
+	"github.com/MetalBlockchain/antelopevm/chain/entity"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
+)
+
+var _ entity.Entity = &ResourceLimitsStateObject{}
+
+// ResourceLimitsStateObject tracks the usage of recent blocks, the usage of
+// the block being built and the total weights staked by all accounts. The
+// virtual limits expand while blocks stay below their target usage and shrink
+// back down once they are congested.
+type ResourceLimitsStateObject struct {
+	ID                   types.IdType     `serialize:"true"`
+	AverageBlockNetUsage UsageAccumulator `serialize:"true"`
+	AverageBlockCpuUsage UsageAccumulator `serialize:"true"`
+	PendingNetUsage      uint64           `serialize:"true"`
+	PendingCpuUsage      uint64           `serialize:"true"`
+	TotalNetWeight       uint64           `serialize:"true"`
+	TotalCpuWeight       uint64           `serialize:"true"`
+	TotalRamBytes        uint64           `serialize:"true"`
+	VirtualNetLimit      uint64           `serialize:"true"`
+	VirtualCpuLimit      uint64           `serialize:"true"`
+}
+
+// GetId implements core.Entity
+func (s *ResourceLimitsStateObject) GetId() []byte {
+	return s.ID.ToBytes()
+}
+
+// GetIndexes implements core.Entity
+func (*ResourceLimitsStateObject) GetIndexes() map[string]entity.EntityIndex {
+	return map[string]entity.EntityIndex{
+		"id": {
+			Fields: []string{"ID"},
+		},
+	}
+}
+
+// GetObjectType implements core.Entity
+func (*ResourceLimitsStateObject) GetObjectType() uint8 {
+	return entity.ResourceLimitsStateObjectType
+}
+
+// UpdateVirtualCpuLimit expands or contracts the virtual CPU limit based on
+// the average usage of recent blocks
+func (s *ResourceLimitsStateObject) UpdateVirtualCpuLimit(config *ResourceLimitsConfigObject) {
+	s.VirtualCpuLimit = UpdateElasticLimit(s.VirtualCpuLimit, s.AverageBlockCpuUsage.Average(), config.CpuLimitParameters)
+}
+
+// UpdateVirtualNetLimit expands or contracts the virtual NET limit based on
+// the average usage of recent blocks
+func (s *ResourceLimitsStateObject) UpdateVirtualNetLimit(config *ResourceLimitsConfigObject) {
+	s.VirtualNetLimit = UpdateElasticLimit(s.VirtualNetLimit, s.AverageBlockNetUsage.Average(), config.NetLimitParameters)
+}
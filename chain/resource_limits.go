@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 
+	"github.com/MetalBlockchain/antelopevm/chain/block"
 	"github.com/MetalBlockchain/antelopevm/chain/name"
 	"github.com/MetalBlockchain/antelopevm/chain/resource"
 	"github.com/MetalBlockchain/antelopevm/config"
@@ -23,8 +24,112 @@ func NewResourceLimitsManager(session *state.Session) *ResourceLimitsManager {
 	}
 }
 
-func (rl *ResourceLimitsManager) GetBlockCpuLimit() uint32 {
-	return config.MaxBlockCpuUsage
+// InitializeDatabase creates the resource limits config and state at genesis,
+// the virtual limits start out at the maximum block limits
+func (rl *ResourceLimitsManager) InitializeDatabase(chainConfig config.ChainConfig) error {
+	cpuLimitParameters, netLimitParameters := blockLimitParameters(chainConfig)
+	limitsConfig := &resource.ResourceLimitsConfigObject{
+		CpuLimitParameters:           cpuLimitParameters,
+		NetLimitParameters:           netLimitParameters,
+		AccountCpuUsageAverageWindow: config.AccountCpuUsageAverageWindowMs / uint32(config.BlockIntervalMs),
+		AccountNetUsageAverageWindow: config.AccountNetUsageAverageWindowMs / uint32(config.BlockIntervalMs),
+	}
+
+	if err := rl.session.CreateResourceLimitsConfigObject(limitsConfig); err != nil {
+		return fmt.Errorf("could not create resource limits config: %v", err)
+	}
+
+	if err := rl.session.CreateResourceLimitsStateObject(&resource.ResourceLimitsStateObject{
+		VirtualCpuLimit: cpuLimitParameters.Max,
+		VirtualNetLimit: netLimitParameters.Max,
+	}); err != nil {
+		return fmt.Errorf("could not create resource limits state: %v", err)
+	}
+
+	return nil
+}
+
+// blockLimitParameters returns the elastic limit parameters of the CPU and NET
+// block limits defined by [chainConfig]
+func blockLimitParameters(chainConfig config.ChainConfig) (resource.ElasticLimitParameters, resource.ElasticLimitParameters) {
+	cpu := resource.ElasticLimitParameters{
+		Target:        uint64(chainConfig.MaxBlockCpuUsage) * uint64(chainConfig.TargetBlockCpuUsagePct) / uint64(config.Percent100),
+		Max:           uint64(chainConfig.MaxBlockCpuUsage),
+		Periods:       config.BlockCpuUsageAverageWindowMs / uint32(config.BlockIntervalMs),
+		MaxMultiplier: config.MaximumElasticResourceMultiplier,
+		ContractRate:  resource.Ratio{Numerator: 99, Denominator: 100},
+		ExpandRate:    resource.Ratio{Numerator: 1000, Denominator: 999},
+	}
+	net := resource.ElasticLimitParameters{
+		Target:        chainConfig.MaxBlockNetUsage * uint64(chainConfig.TargetBlockNetUsagePct) / uint64(config.Percent100),
+		Max:           chainConfig.MaxBlockNetUsage,
+		Periods:       config.BlockSizeAverageWindowMs / uint32(config.BlockIntervalMs),
+		MaxMultiplier: config.MaximumElasticResourceMultiplier,
+		ContractRate:  resource.Ratio{Numerator: 99, Denominator: 100},
+		ExpandRate:    resource.Ratio{Numerator: 1000, Denominator: 999},
+	}
+
+	return cpu, net
+}
+
+// SetBlockParameters updates the parameters of the elastic block limits
+func (rl *ResourceLimitsManager) SetBlockParameters(cpuLimitParameters resource.ElasticLimitParameters, netLimitParameters resource.ElasticLimitParameters) error {
+	if err := cpuLimitParameters.Validate(); err != nil {
+		return err
+	}
+
+	if err := netLimitParameters.Validate(); err != nil {
+		return err
+	}
+
+	limitsConfig, err := rl.session.FindResourceLimitsConfigObject(0)
+
+	if err != nil {
+		return fmt.Errorf("could not find resource limits config: %v", err)
+	}
+
+	if limitsConfig.CpuLimitParameters == cpuLimitParameters && limitsConfig.NetLimitParameters == netLimitParameters {
+		return nil
+	}
+
+	return rl.session.ModifyResourceLimitsConfigObject(limitsConfig, func() {
+		limitsConfig.CpuLimitParameters = cpuLimitParameters
+		limitsConfig.NetLimitParameters = netLimitParameters
+	})
+}
+
+// GetBlockCpuLimit returns the CPU time left in the pending block
+func (rl *ResourceLimitsManager) GetBlockCpuLimit() (uint64, error) {
+	limitsConfig, err := rl.session.FindResourceLimitsConfigObject(0)
+
+	if err != nil {
+		return 0, fmt.Errorf("could not find resource limits config: %v", err)
+	}
+
+	limitsState, err := rl.session.FindResourceLimitsStateObject(0)
+
+	if err != nil {
+		return 0, fmt.Errorf("could not find resource limits state: %v", err)
+	}
+
+	return limitsConfig.CpuLimitParameters.Max - limitsState.PendingCpuUsage, nil
+}
+
+// GetBlockNetLimit returns the NET bandwidth left in the pending block
+func (rl *ResourceLimitsManager) GetBlockNetLimit() (uint64, error) {
+	limitsConfig, err := rl.session.FindResourceLimitsConfigObject(0)
+
+	if err != nil {
+		return 0, fmt.Errorf("could not find resource limits config: %v", err)
+	}
+
+	limitsState, err := rl.session.FindResourceLimitsStateObject(0)
+
+	if err != nil {
+		return 0, fmt.Errorf("could not find resource limits state: %v", err)
+	}
+
+	return limitsConfig.NetLimitParameters.Max - limitsState.PendingNetUsage, nil
 }
 
 func (rl *ResourceLimitsManager) InitializeAccount(account name.AccountName) error {
@@ -154,3 +259,310 @@ func (rl *ResourceLimitsManager) SetAccountLimits(account name.AccountName, ramB
 
 	return decreasedLimit, nil
 }
+
+// AddTransactionUsage bills [cpuUsage] and [netUsage] to each of [accounts] in
+// the block at [timeSlot]. It fails when an account uses more than its staked
+// share of the virtual limits over the averaging window or when the pending
+// block runs out of resources.
+func (rl *ResourceLimitsManager) AddTransactionUsage(accounts []name.AccountName, cpuUsage uint64, netUsage uint64, timeSlot uint32) error {
+	limitsConfig, err := rl.session.FindResourceLimitsConfigObject(0)
+
+	if err != nil {
+		return fmt.Errorf("could not find resource limits config: %v", err)
+	}
+
+	limitsState, err := rl.session.FindResourceLimitsStateObject(0)
+
+	if err != nil {
+		return fmt.Errorf("could not find resource limits state: %v", err)
+	}
+
+	for _, account := range accounts {
+		usage, err := rl.session.FindResourceUsageByOwner(account)
+
+		if err != nil {
+			return fmt.Errorf("could not find resource usage object: %v", err)
+		}
+
+		var ramBytes, netWeight, cpuWeight int64
+
+		if err := rl.GetAccountLimits(account, &ramBytes, &netWeight, &cpuWeight); err != nil {
+			return err
+		}
+
+		cpu, net := usage.CpuUsage, usage.NetUsage
+
+		if err := cpu.Add(cpuUsage, timeSlot, limitsConfig.AccountCpuUsageAverageWindow); err != nil {
+			return err
+		}
+
+		if err := net.Add(netUsage, timeSlot, limitsConfig.AccountNetUsageAverageWindow); err != nil {
+			return err
+		}
+
+		if err := rl.session.ModifyResourceUsage(usage, func() {
+			usage.CpuUsage = cpu
+			usage.NetUsage = net
+		}); err != nil {
+			return err
+		}
+
+		if cpuWeight >= 0 && limitsState.TotalCpuWeight > 0 {
+			used, max, err := accountUsageInWindow(&usage.CpuUsage, limitsConfig.AccountCpuUsageAverageWindow, limitsState.VirtualCpuLimit, uint64(cpuWeight), limitsState.TotalCpuWeight)
+
+			if err != nil {
+				return err
+			}
+
+			if used > max {
+				return fmt.Errorf("authorizing account %s has insufficient cpu resources", account)
+			}
+		}
+
+		if netWeight >= 0 && limitsState.TotalNetWeight > 0 {
+			used, max, err := accountUsageInWindow(&usage.NetUsage, limitsConfig.AccountNetUsageAverageWindow, limitsState.VirtualNetLimit, uint64(netWeight), limitsState.TotalNetWeight)
+
+			if err != nil {
+				return err
+			}
+
+			if used > max {
+				return fmt.Errorf("authorizing account %s has insufficient net resources", account)
+			}
+		}
+	}
+
+	if err := rl.session.ModifyResourceLimitsStateObject(limitsState, func() {
+		limitsState.PendingCpuUsage += cpuUsage
+		limitsState.PendingNetUsage += netUsage
+	}); err != nil {
+		return err
+	}
+
+	if limitsState.PendingCpuUsage > limitsConfig.CpuLimitParameters.Max {
+		return fmt.Errorf("block has insufficient cpu resources: %w", state.ErrBlockFull)
+	}
+
+	if limitsState.PendingNetUsage > limitsConfig.NetLimitParameters.Max {
+		return fmt.Errorf("block has insufficient net resources: %w", state.ErrBlockFull)
+	}
+
+	return nil
+}
+
+// accountUsageInWindow returns how much of a resource an account used over
+// the averaging window and how much its [weight] out of [totalWeight] entitles
+// it to use of the virtual limit over that window
+func accountUsageInWindow(usage *resource.UsageAccumulator, windowSize uint32, virtualLimit uint64, weight uint64, totalWeight uint64) (uint64, uint64, error) {
+	used, err := usage.ValueInWindow(windowSize)
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	capacityInWindow := virtualLimit * uint64(windowSize)
+
+	if windowSize > 0 && capacityInWindow/uint64(windowSize) != virtualLimit {
+		return 0, 0, fmt.Errorf("virtual limit overflows over the averaging window")
+	}
+
+	max, err := resource.MulDiv(capacityInWindow, weight, totalWeight)
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return used, max, nil
+}
+
+// GetAccountCpuLimit returns the CPU time [account] used and has available in
+// the current window, the values are -1 when the account is unlimited
+func (rl *ResourceLimitsManager) GetAccountCpuLimit(account name.AccountName) (*resource.AccountResourceLimit, error) {
+	var ramBytes, netWeight, cpuWeight int64
+
+	if err := rl.GetAccountLimits(account, &ramBytes, &netWeight, &cpuWeight); err != nil {
+		return nil, err
+	}
+
+	return rl.getAccountLimit(account, cpuWeight, true)
+}
+
+// GetAccountNetLimit returns the NET bandwidth [account] used and has
+// available in the current window, the values are -1 when the account is
+// unlimited
+func (rl *ResourceLimitsManager) GetAccountNetLimit(account name.AccountName) (*resource.AccountResourceLimit, error) {
+	var ramBytes, netWeight, cpuWeight int64
+
+	if err := rl.GetAccountLimits(account, &ramBytes, &netWeight, &cpuWeight); err != nil {
+		return nil, err
+	}
+
+	return rl.getAccountLimit(account, netWeight, false)
+}
+
+func (rl *ResourceLimitsManager) getAccountLimit(account name.AccountName, weight int64, cpu bool) (*resource.AccountResourceLimit, error) {
+	limitsConfig, err := rl.session.FindResourceLimitsConfigObject(0)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not find resource limits config: %v", err)
+	}
+
+	limitsState, err := rl.session.FindResourceLimitsStateObject(0)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not find resource limits state: %v", err)
+	}
+
+	usage, err := rl.session.FindResourceUsageByOwner(account)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not find resource usage object: %v", err)
+	}
+
+	accumulator, windowSize, virtualLimit, totalWeight := &usage.NetUsage, limitsConfig.AccountNetUsageAverageWindow, limitsState.VirtualNetLimit, limitsState.TotalNetWeight
+
+	if cpu {
+		accumulator, windowSize, virtualLimit, totalWeight = &usage.CpuUsage, limitsConfig.AccountCpuUsageAverageWindow, limitsState.VirtualCpuLimit, limitsState.TotalCpuWeight
+	}
+
+	limit := &resource.AccountResourceLimit{
+		Used:                -1,
+		Available:           -1,
+		Max:                 -1,
+		LastUsageUpdateTime: block.BlockTimeStamp(accumulator.LastOrdinal),
+		CurrentUsed:         -1,
+	}
+
+	if weight < 0 || totalWeight == 0 {
+		return limit, nil
+	}
+
+	used, max, err := accountUsageInWindow(accumulator, windowSize, virtualLimit, uint64(weight), totalWeight)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if used > math.MaxInt64 || max > math.MaxInt64 {
+		return nil, fmt.Errorf("usage exceeds maximum value representable after extending for precision")
+	}
+
+	limit.Used = int64(used)
+	limit.Max = int64(max)
+	limit.Available = 0
+	limit.CurrentUsed = limit.Used
+
+	if max > used {
+		limit.Available = int64(max - used)
+	}
+
+	return limit, nil
+}
+
+// ProcessAccountLimitUpdates applies the limits set during the block to the
+// accounts and updates the total weights accordingly
+func (rl *ResourceLimitsManager) ProcessAccountLimitUpdates() error {
+	limitsState, err := rl.session.FindResourceLimitsStateObject(0)
+
+	if err != nil {
+		return fmt.Errorf("could not find resource limits state: %v", err)
+	}
+
+	// Collect the pending limits first as they are removed while processing
+	pending := make([]*resource.ResourceLimits, 0)
+	iterator := rl.session.FindPendingResourceLimits()
+
+	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+		item, err := iterator.Item()
+
+		if err != nil {
+			iterator.Close()
+			return err
+		}
+
+		pending = append(pending, item)
+	}
+
+	iterator.Close()
+
+	totalRamBytes, totalCpuWeight, totalNetWeight := limitsState.TotalRamBytes, limitsState.TotalCpuWeight, limitsState.TotalNetWeight
+
+	for _, limits := range pending {
+		actual, err := rl.session.FindResourceLimitsByOwner(false, limits.Owner)
+
+		if err != nil {
+			return fmt.Errorf("could not find account limits: %v", err)
+		}
+
+		totalRamBytes = updateTotalWeight(totalRamBytes, actual.RamBytes, limits.RamBytes)
+		totalCpuWeight = updateTotalWeight(totalCpuWeight, actual.CpuWeight, limits.CpuWeight)
+		totalNetWeight = updateTotalWeight(totalNetWeight, actual.NetWeight, limits.NetWeight)
+
+		if err := rl.session.ModifyResourceLimits(actual, func() {
+			actual.RamBytes = limits.RamBytes
+			actual.CpuWeight = limits.CpuWeight
+			actual.NetWeight = limits.NetWeight
+		}); err != nil {
+			return err
+		}
+
+		if err := rl.session.RemoveResourceLimits(limits); err != nil {
+			return err
+		}
+	}
+
+	return rl.session.ModifyResourceLimitsStateObject(limitsState, func() {
+		limitsState.TotalRamBytes = totalRamBytes
+		limitsState.TotalCpuWeight = totalCpuWeight
+		limitsState.TotalNetWeight = totalNetWeight
+	})
+}
+
+// updateTotalWeight replaces [oldValue] by [newValue] in [total], negative
+// values mean unlimited and are not part of the total
+func updateTotalWeight(total uint64, oldValue int64, newValue int64) uint64 {
+	if oldValue > 0 {
+		total -= uint64(oldValue)
+	}
+
+	if newValue > 0 {
+		total += uint64(newValue)
+	}
+
+	return total
+}
+
+// ProcessBlockUsage adds the usage of the pending block to the block averages
+// and expands or contracts the virtual limits based on them
+func (rl *ResourceLimitsManager) ProcessBlockUsage(blockNum uint32) error {
+	limitsConfig, err := rl.session.FindResourceLimitsConfigObject(0)
+
+	if err != nil {
+		return fmt.Errorf("could not find resource limits config: %v", err)
+	}
+
+	limitsState, err := rl.session.FindResourceLimitsStateObject(0)
+
+	if err != nil {
+		return fmt.Errorf("could not find resource limits state: %v", err)
+	}
+
+	cpu, net := limitsState.AverageBlockCpuUsage, limitsState.AverageBlockNetUsage
+
+	if err := cpu.Add(limitsState.PendingCpuUsage, blockNum, limitsConfig.CpuLimitParameters.Periods); err != nil {
+		return err
+	}
+
+	if err := net.Add(limitsState.PendingNetUsage, blockNum, limitsConfig.NetLimitParameters.Periods); err != nil {
+		return err
+	}
+
+	return rl.session.ModifyResourceLimitsStateObject(limitsState, func() {
+		limitsState.AverageBlockCpuUsage = cpu
+		limitsState.AverageBlockNetUsage = net
+		limitsState.UpdateVirtualCpuLimit(limitsConfig)
+		limitsState.UpdateVirtualNetLimit(limitsConfig)
+		limitsState.PendingCpuUsage = 0
+		limitsState.PendingNetUsage = 0
+	})
+}
@@ -0,0 +1,95 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/config"
+	"github.com/MetalBlockchain/antelopevm/state"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestResourceLimitsManager(t *testing.T) *ResourceLimitsManager {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	session := state.NewState(nil, db).CreateSession(true)
+	t.Cleanup(session.Discard)
+	manager := NewResourceLimitsManager(session)
+	assert.NoError(t, manager.InitializeDatabase(config.ChainConfig{
+		MaxBlockNetUsage:       1024 * 1024,
+		TargetBlockNetUsagePct: uint32(config.Percent1 * 10),
+		MaxBlockCpuUsage:       200000,
+		TargetBlockCpuUsagePct: uint32(config.Percent1 * 10),
+	}))
+
+	return manager
+}
+
+func TestElasticBlockLimits(t *testing.T) {
+	manager := newTestResourceLimitsManager(t)
+	limitsState, err := manager.session.FindResourceLimitsStateObject(0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(200000), limitsState.VirtualCpuLimit)
+
+	// Blocks below their target expand the virtual limit
+	for blockNum := uint32(2); blockNum < 12; blockNum++ {
+		assert.NoError(t, manager.ProcessBlockUsage(blockNum))
+	}
+
+	limitsState, err = manager.session.FindResourceLimitsStateObject(0)
+	assert.NoError(t, err)
+	expanded := limitsState.VirtualCpuLimit
+	assert.Greater(t, expanded, uint64(200000))
+
+	// Congested blocks contract it again, but never below the block maximum
+	for blockNum := uint32(12); blockNum < 2000; blockNum++ {
+		assert.NoError(t, manager.AddTransactionUsage(nil, 150000, 0, blockNum))
+		assert.NoError(t, manager.ProcessBlockUsage(blockNum))
+	}
+
+	limitsState, err = manager.session.FindResourceLimitsStateObject(0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(200000), limitsState.VirtualCpuLimit)
+	assert.Zero(t, limitsState.PendingCpuUsage)
+
+	// A single block cannot use more than the block maximum
+	assert.Error(t, manager.AddTransactionUsage(nil, 200001, 0, 2000))
+}
+
+func TestAccountCpuLimit(t *testing.T) {
+	manager := newTestResourceLimitsManager(t)
+	alice, bob, carol := name.StringToName("alice"), name.StringToName("bob"), name.StringToName("carol")
+
+	for _, account := range []name.AccountName{alice, bob, carol} {
+		assert.NoError(t, manager.InitializeAccount(account))
+	}
+
+	// Limits only take effect at the end of the block
+	_, err := manager.SetAccountLimits(alice, -1, 1, 1)
+	assert.NoError(t, err)
+	_, err = manager.SetAccountLimits(bob, -1, 1000000000, 1000000000)
+	assert.NoError(t, err)
+	assert.NoError(t, manager.AddTransactionUsage([]name.AccountName{alice}, 1000, 1000, 1))
+	assert.NoError(t, manager.ProcessAccountLimitUpdates())
+
+	limitsState, err := manager.session.FindResourceLimitsStateObject(0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1000000001), limitsState.TotalCpuWeight)
+
+	limit, err := manager.GetAccountCpuLimit(alice)
+	assert.NoError(t, err)
+	assert.Zero(t, limit.Available)
+	assert.Positive(t, limit.Used)
+
+	// Alice's stake does not cover her usage anymore while bob's does, carol
+	// is unlimited
+	assert.Error(t, manager.AddTransactionUsage([]name.AccountName{alice}, 100, 0, 2))
+	assert.NoError(t, manager.AddTransactionUsage([]name.AccountName{bob}, 100, 0, 2))
+	assert.NoError(t, manager.AddTransactionUsage([]name.AccountName{carol}, 100, 0, 2))
+
+	limit, err = manager.GetAccountCpuLimit(carol)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), limit.Available)
+}
@@ -0,0 +1,92 @@
+package transaction
+
+import (
+	"github.com/MetalBlockchain/antelopevm/chain/entity"
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/resource"
+	"github.com/MetalBlockchain/antelopevm/chain/time"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
+	"github.com/MetalBlockchain/antelopevm/config"
+	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
+	"github.com/MetalBlockchain/antelopevm/math"
+)
+
+// DeferredTransactionGenerationContextId is the only transaction extension
+// deferred transactions may carry
+const DeferredTransactionGenerationContextId uint16 = 0
+
+// GeneratedTransactionObjectBillableSize is the RAM billed to the payer of a
+// deferred transaction on top of its packed size
+var GeneratedTransactionObjectBillableSize = resource.NewBillableSize(96 + 4 + uint64(5*config.OverheadPerRowPerIndexRamBytes))
+
+var _ entity.Entity = &GeneratedTransactionObject{}
+
+// GeneratedTransactionObject is a deferred transaction waiting to be executed
+// once its delay has passed, it is identified by its sender and sender ID
+type GeneratedTransactionObject struct {
+	ID         types.IdType      `serialize:"true"`
+	TrxId      TransactionIdType `serialize:"true"`
+	Sender     name.AccountName  `serialize:"true"`
+	SenderId   math.Uint128      `serialize:"true"`
+	Payer      name.AccountName  `serialize:"true"`
+	DelayUntil time.TimePoint    `serialize:"true"`
+	Expiration time.TimePoint    `serialize:"true"`
+	Published  time.TimePoint    `serialize:"true"`
+	PackedTrx  types.HexBytes    `serialize:"true"`
+}
+
+// GetId implements core.Entity
+func (g *GeneratedTransactionObject) GetId() []byte {
+	return g.ID.ToBytes()
+}
+
+// GetIndexes implements core.Entity
+func (g *GeneratedTransactionObject) GetIndexes() map[string]entity.EntityIndex {
+	return map[string]entity.EntityIndex{
+		"id": {
+			Fields: []string{"ID"},
+		},
+		"byTrxId": {
+			Fields: []string{"TrxId"},
+		},
+		"byDelay": {
+			Fields: []string{"DelayUntil", "ID"},
+		},
+		"bySenderId": {
+			Fields: []string{"Sender", "SenderId"},
+		},
+	}
+}
+
+// GetObjectType implements core.Entity
+func (g *GeneratedTransactionObject) GetObjectType() uint8 {
+	return entity.GeneratedTransactionObjectType
+}
+
+// GetTransaction unpacks the deferred transaction
+func (g *GeneratedTransactionObject) GetTransaction() (*Transaction, error) {
+	return unpackTransaction(g.PackedTrx)
+}
+
+// BillableSize returns the RAM the payer is billed for this object
+func (g *GeneratedTransactionObject) BillableSize() int64 {
+	return int64(GeneratedTransactionObjectBillableSize) + int64(len(g.PackedTrx))
+}
+
+// DeferredTransactionGenerationContext ties a deferred transaction to the
+// transaction that sent it, so every deferred transaction gets a unique ID
+type DeferredTransactionGenerationContext struct {
+	SenderTrxId TransactionIdType
+	SenderId    math.Uint128
+	Sender      name.AccountName
+}
+
+func (d DeferredTransactionGenerationContext) Pack() (types.HexBytes, error) {
+	return rlp.EncodeToBytes(d)
+}
+
+// TransactionIdToSenderId returns the sender ID of a delayed input transaction,
+// those have no sender so they are only identified by their ID
+func TransactionIdToSenderId(id TransactionIdType) math.Uint128 {
+	return math.Uint128{Low: id.Hash[2], High: id.Hash[3]}
+}
@@ -13,6 +13,7 @@ const (
 	Implicit  TransactionType = 1
 	Scheduled TransactionType = 2
 	DryRun    TransactionType = 3
+	ReadOnly  TransactionType = 4
 )
 
 type TransactionMetaData struct {
@@ -52,6 +53,16 @@ func RecoverKeys(trx *PackedTransaction, chainId types.ChainIdType, timeLimit ti
 	}, nil
 }
 
+// NewImplicitTransactionMetaData wraps [trx] which is generated by the chain
+// itself, it carries no signatures so there are no keys to recover
+func NewImplicitTransactionMetaData(trx *PackedTransaction) *TransactionMetaData {
+	return &TransactionMetaData{
+		packedTransaction:   trx,
+		recoveredPublicKeys: ecc.NewPublicKeySet(0),
+		transactionType:     Implicit,
+	}
+}
+
 func (m *TransactionMetaData) Implicit() bool {
 	return m.transactionType == Implicit
 }
@@ -64,6 +75,10 @@ func (m *TransactionMetaData) IsDryRun() bool {
 	return m.transactionType == DryRun
 }
 
+func (m *TransactionMetaData) IsReadOnly() bool {
+	return m.transactionType == ReadOnly
+}
+
 func (m *TransactionMetaData) Id() *TransactionIdType {
 	id, _ := m.packedTransaction.ID()
 	return id
@@ -12,6 +12,7 @@ import (
 	"github.com/MetalBlockchain/antelopevm/chain/name"
 	"github.com/MetalBlockchain/antelopevm/chain/time"
 	"github.com/MetalBlockchain/antelopevm/chain/types"
+	"github.com/MetalBlockchain/antelopevm/config"
 	"github.com/MetalBlockchain/antelopevm/crypto"
 	"github.com/MetalBlockchain/antelopevm/crypto/ecc"
 	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
@@ -237,6 +238,10 @@ type PackedTransaction struct {
 	PackedContextFreeData types.HexBytes  `serialize:"true" json:"packed_context_free_data"`
 	PackedTrx             types.HexBytes  `serialize:"true" json:"packed_trx"`
 	UnpackedTrx           *Transaction    `json:"transaction" eos:"-"`
+
+	// Digest of the signatures and context free data, only set once the
+	// context free data has been pruned
+	PrunableDigest *crypto.Sha256 `json:"prunable_digest,omitempty" eos:"-"`
 }
 
 func NewPackedTransactionFromSignedTransaction(signedTrx SignedTransaction, compressionType CompressionType) (*PackedTransaction, error) {
@@ -286,23 +291,15 @@ func (p *PackedTransaction) GetSignedTransaction() (*SignedTransaction, error) {
 	return NewSignedTransaction(p.UnpackedTrx, p.Signatures, contextFreeData), nil
 }
 
+// PackedDigest returns the digest receipts commit to, it stays the same once
+// the context free data is pruned
 func (p *PackedTransaction) PackedDigest() (*crypto.Sha256, error) {
-	prunable := crypto.NewSha256()
+	prunableResult, err := p.prunableDigest()
 
-	if result, err := rlp.EncodeToBytes(p.Signatures); err != nil {
+	if err != nil {
 		return nil, err
-	} else {
-		prunable.Write(result)
 	}
 
-	if result, err := rlp.EncodeToBytes(p.PackedContextFreeData); err != nil {
-		return nil, err
-	} else {
-		prunable.Write(result)
-	}
-
-	prunableResult := *crypto.NewSha256Byte(prunable.Sum(nil))
-
 	enc := crypto.NewSha256()
 
 	if result, err := rlp.EncodeToBytes(p.Compression); err != nil {
@@ -326,6 +323,49 @@ func (p *PackedTransaction) PackedDigest() (*crypto.Sha256, error) {
 	return crypto.NewSha256Byte(enc.Sum(nil)), nil
 }
 
+func (p *PackedTransaction) prunableDigest() (*crypto.Sha256, error) {
+	if p.PrunableDigest != nil {
+		return p.PrunableDigest, nil
+	}
+
+	prunable := crypto.NewSha256()
+
+	if result, err := rlp.EncodeToBytes(p.Signatures); err != nil {
+		return nil, err
+	} else {
+		prunable.Write(result)
+	}
+
+	if result, err := rlp.EncodeToBytes(p.PackedContextFreeData); err != nil {
+		return nil, err
+	} else {
+		prunable.Write(result)
+	}
+
+	return crypto.NewSha256Byte(prunable.Sum(nil)), nil
+}
+
+// PruneContextFreeData returns a copy of this transaction without its context
+// free data, the digest of the pruned data is kept so receipts still match
+func (p *PackedTransaction) PruneContextFreeData() (*PackedTransaction, error) {
+	digest, err := p.prunableDigest()
+
+	if err != nil {
+		return nil, err
+	}
+
+	pruned := *p
+	pruned.PackedContextFreeData = types.HexBytes{}
+	pruned.PrunableDigest = digest
+
+	return &pruned, nil
+}
+
+// Pruned reports whether the context free data of this transaction was pruned
+func (p *PackedTransaction) Pruned() bool {
+	return p.PrunableDigest != nil
+}
+
 func (p *PackedTransaction) GetTransaction() (*Transaction, error) {
 	if p.UnpackedTrx != nil {
 		return p.UnpackedTrx, nil
@@ -379,7 +419,7 @@ func (p *PackedTransaction) GetContextFreeData() ([]types.HexBytes, error) {
 }
 
 func (p *PackedTransaction) GetUnprunableSize() uint32 {
-	size := uint32(16) // FixedNetOverheadOfPackedTrx
+	size := config.FixedNetOverheadOfPackedTrx
 	size += uint32(len(p.PackedTrx))
 	return size
 }
@@ -409,6 +449,7 @@ func (p *PackedTransaction) MarshalJSON() ([]byte, error) {
 		PackedTrx             types.HexBytes     `json:"packed_trx"`
 		UnpackedTrx           *Transaction       `json:"transaction"`
 		Id                    *TransactionIdType `json:"id"`
+		PrunableDigest        *crypto.Sha256     `json:"prunable_digest,omitempty"`
 	}{
 		Signatures:            p.Signatures,
 		Compression:           p.Compression,
@@ -416,6 +457,7 @@ func (p *PackedTransaction) MarshalJSON() ([]byte, error) {
 		PackedTrx:             p.PackedTrx,
 		UnpackedTrx:           p.UnpackedTrx,
 		Id:                    id,
+		PrunableDigest:        p.PrunableDigest,
 	})
 }
 
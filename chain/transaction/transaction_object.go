@@ -9,9 +9,9 @@ import (
 var _ entity.Entity = &TransactionObject{}
 
 type TransactionObject struct {
-	ID         types.IdType
-	Expiration time.TimePointSec
-	TrxId      TransactionIdType
+	ID         types.IdType      `serialize:"true"`
+	Expiration time.TimePointSec `serialize:"true"`
+	TrxId      TransactionIdType `serialize:"true"`
 }
 
 func (p TransactionObject) GetId() []byte {
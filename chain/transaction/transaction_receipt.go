@@ -40,3 +40,22 @@ func (t *TransactionReceipt) Digest() (*crypto.Sha256, error) {
 
 	return crypto.NewSha256Byte(enc.Sum(nil)), nil
 }
+
+// ScheduledTransactionReceipt is the receipt of a deferred transaction, blocks
+// only refer to it by ID as the transaction itself is stored on chain
+type ScheduledTransactionReceipt struct {
+	TransactionReceiptHeader `serialize:"true"`
+	TrxId                    TransactionIdType `serialize:"true" json:"trx"`
+}
+
+func (t *ScheduledTransactionReceipt) Digest() (*crypto.Sha256, error) {
+	enc := crypto.NewSha256()
+
+	if data, err := rlp.EncodeMultipleToBytes(t.Status, t.CpuUsageUs, t.NetUsageWords, t.TrxId); err != nil {
+		return nil, err
+	} else {
+		enc.Write(data)
+	}
+
+	return crypto.NewSha256Byte(enc.Sum(nil)), nil
+}
@@ -0,0 +1,58 @@
+package transaction_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneContextFreeData(t *testing.T) {
+	trx := &transaction.Transaction{
+		ContextFreeActions: []*transaction.Action{{Account: name.StringToName("glenn"), Name: name.StringToName("check")}},
+	}
+	signedTrx := transaction.NewSignedTransaction(trx, nil, []types.HexBytes{{0x01, 0x02}, {0x03}})
+	packedTrx, err := transaction.NewPackedTransactionFromSignedTransaction(*signedTrx, transaction.CompressionNone)
+	assert.NoError(t, err)
+
+	contextFreeData, err := packedTrx.GetContextFreeData()
+	assert.NoError(t, err)
+	assert.Equal(t, []types.HexBytes{{0x01, 0x02}, {0x03}}, contextFreeData)
+
+	// The receipt digest does not change once the data is gone
+	digest, err := packedTrx.PackedDigest()
+	assert.NoError(t, err)
+	pruned, err := packedTrx.PruneContextFreeData()
+	assert.NoError(t, err)
+	assert.True(t, pruned.Pruned())
+	assert.False(t, packedTrx.Pruned())
+
+	prunedDigest, err := pruned.PackedDigest()
+	assert.NoError(t, err)
+	assert.Equal(t, digest, prunedDigest)
+
+	contextFreeData, err = pruned.GetContextFreeData()
+	assert.NoError(t, err)
+	assert.Empty(t, contextFreeData)
+}
+
+func TestTransactionTraceJSON(t *testing.T) {
+	trace := transaction.TransactionTrace{
+		ActionTraces: []transaction.ActionTrace{{Console: "hello", ReturnValue: types.HexBytes{0x2a}}},
+		Except:       fmt.Errorf("assertion failure"),
+	}
+	data, err := json.Marshal(trace)
+	assert.NoError(t, err)
+
+	var out map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &out))
+	assert.Equal(t, map[string]interface{}{"code": float64(0), "message": "assertion failure"}, out["except"])
+	action := out["action_traces"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "hello", action["console"])
+	assert.Equal(t, "2a", action["return_value_hex_data"])
+	assert.Nil(t, action["except"])
+}
@@ -1,6 +1,9 @@
 package transaction
 
 import (
+	"encoding/json"
+	"errors"
+
 	"github.com/MetalBlockchain/antelopevm/chain/entity"
 	"github.com/MetalBlockchain/antelopevm/chain/fc"
 	"github.com/MetalBlockchain/antelopevm/chain/name"
@@ -12,17 +15,51 @@ import (
 var _ entity.Entity = &TransactionTrace{}
 
 type TransactionTrace struct {
-	ID           types.IdType       `serialize:"true" json:"-" eos:"-"`
-	Hash         TransactionIdType  `serialize:"true" json:"id"`
-	BlockNum     uint64             `serialize:"true" json:"block_num"`
-	BlockTime    time.TimePoint     `serialize:"true" json:"block_time"`
-	Receipt      TransactionReceipt `serialize:"true" json:"receipt"`
-	Elapsed      time.Microseconds  `serialize:"true" json:"elapsed"`
-	NetUsage     uint64             `serialize:"true" json:"-"`
-	Scheduled    bool               `serialize:"true" json:"scheduled"`
-	ActionTraces []ActionTrace      `serialize:"true" json:"action_traces"`
-	Except       error              `msg:"-" json:"-"`
-	ErrorCode    uint64             `json:"-"`
+	ID              types.IdType       `serialize:"true" json:"-" eos:"-"`
+	Hash            TransactionIdType  `serialize:"true" json:"id"`
+	BlockNum        uint64             `serialize:"true" json:"block_num"`
+	BlockTime       time.TimePoint     `serialize:"true" json:"block_time"`
+	Receipt         TransactionReceipt `serialize:"true" json:"receipt"`
+	Elapsed         time.Microseconds  `serialize:"true" json:"elapsed"`
+	NetUsage        uint64             `serialize:"true" json:"-"`
+	Scheduled       bool               `serialize:"true" json:"scheduled"`
+	ActionTraces    []ActionTrace      `serialize:"true" json:"action_traces"`
+	AccountRamDelta RamDelta           `serialize:"true" json:"account_ram_delta"`
+	Except          error              `msg:"-" json:"-"`
+	ErrorCode       uint64             `json:"-"`
+}
+
+// MarshalJSON adds the error that aborted the transaction to its JSON
+func (a TransactionTrace) MarshalJSON() ([]byte, error) {
+	type transactionTrace TransactionTrace
+
+	return json.Marshal(struct {
+		transactionTrace
+		Except *Exception `json:"except"`
+	}{transactionTrace(a), NewException(a.Except)})
+}
+
+// Exception is the JSON form of the error that aborted a transaction or an
+// action, the code is only set for errors that carry one
+type Exception struct {
+	Code    int64  `json:"code"`
+	Message string `json:"message"`
+}
+
+func NewException(err error) *Exception {
+	if err == nil {
+		return nil
+	}
+
+	exception := &Exception{Message: err.Error()}
+
+	var coded interface{ Code() int64 }
+
+	if errors.As(err, &coded) {
+		exception.Code = coded.Code()
+	}
+
+	return exception
 }
 
 func (a TransactionTrace) GetId() []byte {
@@ -41,7 +78,7 @@ func (a TransactionTrace) GetIndexes() map[string]entity.EntityIndex {
 }
 
 func (a TransactionTrace) GetObjectType() uint8 {
-	return entity.TransactionObjectType
+	return entity.TransactionTraceType
 }
 
 type RamDelta struct {
@@ -58,12 +95,23 @@ type ActionTrace struct {
 	Action               Action            `serialize:"true" json:"act"`
 	ContextFree          bool              `serialize:"true" json:"context_free"`
 	Elapsed              uint64            `serialize:"true" json:"elapsed"`
-	Console              string            `serialize:"true" json:"-"`
+	Console              string            `serialize:"true" json:"console"`
 	TransactionId        TransactionIdType `serialize:"true" json:"trx_id"`
 	BlockNum             uint64            `serialize:"true" json:"block_num"`
 	BlockTime            time.TimePoint    `serialize:"true" json:"block_time"`
 	Except               error             `msg:"-" json:"-"`
 	ErrorCode            uint64            `serialize:"true" json:"-"`
+	ReturnValue          types.HexBytes    `serialize:"true" json:"return_value_hex_data"`
+}
+
+// MarshalJSON adds the error that aborted the action to its JSON
+func (a ActionTrace) MarshalJSON() ([]byte, error) {
+	type actionTrace ActionTrace
+
+	return json.Marshal(struct {
+		actionTrace
+		Except *Exception `json:"except"`
+	}{actionTrace(a), NewException(a.Except)})
 }
 
 func NewActionTrace(trace *TransactionTrace, action Action, receiver name.AccountName, contextFree bool, actionOrdinal fc.UnsignedInt, creatorActionOrdinal fc.UnsignedInt) *ActionTrace {
@@ -1,15 +1,20 @@
 package chain
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 
+	"github.com/MetalBlockchain/antelopevm/chain/block"
 	"github.com/MetalBlockchain/antelopevm/chain/fc"
 	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/protocol"
 	"github.com/MetalBlockchain/antelopevm/chain/time"
 	"github.com/MetalBlockchain/antelopevm/chain/transaction"
 	"github.com/MetalBlockchain/antelopevm/chain/types"
 	"github.com/MetalBlockchain/antelopevm/config"
 	"github.com/MetalBlockchain/antelopevm/crypto"
+	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
 	"github.com/MetalBlockchain/antelopevm/state"
 	"github.com/MetalBlockchain/antelopevm/wasm/api"
 )
@@ -21,6 +26,7 @@ type TransactionContext struct {
 	PackedTrx                    *transaction.PackedTransaction
 	ID                           transaction.TransactionIdType
 	ApplyContextFree             bool
+	ContextFreeData              []types.HexBytes
 	Trace                        *transaction.TransactionTrace
 	ActionId                     types.IdType
 	ExecutedActionReceiptDigests []crypto.Sha256
@@ -29,6 +35,11 @@ type TransactionContext struct {
 	Deadline                     time.TimePoint
 	BilledCpuTimeUs              int64
 	ExplicitBilledCpuTime        bool
+	NetUsage                     uint64
+	BillToAccounts               []name.AccountName
+
+	// ReadOnly transactions may only read the chain state
+	ReadOnly bool
 
 	Published time.TimePoint
 
@@ -42,12 +53,20 @@ type TransactionContext struct {
 	deadlineExceptionCode     int64
 	billingTimerExceptionCode int64
 	isInput                   bool
+	netLimit                  uint64
+	eagerNetLimit             uint64
+	netLimitDueToBlock        bool
+	delay                     time.Microseconds
+	minCpuUsage               time.Microseconds
+	validateRamUsage          map[name.AccountName]bool
 }
 
 func NewTransactionContext(control *Controller, s *state.Session, t *transaction.PackedTransaction, trxId transaction.TransactionIdType, block *state.Block) *TransactionContext {
 	tc := TransactionContext{
 		Control:               control,
 		PackedTrx:             t,
+		ID:                    trxId,
+		ApplyContextFree:      true,
 		Session:               s,
 		AuthorizationManager:  *NewAuthorizationManager(control, s),
 		Deadline:              time.MaxTimePoint(),
@@ -61,6 +80,7 @@ func NewTransactionContext(control *Controller, s *state.Session, t *transaction
 		deadline:                  time.MaxTimePoint(),
 		deadlineExceptionCode:     BlockCpuUsageExceededException{}.Code(),
 		billingTimerExceptionCode: BlockCpuUsageExceededException{}.Code(),
+		validateRamUsage:          make(map[name.AccountName]bool),
 	}
 
 	tc.Trace = &transaction.TransactionTrace{
@@ -100,6 +120,12 @@ func (t *TransactionContext) InitForInputTransaction(packedTrxUnprunableSize uin
 		return fmt.Errorf("no transaction extensions supported yet for input transactions")
 	}
 
+	for _, act := range transaction.ContextFreeActions {
+		if len(act.Authorization) > 0 {
+			return fmt.Errorf("context-free actions cannot have authorizations")
+		}
+	}
+
 	cfg, err := t.Session.FindGlobalPropertyObject(0)
 
 	if err != nil {
@@ -114,18 +140,65 @@ func (t *TransactionContext) InitForInputTransaction(packedTrxUnprunableSize uin
 	}
 
 	initialNetUsage := uint64(cfg.Configuration.BasePerTransactionNetUsage) + packedTrxUnprunableSize + discountedSizeForPrunedData
+
+	// Delayed transactions are charged ahead of time for the NET needed to
+	// retire them later on
+	if transaction.DelaySec > 0 {
+		initialNetUsage += uint64(cfg.Configuration.BasePerTransactionNetUsage) + config.TransactionIdNetUsage
+	}
+
+	if t.ReadOnly && transaction.DelaySec > 0 {
+		return fmt.Errorf("read-only transactions cannot be delayed")
+	}
+
 	t.Published = t.Control.PendingBlockTime()
 	t.isInput = true
+	t.delay = time.Seconds(int64(transaction.DelaySec))
+
+	if err := t.Control.ValidateExpiration(t.Session, transaction, t.Published); err != nil {
+		return err
+	}
+
+	if err := t.Control.ValidateTapos(t.Session, transaction); err != nil {
+		return err
+	}
 
 	if err := t.Init(initialNetUsage); err != nil {
 		return err
 	}
 
+	// Read-only transactions are never included in a block
+	if t.ReadOnly {
+		return nil
+	}
+
 	id, _ := t.PackedTrx.ID()
 
 	return t.RecordTransaction(*id, transaction.Expiration)
 }
 
+// InitForDeferredTransaction prepares the execution of a deferred transaction
+// that was scheduled at [published], its NET was already billed to the sender
+func (t *TransactionContext) InitForDeferredTransaction(published time.TimePoint) error {
+	transaction, err := t.PackedTrx.GetTransaction()
+
+	if err != nil {
+		return err
+	}
+
+	// Deferred transactions sent before NO_DUPLICATE_DEFERRED_ID have an
+	// expiration and could not carry extensions
+	if transaction.Expiration != 0 && len(transaction.TransactionExtensions) > 0 {
+		return fmt.Errorf("no transaction extensions supported yet for deferred transactions")
+	}
+
+	t.Published = published
+	t.Trace.Scheduled = true
+	t.ApplyContextFree = false
+
+	return t.Init(0)
+}
+
 func (t *TransactionContext) Init(initialNetUsage uint64) error {
 	if t.isInitialized {
 		return fmt.Errorf("cannot initialize twice")
@@ -137,16 +210,59 @@ func (t *TransactionContext) Init(initialNetUsage uint64) error {
 		return err
 	}
 
-	if transaction.DelaySec > 0 {
-		return fmt.Errorf("deferred transactions are deprecated")
+	cfg, err := t.Session.FindGlobalPropertyObject(0)
+
+	if err != nil {
+		return err
 	}
 
-	t.objectiveDurationLimit = time.Microseconds(config.MaxBlockCpuUsage)
+	if t.delay > time.Seconds(int64(cfg.Configuration.MaxTrxDelay)) {
+		return fmt.Errorf("delay_sec %d exceeds the maximum transaction delay of %d seconds", transaction.DelaySec, cfg.Configuration.MaxTrxDelay)
+	}
+
+	t.minCpuUsage = time.Microseconds(cfg.Configuration.MinTransactionCpuUsage)
+
+	resourceLimits := t.Control.GetResourceLimitsManager(t.Session)
+	blockCpuLimit, err := resourceLimits.GetBlockCpuLimit()
+
+	if err != nil {
+		return err
+	}
+
+	blockNetLimit, err := resourceLimits.GetBlockNetLimit()
+
+	if err != nil {
+		return err
+	}
+
+	t.netLimit = blockNetLimit
+	t.netLimitDueToBlock = true
+
+	// Possibly lower net_limit to the maximum net usage a transaction is allowed to be billed
+	if uint64(cfg.Configuration.MaxTransactionNetUsage) <= t.netLimit {
+		t.netLimit = uint64(cfg.Configuration.MaxTransactionNetUsage)
+		t.netLimitDueToBlock = false
+	}
+
+	// Possibly lower net_limit to optional limit set in transaction header
+	if trxSpecifiedNetUsageLimit := uint64(transaction.MaxNetUsageWords) * 8; trxSpecifiedNetUsageLimit > 0 && trxSpecifiedNetUsageLimit <= t.netLimit {
+		t.netLimit = trxSpecifiedNetUsageLimit
+		t.netLimitDueToBlock = false
+	}
+
+	t.eagerNetLimit = t.netLimit
+
+	// Fail early when the transaction is already larger than allowed
+	if err := t.AddNetUsage(initialNetUsage); err != nil {
+		return err
+	}
+
+	t.objectiveDurationLimit = time.Microseconds(blockCpuLimit)
 	t.deadline = t.start + time.TimePoint(t.objectiveDurationLimit)
 
 	// Possibly lower objective_duration_limit to the maximum cpu usage a transaction is allowed to be billed
-	if config.MaxTransactionCpuUsage <= uint32(t.objectiveDurationLimit.Count()) {
-		t.objectiveDurationLimit = time.Microseconds(config.MaxTransactionCpuUsage)
+	if cfg.Configuration.MaxTransactionCpuUsage <= uint32(t.objectiveDurationLimit.Count()) {
+		t.objectiveDurationLimit = time.Microseconds(cfg.Configuration.MaxTransactionCpuUsage)
 		t.billingTimerExceptionCode = TxCpuUsageExceededException{}.Code()
 		t.deadline = t.start + time.TimePoint(t.objectiveDurationLimit)
 	}
@@ -170,6 +286,45 @@ func (t *TransactionContext) Init(initialNetUsage uint64) error {
 		t.deadlineExceptionCode = t.billingTimerExceptionCode
 	}
 
+	if err := t.initBillToAccounts(transaction); err != nil {
+		return err
+	}
+
+	// Stop early when the accounts billed cannot pay for the time left
+	accountNetLimit, accountCpuLimit, err := t.maxBandwidthBilledAccountsCanPay()
+
+	if err != nil {
+		return err
+	}
+
+	if accountNetLimit >= 0 && uint64(accountNetLimit) <= t.eagerNetLimit {
+		t.eagerNetLimit = uint64(accountNetLimit)
+		t.netLimitDueToBlock = false
+	}
+
+	// Round down to a multiple of the word size, the usage is billed in words
+	t.eagerNetLimit = (t.eagerNetLimit / 8) * 8
+
+	if err := t.CheckNetUsage(); err != nil {
+		return err
+	}
+
+	if accountCpuLimit >= 0 && t.start+time.TimePoint(accountCpuLimit) < t.deadline {
+		t.deadline = t.start + time.TimePoint(accountCpuLimit)
+		t.deadlineExceptionCode = TxCpuUsageExceededException{}.Code()
+	}
+
+	// Explicitly billed CPU time comes from the receipt of the block producer,
+	// a slower validator should not fail the transaction on its own clock
+	if t.ExplicitBilledCpuTime {
+		if err := t.ValidateCpuUsageToBill(t.BilledCpuTimeUs); err != nil {
+			return err
+		}
+
+		t.deadline = t.Deadline
+		t.deadlineExceptionCode = DeadlineException{}.Code()
+	}
+
 	if err := t.CheckTime(); err != nil {
 		return err
 	}
@@ -179,6 +334,90 @@ func (t *TransactionContext) Init(initialNetUsage uint64) error {
 	return nil
 }
 
+// initBillToAccounts collects the accounts the transaction is billed to, every
+// authorizer unless ONLY_BILL_FIRST_AUTHORIZER is active
+func (t *TransactionContext) initBillToAccounts(trx *transaction.Transaction) error {
+	onlyFirst, err := t.Control.GetProtocolFeatureManager(t.Session).IsBuiltinFeatureActivated(protocol.OnlyBillFirstAuthorizer)
+
+	if err != nil {
+		return err
+	}
+
+	accounts := make(map[name.AccountName]struct{})
+
+	for _, act := range trx.Actions {
+		for _, auth := range act.Authorization {
+			if onlyFirst && len(accounts) > 0 {
+				break
+			}
+
+			accounts[auth.Actor] = struct{}{}
+		}
+	}
+
+	t.BillToAccounts = make([]name.AccountName, 0, len(accounts))
+
+	for account := range accounts {
+		t.BillToAccounts = append(t.BillToAccounts, account)
+	}
+
+	sort.Slice(t.BillToAccounts, func(i, j int) bool {
+		return t.BillToAccounts[i] < t.BillToAccounts[j]
+	})
+
+	return nil
+}
+
+// maxBandwidthBilledAccountsCanPay returns the NET and CPU available to the
+// billed accounts with the least left, or -1 when all of them are unlimited
+func (t *TransactionContext) maxBandwidthBilledAccountsCanPay() (int64, int64, error) {
+	resourceLimits := t.Control.GetResourceLimitsManager(t.Session)
+	var netAvailable, cpuAvailable int64 = -1, -1
+
+	for _, account := range t.BillToAccounts {
+		netLimit, err := resourceLimits.GetAccountNetLimit(account)
+
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if netLimit.Available >= 0 && (netAvailable < 0 || netLimit.Available < netAvailable) {
+			netAvailable = netLimit.Available
+		}
+
+		cpuLimit, err := resourceLimits.GetAccountCpuLimit(account)
+
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if cpuLimit.Available >= 0 && (cpuAvailable < 0 || cpuLimit.Available < cpuAvailable) {
+			cpuAvailable = cpuLimit.Available
+		}
+	}
+
+	return netAvailable, cpuAvailable, nil
+}
+
+// AddNetUsage bills [usage] bytes of NET to the transaction
+func (t *TransactionContext) AddNetUsage(usage uint64) error {
+	t.NetUsage += usage
+
+	return t.CheckNetUsage()
+}
+
+func (t *TransactionContext) CheckNetUsage() error {
+	if t.NetUsage <= t.eagerNetLimit {
+		return nil
+	}
+
+	if t.netLimitDueToBlock {
+		return fmt.Errorf("not enough space left in block: %d > %d: %w", t.NetUsage, t.eagerNetLimit, state.ErrBlockFull)
+	}
+
+	return fmt.Errorf("transaction net usage is too high: %d > %d", t.NetUsage, t.eagerNetLimit)
+}
+
 func (t *TransactionContext) Exec() error {
 	if !t.isInitialized {
 		return fmt.Errorf("must first initialize")
@@ -191,23 +430,151 @@ func (t *TransactionContext) Exec() error {
 	}
 
 	if t.ApplyContextFree {
+		contextFreeData, err := t.PackedTrx.GetContextFreeData()
+
+		if err != nil {
+			return err
+		}
+
+		t.ContextFreeData = contextFreeData
+
 		for _, act := range transaction.ContextFreeActions {
+			if err := t.AddNetUsage(config.ActionNetUsageOverhead); err != nil {
+				return err
+			}
+
 			t.ScheduleAction(*act, act.Account, true, 0)
 		}
 	}
 
-	if transaction.DelaySec == 0 {
+	if t.delay == 0 {
 		for _, act := range transaction.Actions {
+			if err := t.AddNetUsage(config.ActionNetUsageOverhead); err != nil {
+				return err
+			}
+
 			t.ScheduleAction(*act, act.Account, false, 0)
 		}
 	}
 
-	for i := 1; i <= len(t.Trace.ActionTraces); i++ {
+	// Inline actions are scheduled behind the original ones while executing,
+	// they are run by the action that sent them
+	originalActions := len(t.Trace.ActionTraces)
+
+	for i := 1; i <= originalActions; i++ {
+		if t.Trace.ActionTraces[i-1].CreatorActionOrdinal != 0 {
+			continue
+		}
+
 		if err := t.ExecuteAction(i, 0); err != nil {
 			return err
 		}
 	}
 
+	if t.delay != 0 {
+		return t.scheduleTransaction(transaction)
+	}
+
+	return nil
+}
+
+// scheduleTransaction stores the delayed input transaction [trx] so its
+// actions execute once the delay has passed, until then it can be cancelled
+// through eosio::canceldelay. The first authorizer pays for the RAM
+func (t *TransactionContext) scheduleTransaction(trx *transaction.Transaction) error {
+	packedTrx, err := rlp.EncodeToBytes(trx)
+
+	if err != nil {
+		return err
+	}
+
+	gpo, err := t.Session.FindGlobalPropertyObject(0)
+
+	if err != nil {
+		return err
+	}
+
+	payer := name.AccountName(0)
+
+	if len(trx.Actions) > 0 && len(trx.Actions[0].Authorization) > 0 {
+		payer = trx.Actions[0].Authorization[0].Actor
+	}
+
+	delayUntil := t.Published.AddUs(t.delay)
+	generated := &transaction.GeneratedTransactionObject{
+		TrxId:      t.ID,
+		Payer:      payer,
+		SenderId:   transaction.TransactionIdToSenderId(t.ID),
+		Published:  t.Published,
+		DelayUntil: delayUntil,
+		Expiration: delayUntil.AddUs(time.Seconds(int64(gpo.Configuration.DeferredTrxExpirationWindow))),
+		PackedTrx:  packedTrx,
+	}
+
+	if err := t.Session.CreateGeneratedTransaction(generated); err != nil {
+		return err
+	}
+
+	if err := t.AddRamUsage(payer, generated.BillableSize()); err != nil {
+		return err
+	}
+
+	t.Trace.AccountRamDelta = transaction.RamDelta{Account: payer, Delta: generated.BillableSize()}
+
+	return nil
+}
+
+// AddRamUsage bills [delta] bytes of RAM to [account], accounts using more RAM
+// are checked against their quota when the transaction is finalized
+func (t *TransactionContext) AddRamUsage(account name.AccountName, delta int64) error {
+	if err := t.Control.GetResourceLimitsManager(t.Session).AddPendingRamUsage(account, delta); err != nil {
+		return err
+	}
+
+	if delta > 0 {
+		t.validateRamUsage[account] = true
+	}
+
+	return nil
+}
+
+// ValidateReferencedAccounts checks that every account and permission [trx]
+// refers to exists before it is accepted for later execution
+func (t *TransactionContext) ValidateReferencedAccounts(trx *transaction.Transaction) error {
+	for _, act := range trx.ContextFreeActions {
+		if _, err := t.Session.FindAccountByName(act.Account); err != nil {
+			return fmt.Errorf("action's code account '%s' does not exist", act.Account)
+		}
+
+		if len(act.Authorization) > 0 {
+			return fmt.Errorf("context-free actions cannot have authorizations")
+		}
+	}
+
+	oneAuth := false
+
+	for _, act := range trx.Actions {
+		if _, err := t.Session.FindAccountByName(act.Account); err != nil {
+			return fmt.Errorf("action's code account '%s' does not exist", act.Account)
+		}
+
+		for _, auth := range act.Authorization {
+			oneAuth = true
+
+			if _, err := t.Session.FindAccountByName(auth.Actor); err != nil {
+				return fmt.Errorf("action's authorizing actor '%s' does not exist", auth.Actor)
+			}
+
+			if _, err := t.AuthorizationManager.GetPermission(auth); err != nil {
+				return fmt.Errorf("action's authorizations include a non-existent permission: %s@%s", auth.Actor, auth.Permission)
+			}
+		}
+	}
+
+	if !oneAuth {
+		return fmt.Errorf("transaction must have at least one authorization")
+	}
+
 	return nil
 }
 
@@ -232,6 +599,12 @@ func (t *TransactionContext) ScheduleAction(action transaction.Action, receiver
 	return newActionOrdinal
 }
 
+// checksAccessLists reports whether the node-local account and key lists
+// apply, they are skipped when the transaction is replayed from a block
+func (t *TransactionContext) checksAccessLists() bool {
+	return !t.ExplicitBilledCpuTime
+}
+
 func (t *TransactionContext) ExecuteAction(actionOrdinal int, recurseDepth uint32) error {
 	applyContext, err := NewApplyContext(t, actionOrdinal, recurseDepth)
 
@@ -240,6 +613,25 @@ func (t *TransactionContext) ExecuteAction(actionOrdinal int, recurseDepth uint3
 	}
 
 	if err := applyContext.Exec(); err != nil {
+		// Keep the ordinal of the inline action that failed first
+		var actionErr *ActionException
+
+		if !errors.As(err, &actionErr) {
+			actionErr = &ActionException{
+				ActionOrdinal: applyContext.ActionOrdinal,
+				Receiver:      applyContext.Receiver,
+				Account:       applyContext.Act.Account,
+				Name:          applyContext.Act.Name,
+				Err:           err,
+			}
+
+			if trace, traceErr := t.GetActionTrace(applyContext.ActionOrdinal); traceErr == nil {
+				trace.Except = actionErr
+			}
+
+			err = actionErr
+		}
+
 		return err
 	}
 
@@ -257,9 +649,34 @@ func (t *TransactionContext) GetActionTrace(actionOrdinal int) (*transaction.Act
 func (t *TransactionContext) Finalize() error {
 	now := time.Now()
 	t.Trace.Elapsed = time.Microseconds(now - t.start)
-	t.UpdateBilledCpuTime(now)
 
-	return nil
+	if !t.ExplicitBilledCpuTime {
+		t.UpdateBilledCpuTime(now)
+	}
+
+	if err := t.ValidateCpuUsageToBill(t.BilledCpuTimeUs); err != nil {
+		return err
+	}
+
+	// Round up to a multiple of the word size and check against the full limit
+	t.NetUsage = ((t.NetUsage + 7) / 8) * 8
+	t.eagerNetLimit = t.netLimit
+
+	if err := t.CheckNetUsage(); err != nil {
+		return err
+	}
+
+	resourceLimits := t.Control.GetResourceLimitsManager(t.Session)
+
+	for account := range t.validateRamUsage {
+		if err := resourceLimits.VerifyAccountRamUsage(account); err != nil {
+			return err
+		}
+	}
+
+	timeSlot := block.NewBlockTimeStampFromTimePoint(t.Control.PendingBlockTime())
+
+	return resourceLimits.AddTransactionUsage(t.BillToAccounts, uint64(t.BilledCpuTimeUs), t.NetUsage, uint32(timeSlot))
 }
 
 func (t *TransactionContext) Commit() error {
@@ -287,7 +704,7 @@ func (t *TransactionContext) CheckTime() error {
 	if t.deadlineExceptionCode == (DeadlineException{}).Code() {
 		return fmt.Errorf("deadline exceeded %dus", duration)
 	} else if t.deadlineExceptionCode == (BlockCpuUsageExceededException{}).Code() {
-		return fmt.Errorf("not enough time left in block to complete executing transaction %dus", duration)
+		return fmt.Errorf("not enough time left in block to complete executing transaction %dus: %w", duration, state.ErrBlockFull)
 	} else if t.deadlineExceptionCode == (TxCpuUsageExceededException{}).Code() {
 		return fmt.Errorf("transaction was executing for too long %dus", duration)
 	} else if t.deadlineExceptionCode == (LeewayDeadlineException{}).Code() {
@@ -320,14 +737,30 @@ func (t *TransactionContext) ResumeBillingTimer() {
 func (t *TransactionContext) UpdateBilledCpuTime(now time.TimePoint) {
 	billed := now - t.pseudoStart
 
-	if billed < time.TimePoint(config.MinTransactionCpuUsage) {
-		t.BilledCpuTimeUs = int64(config.MinTransactionCpuUsage)
+	if billed < time.TimePoint(t.minCpuUsage) {
+		t.BilledCpuTimeUs = t.minCpuUsage.Count()
 	} else {
 		t.BilledCpuTimeUs = int64(billed)
 	}
 }
 
+func (t *TransactionContext) ValidateCpuUsageToBill(billedUs int64) error {
+	if billedUs < t.minCpuUsage.Count() {
+		return fmt.Errorf("cannot bill CPU time less than the minimum of %dus, billed %dus", t.minCpuUsage.Count(), billedUs)
+	}
+
+	if billedUs > t.objectiveDurationLimit.Count() {
+		return fmt.Errorf("billed CPU time (%dus) is greater than the maximum billable CPU time for the transaction (%dus)", billedUs, t.objectiveDurationLimit.Count())
+	}
+
+	return nil
+}
+
 func (t *TransactionContext) RecordTransaction(id transaction.TransactionIdType, expire time.TimePointSec) error {
+	if err := t.Control.ValidateUniqueness(t.Session, id); err != nil {
+		return err
+	}
+
 	return t.Session.CreateTransactionObject(&transaction.TransactionObject{
 		TrxId:      id,
 		Expiration: expire,
@@ -337,3 +770,9 @@ func (t *TransactionContext) RecordTransaction(id transaction.TransactionIdType,
 func (t *TransactionContext) GetPublicationTime() time.TimePoint {
 	return t.Published
 }
+
+// IsExplicitlyBilled reports whether the CPU time of the transaction was billed
+// by the block producer, which is the case when it is replayed from a block
+func (t *TransactionContext) IsExplicitlyBilled() bool {
+	return t.ExplicitBilledCpuTime
+}
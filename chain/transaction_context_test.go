@@ -0,0 +1,122 @@
+package chain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/MetalBlockchain/antelopevm/chain/account"
+	"github.com/MetalBlockchain/antelopevm/chain/authority"
+	"github.com/MetalBlockchain/antelopevm/chain/block"
+	"github.com/MetalBlockchain/antelopevm/chain/global"
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/time"
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
+	"github.com/MetalBlockchain/antelopevm/config"
+	"github.com/MetalBlockchain/antelopevm/crypto"
+	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
+	"github.com/MetalBlockchain/antelopevm/state"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionNetUsage(t *testing.T) {
+	trxContext := &TransactionContext{netLimit: 100, eagerNetLimit: 96}
+
+	assert.NoError(t, trxContext.AddNetUsage(90))
+	assert.NoError(t, trxContext.AddNetUsage(6))
+	assert.ErrorContains(t, trxContext.AddNetUsage(1), "transaction net usage is too high")
+
+	trxContext.netLimitDueToBlock = true
+	assert.ErrorContains(t, trxContext.CheckNetUsage(), "not enough space left in block")
+}
+
+func TestTransactionCpuUsageToBill(t *testing.T) {
+	// The minimum comes from the chain configuration, not the default
+	trxContext := &TransactionContext{minCpuUsage: 500, objectiveDurationLimit: 1000}
+
+	assert.ErrorContains(t, trxContext.ValidateCpuUsageToBill(int64(config.MinTransactionCpuUsage)), "minimum of 500us")
+	assert.NoError(t, trxContext.ValidateCpuUsageToBill(500))
+	assert.ErrorContains(t, trxContext.ValidateCpuUsageToBill(1001), "greater than the maximum")
+
+	trxContext.pseudoStart = time.Now()
+	trxContext.UpdateBilledCpuTime(trxContext.pseudoStart)
+	assert.Equal(t, int64(500), trxContext.BilledCpuTimeUs)
+}
+
+func TestInlineActionFailure(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	session := state.NewState(nil, db).CreateSession(true)
+	t.Cleanup(session.Discard)
+
+	alice := name.StringToName("alice")
+	assert.NoError(t, session.CreateAccountMetaData(&account.AccountMetaDataObject{Name: alice}))
+
+	// The inline action is sent to an account that does not exist
+	transfer := transaction.Action{Account: alice, Name: name.StringToName("transfer")}
+	trxContext := &TransactionContext{
+		Control: NewController(types.ChainIdType{}, nil),
+		Session: session,
+		Trace: &transaction.TransactionTrace{
+			ActionTraces: []transaction.ActionTrace{
+				{ActionOrdinal: 1, Receiver: alice, Action: transfer},
+				{ActionOrdinal: 2, CreatorActionOrdinal: 1, Receiver: name.StringToName("bob"), Action: transfer},
+			},
+		},
+	}
+	applyContext, err := NewApplyContext(trxContext, 1, 0)
+	assert.NoError(t, err)
+	applyContext.InlineActions = []int{2}
+
+	err = applyContext.Exec()
+	var actionErr *ActionException
+	assert.True(t, errors.As(err, &actionErr))
+	assert.Equal(t, 2, actionErr.ActionOrdinal)
+	assert.Equal(t, actionErr, trxContext.Trace.ActionTraces[1].Except)
+	assert.Nil(t, trxContext.Trace.ActionTraces[0].Except)
+}
+
+func TestDelayedTransactionRamUsage(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	session := state.NewState(nil, db).CreateSession(true)
+	t.Cleanup(session.Discard)
+
+	alice := name.StringToName("alice")
+	assert.NoError(t, session.CreateGlobalPropertyObject(&global.GlobalPropertyObject{}))
+	assert.NoError(t, NewResourceLimitsManager(session).InitializeAccount(alice))
+
+	blockTime := time.TimePointSec(1700000000).ToTimePoint()
+	trxContext := NewTransactionContext(NewController(types.ChainIdType{}, nil), session, nil, *crypto.Hash256("delayed"), state.NewBlock(nil, blockTime, block.BlockHash{}, 2))
+	trxContext.Published = blockTime
+	trxContext.delay = time.Seconds(10)
+
+	// The first authorizer pays for the delayed transaction
+	active := authority.PermissionLevel{Actor: alice, Permission: name.StringToName("active")}
+	assert.NoError(t, trxContext.scheduleTransaction(&transaction.Transaction{
+		Actions: []*transaction.Action{{Account: config.SystemAccountName, Name: name.StringToName("updateauth"), Authorization: []authority.PermissionLevel{active}}},
+	}))
+	generated, err := session.FindGeneratedTransactionByTrxId(trxContext.ID)
+	assert.NoError(t, err)
+	usage, err := session.FindResourceUsageByOwner(alice)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(generated.BillableSize()), usage.RamUsage)
+
+	// Canceling it refunds the payer
+	data, err := rlp.EncodeToBytes(&CancelDelay{CancelingAuth: active, TrxId: trxContext.ID})
+	assert.NoError(t, err)
+	cancel := transaction.Action{Account: config.SystemAccountName, Name: name.StringToName("canceldelay"), Authorization: []authority.PermissionLevel{active}, Data: data}
+	trxContext.Trace.ActionTraces = []transaction.ActionTrace{{ActionOrdinal: 1, Receiver: config.SystemAccountName, Action: cancel}}
+	applyContext, err := NewApplyContext(trxContext, 1, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, applyEosioCancelDelay(applyContext))
+
+	usage, err = session.FindResourceUsageByOwner(alice)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), usage.RamUsage)
+	_, err = session.FindGeneratedTransactionByTrxId(trxContext.ID)
+	assert.ErrorIs(t, err, badger.ErrKeyNotFound)
+}
@@ -0,0 +1,122 @@
+package chain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/MetalBlockchain/antelopevm/chain/time"
+	"github.com/MetalBlockchain/antelopevm/config"
+)
+
+// NetworkUpgrade is a protocol change that every validator applies at the
+// start of the same block, either the block at [BlockHeight] or the first block
+// with a timestamp at or after [Time]. [ActivateFeatures] lists the codenames
+// of builtin protocol features that do not require pre-activation.
+type NetworkUpgrade struct {
+	Name             string              `json:"name"`
+	BlockHeight      uint64              `json:"block_height,omitempty"`
+	Time             time.TimePoint      `json:"time,omitempty"`
+	ChainConfig      *config.ChainConfig `json:"chain_config,omitempty"`
+	ActivateFeatures []string            `json:"activate_features,omitempty"`
+}
+
+// activatesAt reports whether this upgrade takes effect in the block at
+// [height] with timestamp [blockTime] built on a parent with timestamp
+// [parentTime]
+func (u *NetworkUpgrade) activatesAt(height uint64, blockTime time.TimePoint, parentTime time.TimePoint) bool {
+	if u.BlockHeight > 0 {
+		return height == u.BlockHeight
+	}
+
+	return parentTime < u.Time && u.Time <= blockTime
+}
+
+// UpgradeSchedule lists the network upgrades passed to the VM through
+// upgradeData, the schedule has to be the same on every validator
+type UpgradeSchedule struct {
+	Upgrades []NetworkUpgrade `json:"upgrades"`
+}
+
+// ParseUpgradeSchedule parses and validates [data], empty data means no
+// upgrades are scheduled
+func ParseUpgradeSchedule(data []byte) (*UpgradeSchedule, error) {
+	schedule := &UpgradeSchedule{Upgrades: make([]NetworkUpgrade, 0)}
+
+	if len(data) == 0 {
+		return schedule, nil
+	}
+
+	if err := json.Unmarshal(data, schedule); err != nil {
+		return nil, fmt.Errorf("failed to parse upgrade schedule: %w", err)
+	}
+
+	if err := schedule.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid upgrade schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+func (s *UpgradeSchedule) Validate() error {
+	names := make(map[string]struct{}, len(s.Upgrades))
+
+	for _, upgrade := range s.Upgrades {
+		if upgrade.Name == "" {
+			return errors.New("upgrade has no name")
+		}
+
+		if _, ok := names[upgrade.Name]; ok {
+			return fmt.Errorf("upgrade %s is scheduled more than once", upgrade.Name)
+		}
+
+		names[upgrade.Name] = struct{}{}
+
+		if (upgrade.BlockHeight > 0) == (upgrade.Time > 0) {
+			return fmt.Errorf("upgrade %s needs either a block height or a time", upgrade.Name)
+		}
+
+		// The genesis block is never executed
+		if upgrade.BlockHeight == 1 {
+			return fmt.Errorf("upgrade %s cannot activate at the genesis block", upgrade.Name)
+		}
+
+		for _, codename := range upgrade.ActivateFeatures {
+			feature := GetBuiltinProtocolFeatureByName(codename)
+
+			if feature == nil {
+				return fmt.Errorf("upgrade %s activates unknown protocol feature %s", upgrade.Name, codename)
+			}
+
+			if feature.SubjectiveRestrictions.PreactivationRequired {
+				return fmt.Errorf("upgrade %s cannot activate %s as it requires pre-activation", upgrade.Name, codename)
+			}
+		}
+
+		if upgrade.ChainConfig != nil {
+			if err := upgrade.ChainConfig.Validate(); err != nil {
+				return fmt.Errorf("upgrade %s: %w", upgrade.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Activations returns the upgrades taking effect in the block at [height]
+// with timestamp [blockTime], in the order they were scheduled
+func (s *UpgradeSchedule) Activations(height uint64, blockTime time.TimePoint, parentTime time.TimePoint) []NetworkUpgrade {
+	upgrades := make([]NetworkUpgrade, 0)
+
+	if s == nil {
+		return upgrades
+	}
+
+	for _, upgrade := range s.Upgrades {
+		if upgrade.activatesAt(height, blockTime, parentTime) {
+			upgrades = append(upgrades, upgrade)
+		}
+	}
+
+	return upgrades
+}
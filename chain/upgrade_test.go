@@ -0,0 +1,75 @@
+package chain_test
+
+import (
+	"testing"
+
+	"github.com/MetalBlockchain/antelopevm/chain"
+	"github.com/MetalBlockchain/antelopevm/chain/time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUpgradeSchedule(t *testing.T) {
+	schedule, err := chain.ParseUpgradeSchedule(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, schedule.Upgrades)
+
+	schedule, err = chain.ParseUpgradeSchedule([]byte(`{
+		"upgrades": [
+			{"name": "first", "block_height": 100},
+			{"name": "second", "time": "2023-06-01T00:00:00.000"}
+		]
+	}`))
+	assert.NoError(t, err)
+	assert.Len(t, schedule.Upgrades, 2)
+
+	invalid := []string{
+		`{"upgrades": [{"block_height": 100}]}`,
+		`{"upgrades": [{"name": "first"}]}`,
+		`{"upgrades": [{"name": "first", "block_height": 1}]}`,
+		`{"upgrades": [{"name": "first", "block_height": 100, "time": "2023-06-01T00:00:00"}]}`,
+		`{"upgrades": [{"name": "first", "block_height": 100}, {"name": "first", "block_height": 200}]}`,
+		`{"upgrades": [{"name": "first", "block_height": 100, "chain_config": {}}]}`,
+	}
+
+	for _, data := range invalid {
+		_, err := chain.ParseUpgradeSchedule([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func TestUpgradeScheduleActivations(t *testing.T) {
+	activation, err := time.FromIsoString("2023-06-01T00:00:00.000")
+	assert.NoError(t, err)
+	schedule := &chain.UpgradeSchedule{
+		Upgrades: []chain.NetworkUpgrade{
+			{Name: "height", BlockHeight: 100},
+			{Name: "time", Time: activation},
+		},
+	}
+
+	before := activation.SubUs(time.Seconds(1))
+	after := activation.AddUs(time.Seconds(1))
+
+	assert.Empty(t, schedule.Activations(99, before, before))
+	assert.Equal(t, "height", schedule.Activations(100, before, before)[0].Name)
+	assert.Empty(t, schedule.Activations(101, before, before))
+
+	// Only the first block at or after the activation time applies it
+	assert.Equal(t, "time", schedule.Activations(50, activation, before)[0].Name)
+	assert.Equal(t, "time", schedule.Activations(50, after, before)[0].Name)
+	assert.Empty(t, schedule.Activations(51, after, activation))
+
+	var none *chain.UpgradeSchedule
+	assert.Empty(t, none.Activations(100, after, before))
+}
+
+func TestUpgradeScheduleFeatures(t *testing.T) {
+	_, err := chain.ParseUpgradeSchedule([]byte(`{"upgrades": [{"name": "first", "block_height": 2, "activate_features": ["PREACTIVATE_FEATURE"]}]}`))
+	assert.NoError(t, err)
+
+	_, err = chain.ParseUpgradeSchedule([]byte(`{"upgrades": [{"name": "first", "block_height": 2, "activate_features": ["ONLY_LINK_TO_EXISTING_PERMISSION"]}]}`))
+	assert.Error(t, err)
+
+	_, err = chain.ParseUpgradeSchedule([]byte(`{"upgrades": [{"name": "first", "block_height": 2, "activate_features": ["UNKNOWN"]}]}`))
+	assert.Error(t, err)
+}
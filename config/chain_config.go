@@ -6,25 +6,25 @@ import (
 )
 
 type ChainConfig struct {
-	MaxBlockNetUsage               uint64 `json:"max_block_net_usage"`
-	TargetBlockNetUsagePct         uint32 `json:"target_block_net_usage_pct"`
-	MaxTransactionNetUsage         uint32 `json:"max_transaction_net_usage"`
-	BasePerTransactionNetUsage     uint32 `json:"base_per_transaction_net_usage"`
-	NetUsageLeeway                 uint32 `json:"net_usage_leeway"`
-	ContextFreeDiscountNetUsageNum uint32 `json:"context_free_discount_net_usage_num"`
-	ContextFreeDiscountNetUsageDen uint32 `json:"context_free_discount_net_usage_den"`
-
-	MaxBlockCpuUsage       uint32 `json:"max_block_cpu_usage"`
-	TargetBlockCpuUsagePct uint32 `json:"target_block_cpu_usage_pct"`
-	MaxTransactionCpuUsage uint32 `json:"max_transaction_cpu_usage"`
-	MinTransactionCpuUsage uint32 `json:"min_transaction_cpu_usage"`
-
-	MaxTrxLifetime              uint32 `json:"max_transaction_lifetime"`
-	DeferredTrxExpirationWindow uint32 `json:"deferred_trx_expiration_window"`
-	MaxTrxDelay                 uint32 `json:"max_transaction_delay"`
-	MaxInlineActionSize         uint32 `json:"max_inline_action_size"`
-	MaxInlineActionDepth        uint16 `json:"max_inline_action_depth"`
-	MaxAuthorityDepth           uint16 `json:"max_authority_depth"`
+	MaxBlockNetUsage               uint64 `serialize:"true" json:"max_block_net_usage"`
+	TargetBlockNetUsagePct         uint32 `serialize:"true" json:"target_block_net_usage_pct"`
+	MaxTransactionNetUsage         uint32 `serialize:"true" json:"max_transaction_net_usage"`
+	BasePerTransactionNetUsage     uint32 `serialize:"true" json:"base_per_transaction_net_usage"`
+	NetUsageLeeway                 uint32 `serialize:"true" json:"net_usage_leeway"`
+	ContextFreeDiscountNetUsageNum uint32 `serialize:"true" json:"context_free_discount_net_usage_num"`
+	ContextFreeDiscountNetUsageDen uint32 `serialize:"true" json:"context_free_discount_net_usage_den"`
+
+	MaxBlockCpuUsage       uint32 `serialize:"true" json:"max_block_cpu_usage"`
+	TargetBlockCpuUsagePct uint32 `serialize:"true" json:"target_block_cpu_usage_pct"`
+	MaxTransactionCpuUsage uint32 `serialize:"true" json:"max_transaction_cpu_usage"`
+	MinTransactionCpuUsage uint32 `serialize:"true" json:"min_transaction_cpu_usage"`
+
+	MaxTrxLifetime              uint32 `serialize:"true" json:"max_transaction_lifetime"`
+	DeferredTrxExpirationWindow uint32 `serialize:"true" json:"deferred_trx_expiration_window"`
+	MaxTrxDelay                 uint32 `serialize:"true" json:"max_transaction_delay"`
+	MaxInlineActionSize         uint32 `serialize:"true" json:"max_inline_action_size"`
+	MaxInlineActionDepth        uint16 `serialize:"true" json:"max_inline_action_depth"`
+	MaxAuthorityDepth           uint16 `serialize:"true" json:"max_authority_depth"`
 }
 
 // TODO: Add validation logic
@@ -37,7 +37,17 @@ var (
 
 	RateLimitingPrecision uint64 = 1000 * 1000
 
+	// Resource windows
+	BlockCpuUsageAverageWindowMs     uint32 = 60 * 1000
+	BlockSizeAverageWindowMs         uint32 = 60 * 1000
+	MaximumElasticResourceMultiplier uint32 = 1000
+	AccountCpuUsageAverageWindowMs   uint32 = 24 * 60 * 60 * 1000
+	AccountNetUsageAverageWindowMs   uint32 = 24 * 60 * 60 * 1000
+
 	MinNetUsageDeltaBetweenBaseAndMaxForTrx uint32 = 10 * 1024
+	ActionNetUsageOverhead                  uint64 = 16 ///< net usage billed for every action on top of its packed size
+	FixedNetOverheadOfPackedTrx             uint32 = 16 ///< net usage billed for every packed transaction on top of its size
+	TransactionIdNetUsage                   uint64 = 32 ///< net usage billed to retire a deferred transaction on top of the base
 
 	// Wasm parameters
 	DefaultMaxWasmMutableGlobalBytes uint32 = 1024
@@ -4,17 +4,17 @@ import "fmt"
 
 //go:generate msgp
 type WasmConfig struct {
-	MaxMutableGlobalBytes uint32
-	MaxTableElements      uint32
-	MaxSectionElements    uint32
-	MaxLinearMemoryInit   uint32
-	MaxFuncLocalBytes     uint32
-	MaxNestedStructures   uint32
-	MaxSymbolBytes        uint32
-	MaxModuleBytes        uint32
-	MaxCodeBytes          uint32
-	MaxPages              uint32
-	MaxCallDepth          uint32
+	MaxMutableGlobalBytes uint32 `serialize:"true"`
+	MaxTableElements      uint32 `serialize:"true"`
+	MaxSectionElements    uint32 `serialize:"true"`
+	MaxLinearMemoryInit   uint32 `serialize:"true"`
+	MaxFuncLocalBytes     uint32 `serialize:"true"`
+	MaxNestedStructures   uint32 `serialize:"true"`
+	MaxSymbolBytes        uint32 `serialize:"true"`
+	MaxModuleBytes        uint32 `serialize:"true"`
+	MaxCodeBytes          uint32 `serialize:"true"`
+	MaxPages              uint32 `serialize:"true"`
+	MaxCallDepth          uint32 `serialize:"true"`
 }
 
 func DefaultInitialWasmConfiguration() WasmConfig {
@@ -9,8 +9,8 @@ import (
 
 //go:generate msgp
 type Uint128 struct {
-	Low  uint64
-	High uint64
+	Low  uint64 `serialize:"true"`
+	High uint64 `serialize:"true"`
 }
 
 type Uint128Bytes struct {
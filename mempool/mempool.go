@@ -6,6 +6,7 @@ import (
 
 	"github.com/MetalBlockchain/antelopevm/chain/time"
 	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/MetalBlockchain/antelopevm/metrics"
 	log "github.com/inconshreveable/log15"
 )
 
@@ -13,6 +14,7 @@ type Mempool struct {
 	mu      sync.RWMutex
 	maxSize int
 	heap    *txHeap
+	metrics *metrics.Metrics
 
 	// Pending is a channel of length one, which the mempool ensures has an item on
 	// it as long as there is an unissued transaction remaining in [txs]
@@ -21,10 +23,11 @@ type Mempool struct {
 	newTxs []*transaction.PackedTransaction
 }
 
-func New(maxSize int) *Mempool {
+func New(maxSize int, metrics *metrics.Metrics) *Mempool {
 	return &Mempool{
 		maxSize: maxSize,
 		heap:    newTxHeap(maxSize),
+		metrics: metrics,
 		Pending: make(chan struct{}, 1),
 	}
 }
@@ -45,17 +48,26 @@ func (th *Mempool) Add(tx *transaction.PackedTransaction) bool {
 		return false
 	}
 
+	id := *tx.UnpackedTrx.ID()
 	oldLen := th.heap.Len()
 
 	heap.Push(th.heap, &txEntry{
-		id:      *tx.UnpackedTrx.ID(),
+		id:      id,
 		created: time.Now(),
 		tx:      tx,
 		index:   oldLen,
 	})
 
 	if th.heap.Len() > th.maxSize {
-		th.popMin()
+		th.removeNewTx(th.popMin())
+		th.metrics.MempoolEviction()
+	}
+
+	th.metrics.SetMempoolSize(th.heap.Len())
+
+	// Evicted transactions are neither gossiped nor included in a block
+	if !th.heap.Has(id) {
+		return false
 	}
 
 	// When adding [tx] to the mempool make sure that there is an item in Pending
@@ -74,7 +86,10 @@ func (th *Mempool) Pop() *transaction.PackedTransaction { // O(log N)
 	defer th.mu.Unlock()
 
 	item := th.heap.items[0]
-	return th.remove(item.id)
+	tx := th.remove(item.id)
+	th.metrics.SetMempoolSize(th.heap.Len())
+
+	return tx
 }
 
 func (th *Mempool) Peek() *transaction.PackedTransaction {
@@ -86,6 +101,22 @@ func (th *Mempool) Peek() *transaction.PackedTransaction {
 	return txEntry
 }
 
+// NewTxs returns up to [maxTxs] transactions that were added since the last
+// call, these are the transactions that still have to be gossiped
+func (th *Mempool) NewTxs(maxTxs int) []*transaction.PackedTransaction {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	if len(th.newTxs) < maxTxs {
+		maxTxs = len(th.newTxs)
+	}
+
+	selected := th.newTxs[:maxTxs]
+	th.newTxs = th.newTxs[maxTxs:]
+
+	return selected
+}
+
 func (th *Mempool) Len() int {
 	th.mu.RLock()
 	defer th.mu.RUnlock()
@@ -113,6 +144,17 @@ func (th *Mempool) remove(id transaction.TransactionIdType) *transaction.PackedT
 	return entry.tx
 }
 
+// removeNewTx drops [tx] from the transactions that still have to be gossiped,
+// it assumes the write lock is held
+func (th *Mempool) removeNewTx(tx *transaction.PackedTransaction) {
+	for i, newTx := range th.newTxs {
+		if newTx == tx {
+			th.newTxs = append(th.newTxs[:i], th.newTxs[i+1:]...)
+			return
+		}
+	}
+}
+
 func (th *Mempool) addPending() {
 	select {
 	case th.Pending <- struct{}{}:
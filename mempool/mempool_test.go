@@ -0,0 +1,40 @@
+package mempool
+
+import (
+	"testing"
+	gotime "time"
+
+	"github.com/MetalBlockchain/antelopevm/chain/time"
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTransaction(t *testing.T, expiration time.TimePointSec) *transaction.PackedTransaction {
+	trx := &transaction.Transaction{TransactionHeader: transaction.TransactionHeader{Expiration: expiration}}
+	packedTrx, err := transaction.NewPackedTransactionFromSignedTransaction(*transaction.NewSignedTransaction(trx, nil, nil), transaction.CompressionNone)
+	assert.NoError(t, err)
+
+	return packedTrx
+}
+
+func TestMempoolEviction(t *testing.T) {
+	mempool := New(1, nil)
+	first, second := newTestTransaction(t, 1), newTestTransaction(t, 2)
+
+	assert.True(t, mempool.Add(first))
+	gotime.Sleep(gotime.Millisecond)
+	assert.True(t, mempool.Add(second))
+
+	// The oldest transaction makes room and is not gossiped anymore
+	assert.Equal(t, 1, mempool.Len())
+	assert.Equal(t, []*transaction.PackedTransaction{second}, mempool.NewTxs(10))
+}
+
+func TestMempoolFull(t *testing.T) {
+	mempool := New(0, nil)
+
+	// A transaction evicted right away is not kept for gossip
+	assert.False(t, mempool.Add(newTestTransaction(t, 1)))
+	assert.Equal(t, 0, mempool.Len())
+	assert.Empty(t, mempool.NewTxs(10))
+}
@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/MetalBlockchain/metalgo/utils/wrappers"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "antelopevm"
+
+// Metrics holds the collectors of the VM. All methods can be called on a nil
+// *Metrics, which makes it easy to leave instrumentation out in tests.
+type Metrics struct {
+	blocksBuilt    prometheus.Counter
+	blocksVerified prometheus.Counter
+	blocksAccepted prometheus.Counter
+	blocksRejected prometheus.Counter
+
+	transactionsExecuted prometheus.Counter
+	transactionsFailed   prometheus.Counter
+
+	actionExecutionTime   prometheus.Histogram
+	wasmInstantiationTime prometheus.Histogram
+
+	mempoolSize      prometheus.Gauge
+	mempoolEvictions prometheus.Counter
+
+	databaseConflicts prometheus.Counter
+
+	apiRequestLatency *prometheus.HistogramVec
+}
+
+// New creates the collectors and registers them on [registerer]
+func New(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		blocksBuilt: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "blocks_built",
+			Help:      "Number of blocks built by this node",
+		}),
+		blocksVerified: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "blocks_verified",
+			Help:      "Number of blocks that passed verification",
+		}),
+		blocksAccepted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "blocks_accepted",
+			Help:      "Number of blocks accepted by consensus",
+		}),
+		blocksRejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "blocks_rejected",
+			Help:      "Number of blocks rejected by consensus",
+		}),
+		transactionsExecuted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transactions_executed",
+			Help:      "Number of transactions that executed successfully",
+		}),
+		transactionsFailed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transactions_failed",
+			Help:      "Number of transactions that failed to execute",
+		}),
+		// Accounts and actions are picked by users, labelling with them would
+		// let anyone create as many series as they like
+		actionExecutionTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "action_execution_time_us",
+			Help:      "Time spent executing an action in microseconds",
+			Buckets:   prometheus.ExponentialBuckets(10, 2, 14),
+		}),
+		wasmInstantiationTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "wasm_instantiation_time_us",
+			Help:      "Time spent compiling and instantiating a WASM module in microseconds",
+			Buckets:   prometheus.ExponentialBuckets(10, 2, 16),
+		}),
+		mempoolSize: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "mempool_size",
+			Help:      "Number of transactions in the mempool",
+		}),
+		mempoolEvictions: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mempool_evictions",
+			Help:      "Number of transactions evicted from a full mempool",
+		}),
+		databaseConflicts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "database_conflicts",
+			Help:      "Number of Badger transactions that failed to commit because of a conflict",
+		}),
+		apiRequestLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "api_request_latency_ms",
+			Help:      "Latency of API requests in milliseconds",
+			Buckets:   prometheus.ExponentialBuckets(0.5, 2, 14),
+		}, []string{"path"}),
+	}
+
+	errs := wrappers.Errs{}
+	errs.Add(
+		registerer.Register(m.blocksBuilt),
+		registerer.Register(m.blocksVerified),
+		registerer.Register(m.blocksAccepted),
+		registerer.Register(m.blocksRejected),
+		registerer.Register(m.transactionsExecuted),
+		registerer.Register(m.transactionsFailed),
+		registerer.Register(m.actionExecutionTime),
+		registerer.Register(m.wasmInstantiationTime),
+		registerer.Register(m.mempoolSize),
+		registerer.Register(m.mempoolEvictions),
+		registerer.Register(m.databaseConflicts),
+		registerer.Register(m.apiRequestLatency),
+	)
+
+	return m, errs.Err
+}
+
+func (m *Metrics) BlockBuilt() {
+	if m != nil {
+		m.blocksBuilt.Inc()
+	}
+}
+
+func (m *Metrics) BlockVerified() {
+	if m != nil {
+		m.blocksVerified.Inc()
+	}
+}
+
+func (m *Metrics) BlockAccepted() {
+	if m != nil {
+		m.blocksAccepted.Inc()
+	}
+}
+
+func (m *Metrics) BlockRejected() {
+	if m != nil {
+		m.blocksRejected.Inc()
+	}
+}
+
+func (m *Metrics) TransactionExecuted() {
+	if m != nil {
+		m.transactionsExecuted.Inc()
+	}
+}
+
+func (m *Metrics) TransactionFailed() {
+	if m != nil {
+		m.transactionsFailed.Inc()
+	}
+}
+
+func (m *Metrics) ObserveActionExecution(duration time.Duration) {
+	if m != nil {
+		m.actionExecutionTime.Observe(float64(duration.Microseconds()))
+	}
+}
+
+func (m *Metrics) ObserveWasmInstantiation(duration time.Duration) {
+	if m != nil {
+		m.wasmInstantiationTime.Observe(float64(duration.Microseconds()))
+	}
+}
+
+func (m *Metrics) SetMempoolSize(size int) {
+	if m != nil {
+		m.mempoolSize.Set(float64(size))
+	}
+}
+
+func (m *Metrics) MempoolEviction() {
+	if m != nil {
+		m.mempoolEvictions.Inc()
+	}
+}
+
+func (m *Metrics) DatabaseConflict() {
+	if m != nil {
+		m.databaseConflicts.Inc()
+	}
+}
+
+func (m *Metrics) ObserveAPIRequest(path string, duration time.Duration) {
+	if m != nil {
+		m.apiRequestLatency.WithLabelValues(path).Observe(float64(duration) / float64(time.Millisecond))
+	}
+}
@@ -0,0 +1,30 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/MetalBlockchain/antelopevm/chain/account"
+	"github.com/MetalBlockchain/antelopevm/crypto"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindCodeObjectByCodeHash(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	assert.NoError(t, err)
+	state := NewState(nil, db)
+	session := state.CreateSession(true)
+	defer session.Discard()
+
+	code := []byte{0x00, 0x61, 0x73, 0x6d}
+	codeHash := *crypto.Hash256(code)
+	assert.NoError(t, session.CreateCodeObject(&account.CodeObject{CodeHash: codeHash, Code: code, CodeRefCount: 1}))
+
+	found, err := session.FindCodeObjectByCodeHash(codeHash, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, codeHash, found.CodeHash)
+
+	// Code is stored per VM type and version
+	_, err = session.FindCodeObjectByCodeHash(codeHash, 0, 1)
+	assert.Equal(t, badger.ErrKeyNotFound, err)
+}
@@ -2,13 +2,19 @@ package state
 
 import (
 	"context"
+	"errors"
 
 	"github.com/MetalBlockchain/antelopevm/chain/time"
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
 	"github.com/MetalBlockchain/metalgo/ids"
 	"github.com/MetalBlockchain/metalgo/snow/consensus/snowman"
 	log "github.com/inconshreveable/log15"
 )
 
+// ErrBlockFull is wrapped by the errors of transactions that did not fit in the
+// CPU or NET left in the block, they are kept for a later block
+var ErrBlockFull = errors.New("block is full")
+
 func BuildBlock(vm VM, preferred ids.ID) (snowman.Block, error) {
 	parent, err := vm.GetStoredBlock(context.Background(), preferred)
 
@@ -16,17 +22,54 @@ func BuildBlock(vm VM, preferred ids.ID) (snowman.Block, error) {
 		return nil, err
 	}
 
-	session := vm.State().CreateSession(true)
+	// Execute on top of the preferred block, the changes are thrown away as the
+	// block is executed again when it gets verified
+	session := vm.State().CreateSpeculativeSession(parent)
 	defer session.Discard()
 	mempool := vm.GetMempool()
 	block := NewBlock(vm, time.Now(), parent.Hash, uint64(parent.Header.BlockNum())+1)
 
+	// Block timestamps have to be strictly increasing, use the next slot after
+	// the parent when the clock has not advanced far enough
+	if block.Header.Timestamp <= parent.Header.Timestamp {
+		block.Header.Timestamp = parent.Header.Timestamp + 1
+	}
+
+	if err := vm.StartBlock(block, parent, session); err != nil {
+		return nil, err
+	}
+
+	scheduled, err := vm.ScheduledTransactions(block, session)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range scheduled {
+		trace, err := vm.ExecuteScheduledTransaction(id, 0, block, session)
+
+		if err != nil {
+			log.Error("failed to execute scheduled transaction", "id", id, "error", err)
+			continue
+		}
+
+		block.ScheduledTransactions = append(block.ScheduledTransactions, transaction.ScheduledTransactionReceipt{
+			TransactionReceiptHeader: trace.Receipt.TransactionReceiptHeader,
+			TrxId:                    id,
+		})
+	}
+
 	for mempool.Len() > 0 {
 		next := mempool.Pop()
-		receipt, err := vm.ExecuteTransaction(next, block, session)
+		receipt, err := vm.ExecuteTransaction(next, 0, block, session)
+
+		if errors.Is(err, ErrBlockFull) {
+			mempool.Add(next)
+			break
+		}
 
 		if err != nil {
-			id, err := next.ID()
+			id, _ := next.ID()
 			log.Error("failed to execute transaction", "id", id, "error", err)
 			continue
 		}
@@ -34,6 +77,18 @@ func BuildBlock(vm VM, preferred ids.ID) (snowman.Block, error) {
 		block.Transactions = append(block.Transactions, receipt.Receipt)
 	}
 
+	if err := vm.FinalizeBlock(block, session); err != nil {
+		return nil, err
+	}
+
+	merkleRoot, err := CalculateTransactionMerkle(block.ScheduledTransactions, block.Transactions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block.Header.TransactionMerkleRoot = *merkleRoot
+
 	// Calculate hash of this block at the end
 	block.Finalize()
 
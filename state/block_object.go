@@ -12,25 +12,35 @@ import (
 	"github.com/MetalBlockchain/antelopevm/chain/transaction"
 	"github.com/MetalBlockchain/antelopevm/chain/types"
 	"github.com/MetalBlockchain/antelopevm/crypto"
+	"github.com/MetalBlockchain/antelopevm/utils"
 	"github.com/MetalBlockchain/metalgo/ids"
 	"github.com/MetalBlockchain/metalgo/snow/choices"
 	"github.com/MetalBlockchain/metalgo/snow/consensus/snowman"
 	log "github.com/inconshreveable/log15"
 )
 
+// maxFutureBlockTime is how far ahead of the local clock a block timestamp is
+// allowed to be before the block is considered invalid
+const maxFutureBlockTime = 10 * time.Second
+
 var (
 	_ snowman.Block = &Block{}
 	_ entity.Entity = &Block{}
 )
 
 type Block struct {
-	Index           types.IdType                     `serialize:"true"`
-	Hash            block.BlockHash                  `serialize:"true"`
-	Header          block.BlockHeader                `serialize:"true"`
-	Transactions    []transaction.TransactionReceipt `serialize:"true"`
-	BlockExtensions []types.Extension                `serialize:"true"`
-	BlockStatus     block.BlockStatus                `serialize:"true"`
-	vm              VM
+	Index                 types.IdType                              `serialize:"true"`
+	Hash                  block.BlockHash                           `serialize:"true"`
+	Header                block.BlockHeader                         `serialize:"true"`
+	Transactions          []transaction.TransactionReceipt          `serialize:"true"`
+	ScheduledTransactions []transaction.ScheduledTransactionReceipt `serialize:"true"`
+	BlockExtensions       []types.Extension                         `serialize:"true"`
+	BlockStatus           block.BlockStatus                         `serialize:"true"`
+	vm                    VM
+
+	// Uncommitted state changes made by this block, only set between
+	// verification and acceptance
+	layer *stateLayer
 }
 
 func NewBlock(vm VM, timestamp chainTime.TimePoint, parent block.BlockHash, height uint64) *Block {
@@ -39,11 +49,12 @@ func NewBlock(vm VM, timestamp chainTime.TimePoint, parent block.BlockHash, heig
 			Timestamp: block.NewBlockTimeStampFromTimePoint(timestamp),
 			Producer:  name.StringToName("eosio"),
 			Confirmed: 1,
-			Previous:  *crypto.Hash256(parent),
+			Previous:  *crypto.NewSha256Byte(parent[:]),
 		},
-		Transactions: make([]transaction.TransactionReceipt, 0),
-		vm:           vm,
-		BlockStatus:  block.BlockStatusProcessing,
+		Transactions:          make([]transaction.TransactionReceipt, 0),
+		ScheduledTransactions: make([]transaction.ScheduledTransactionReceipt, 0),
+		vm:                    vm,
+		BlockStatus:           block.BlockStatusProcessing,
 	}
 }
 
@@ -52,28 +63,89 @@ func (b *Block) Initialize(vm VM) {
 }
 
 // Verify returns nil iff this block is valid.
-// To be valid, it must be that:
-// b.parent.Timestamp < b.Timestamp <= [local time] + 1 hour
+// To be valid, its timestamp must be later than the timestamp of its parent,
+// its transaction merkle root must match its receipts and all transactions in
+// this block must execute on top of the state of its parent with the same
+// outcome as recorded in their receipts. The CPU time billed is taken from the
+// receipts so every node ends up with the same state. The resulting state
+// changes are kept in memory until this block is accepted or rejected.
 func (b *Block) Verify(ctx context.Context) error {
 	log.Debug("verifying block", "block", b)
 
-	session := b.vm.State().CreateSession(true)
+	if err := b.VerifyHash(); err != nil {
+		return err
+	}
+
+	parent, err := b.vm.GetStoredBlock(ctx, b.Parent())
+
+	if err != nil {
+		return fmt.Errorf("could not find parent block %s: %w", b.Parent(), err)
+	}
+
+	if b.Header.Timestamp <= parent.Header.Timestamp {
+		return fmt.Errorf("block timestamp %s is not later than parent timestamp %s", b.Header.Timestamp.ToTimePoint(), parent.Header.Timestamp.ToTimePoint())
+	}
+
+	if maxTime := time.Now().Add(maxFutureBlockTime); b.Timestamp().After(maxTime) {
+		return fmt.Errorf("block timestamp %s is too far in the future", b.Header.Timestamp.ToTimePoint())
+	}
+
+	merkleRoot, err := CalculateTransactionMerkle(b.ScheduledTransactions, b.Transactions)
+
+	if err != nil {
+		return err
+	}
+
+	if !merkleRoot.Equals(b.Header.TransactionMerkleRoot) {
+		return fmt.Errorf("transaction merkle root %s does not match %s", b.Header.TransactionMerkleRoot, merkleRoot)
+	}
+
+	session := b.vm.State().CreateSpeculativeSession(parent)
 	defer session.Discard()
 
+	if err := b.vm.StartBlock(b, parent, session); err != nil {
+		return err
+	}
+
+	// Deferred transactions are retired before any input transaction
+	for _, trx := range b.ScheduledTransactions {
+		trace, err := b.vm.ExecuteScheduledTransaction(trx.TrxId, trx.CpuUsageUs, b, session)
+
+		if err != nil {
+			return fmt.Errorf("block contains scheduled transaction that failed: %w", err)
+		}
+
+		if trace.Receipt.TransactionReceiptHeader != trx.TransactionReceiptHeader {
+			return fmt.Errorf("receipt of scheduled transaction %s does not match, expected %v but got %v", trace.Hash, trx.TransactionReceiptHeader, trace.Receipt.TransactionReceiptHeader)
+		}
+
+		if err := session.CreateTransaction(trace); err != nil {
+			return err
+		}
+	}
+
 	for _, trx := range b.Transactions {
-		if trace, err := b.vm.ExecuteTransaction(&trx.Transaction, b, session); err != nil {
-			return fmt.Errorf("block contains transaction that failed")
-		} else {
-			if err := session.CreateTransaction(trace); err != nil {
-				return err
-			}
+		trace, err := b.vm.ExecuteTransaction(&trx.Transaction, trx.CpuUsageUs, b, session)
+
+		if err != nil {
+			return fmt.Errorf("block contains transaction that failed: %w", err)
+		}
+
+		if trace.Receipt.TransactionReceiptHeader != trx.TransactionReceiptHeader {
+			return fmt.Errorf("receipt of transaction %s does not match, expected %v but got %v", trace.Hash, trx.TransactionReceiptHeader, trace.Receipt.TransactionReceiptHeader)
+		}
+
+		if err := session.CreateTransaction(trace); err != nil {
+			return err
 		}
 	}
 
-	if err := session.Commit(); err != nil {
+	if err := b.vm.FinalizeBlock(b, session); err != nil {
 		return err
 	}
 
+	b.layer = session.transaction.layer
+
 	return b.vm.Verified(b)
 }
 
@@ -85,9 +157,12 @@ func (b *Block) Accept(ctx context.Context) error {
 	return b.vm.Accepted(b)
 }
 
-// Reject sets this block's status to Rejected and saves the status in state
-// Recall that b.vm.DB.Commit() must be called to persist to the DB
+// Reject sets this block's status to Rejected and drops the state changes it
+// made, blocks built on top of this block will be rejected as well
 func (b *Block) Reject(ctx context.Context) error {
+	b.SetStatus(choices.Rejected)
+	b.layer = nil
+
 	return b.vm.Rejected(b)
 }
 
@@ -139,9 +214,50 @@ func (b *Block) SetStatus(status choices.Status) {
 	}
 }
 
+// CalculateTransactionMerkle returns the merkle root of the digests of
+// [scheduled] followed by those of [trxs], the order they are executed in
+func CalculateTransactionMerkle(scheduled []transaction.ScheduledTransactionReceipt, trxs []transaction.TransactionReceipt) (*crypto.Sha256, error) {
+	digests := make([]crypto.Sha256, 0, len(scheduled)+len(trxs))
+
+	for _, trx := range scheduled {
+		digest, err := trx.Digest()
+
+		if err != nil {
+			return nil, err
+		}
+
+		digests = append(digests, *digest)
+	}
+
+	for _, trx := range trxs {
+		digest, err := trx.Digest()
+
+		if err != nil {
+			return nil, err
+		}
+
+		digests = append(digests, *digest)
+	}
+
+	merkle := utils.Merkle(digests)
+
+	return &merkle, nil
+}
+
+// Finalize calculates the ID of this block, like Antelope the block number is
+// encoded in the first 4 bytes of the ID
 func (b *Block) Finalize() {
-	digest := crypto.Hash256(b.Header)
-	b.Hash = block.BlockHash(digest.FixedBytes())
+	b.Hash = block.BlockHash(b.Header.CalculateId().FixedBytes())
+}
+
+// VerifyHash checks that the ID of this block is the one calculated from its
+// header
+func (b *Block) VerifyHash() error {
+	if id := block.BlockHash(b.Header.CalculateId().FixedBytes()); id != b.Hash {
+		return fmt.Errorf("block id %s does not match id %s of its header", ids.ID(b.Hash), ids.ID(id))
+	}
+
+	return nil
 }
 
 func (b Block) GetId() []byte {
@@ -0,0 +1,42 @@
+package state
+
+import (
+	"github.com/MetalBlockchain/antelopevm/chain/block"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
+)
+
+func (s *Session) FindBlockSummaryObject(id types.IdType) (*block.BlockSummaryObject, error) {
+	key := getObjectKeyByIndex(&block.BlockSummaryObject{ID: id}, "id")
+	item, err := s.transaction.Get(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := item.ValueCopy(nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := &block.BlockSummaryObject{}
+	if _, err := Codec.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// CreateBlockSummaryObject stores [in] under the ID it already has, the
+// summaries are addressed by block number instead of an increasing ID
+func (s *Session) CreateBlockSummaryObject(in *block.BlockSummaryObject) error {
+	return s.create(false, nil, in)
+}
+
+func (s *Session) ModifyBlockSummaryObject(in *block.BlockSummaryObject, modifyFunc func()) error {
+	if err := s.modify(in, modifyFunc); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package state
+
+import (
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
+	"github.com/MetalBlockchain/antelopevm/math"
+)
+
+func (s *Session) FindGeneratedTransaction(id types.IdType) (*transaction.GeneratedTransactionObject, error) {
+	key := getObjectKeyByIndex(&transaction.GeneratedTransactionObject{ID: id}, "id")
+	item, err := s.transaction.Get(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := item.ValueCopy(nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := &transaction.GeneratedTransactionObject{}
+	if _, err := Codec.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (s *Session) FindGeneratedTransactionByTrxId(trxId transaction.TransactionIdType) (*transaction.GeneratedTransactionObject, error) {
+	key := getObjectKeyByIndex(&transaction.GeneratedTransactionObject{TrxId: trxId}, "byTrxId")
+	item, err := s.transaction.Get(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := item.ValueCopy(nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.FindGeneratedTransaction(types.NewIdType(data))
+}
+
+func (s *Session) FindGeneratedTransactionBySenderId(sender name.AccountName, senderId math.Uint128) (*transaction.GeneratedTransactionObject, error) {
+	key := getObjectKeyByIndex(&transaction.GeneratedTransactionObject{Sender: sender, SenderId: senderId}, "bySenderId")
+	item, err := s.transaction.Get(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := item.ValueCopy(nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.FindGeneratedTransaction(types.NewIdType(data))
+}
+
+// FindGeneratedTransactionsByDelay iterates over the deferred transactions
+// ordered by the time they become executable, the earliest first
+func (s *Session) FindGeneratedTransactionsByDelay() *Iterator[transaction.GeneratedTransactionObject] {
+	key := getPartialKey("byDelay", &transaction.GeneratedTransactionObject{})
+
+	return newIterator(s, key, func(b []byte) (*transaction.GeneratedTransactionObject, error) {
+		return s.FindGeneratedTransaction(types.NewIdType(b))
+	})
+}
+
+func (s *Session) CreateGeneratedTransaction(in *transaction.GeneratedTransactionObject) error {
+	return s.create(true, func(id types.IdType) error {
+		in.ID = id
+		return nil
+	}, in)
+}
+
+func (s *Session) RemoveGeneratedTransaction(in *transaction.GeneratedTransactionObject) error {
+	return s.remove(in)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/MetalBlockchain/antelopevm/chain/time"
 	"github.com/MetalBlockchain/antelopevm/chain/transaction"
 	"github.com/MetalBlockchain/antelopevm/chain/types"
+	"github.com/MetalBlockchain/antelopevm/math"
 )
 
 func getPartialKey(index string, obj entity.Entity, values ...interface{}) []byte {
@@ -66,6 +67,8 @@ func encodeType(obj interface{}) []byte {
 		return v.Pack()
 	case block.BlockHash:
 		return v[:]
+	case uint8:
+		return []byte{v}
 	case uint64:
 		return types.IdType(v).ToBytes()
 	case bool:
@@ -78,6 +81,15 @@ func encodeType(obj interface{}) []byte {
 		a := make([]byte, 4)
 		binary.BigEndian.PutUint32(a, uint32(v))
 		return a
+	case time.TimePoint:
+		a := make([]byte, 8)
+		binary.BigEndian.PutUint64(a, uint64(v))
+		return a
+	case math.Uint128:
+		a := make([]byte, 16)
+		binary.BigEndian.PutUint64(a, v.High)
+		binary.BigEndian.PutUint64(a[8:], v.Low)
+		return a
 	default:
 		panic(fmt.Sprintf("type %v not supported", reflect.TypeOf(obj)))
 	}
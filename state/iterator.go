@@ -3,7 +3,7 @@ package state
 import "github.com/dgraph-io/badger/v3"
 
 type Iterator[T any] struct {
-	iterator   *badger.Iterator
+	iterator   *layeredIterator
 	opts       badger.IteratorOptions
 	lookupFunc func([]byte) (*T, error)
 }
@@ -51,6 +51,14 @@ func (s *Session) FindPermissionsByOwner(owner name.AccountName) *Iterator[autho
 	})
 }
 
+func (s *Session) FindPermissionsByName(name name.PermissionName) *Iterator[authority.Permission] {
+	key := getPartialKey("byName", &authority.Permission{}, name)
+
+	return newIterator(s, key, func(b []byte) (*authority.Permission, error) {
+		return s.FindPermission(types.NewIdType(b))
+	})
+}
+
 func (s *Session) CreatePermission(in *authority.Permission) error {
 	return s.create(true, func(id types.IdType) error {
 		in.ID = id
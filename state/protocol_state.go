@@ -0,0 +1,49 @@
+package state
+
+import (
+	"github.com/MetalBlockchain/antelopevm/chain/protocol"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
+)
+
+func (s *Session) FindProtocolStateObject(id types.IdType) (*protocol.ProtocolStateObject, error) {
+	key := getObjectKeyByIndex(&protocol.ProtocolStateObject{ID: id}, "id")
+	item, err := s.transaction.Get(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := item.ValueCopy(nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := &protocol.ProtocolStateObject{}
+	if _, err := Codec.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (s *Session) CreateProtocolStateObject(in *protocol.ProtocolStateObject) error {
+	err := s.create(true, func(id types.IdType) error {
+		in.ID = id
+		return nil
+	}, in)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Session) ModifyProtocolStateObject(in *protocol.ProtocolStateObject, modifyFunc func()) error {
+	if err := s.modify(in, modifyFunc); err != nil {
+		return err
+	}
+
+	return nil
+}
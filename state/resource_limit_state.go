@@ -49,6 +49,14 @@ func (s *Session) FindResourceLimitsByOwner(pending bool, name name.AccountName)
 	return s.FindResourceLimits(types.NewIdType(data))
 }
 
+func (s *Session) FindPendingResourceLimits() *Iterator[resource.ResourceLimits] {
+	key := getPartialKey("byOwner", &resource.ResourceLimits{}, true)
+
+	return newIterator(s, key, func(b []byte) (*resource.ResourceLimits, error) {
+		return s.FindResourceLimits(types.NewIdType(b))
+	})
+}
+
 func (s *Session) CreateResourceLimits(in *resource.ResourceLimits) error {
 	err := s.create(true, func(id types.IdType) error {
 		in.ID = id
@@ -73,3 +81,13 @@ func (s *Session) ModifyResourceLimits(in *resource.ResourceLimits, modifyFunc f
 
 	return nil
 }
+
+func (s *Session) RemoveResourceLimits(in *resource.ResourceLimits) error {
+	if err := s.remove(in); err != nil {
+		return err
+	}
+
+	s.resourceLimitsCache.Evict(in.ID)
+
+	return nil
+}
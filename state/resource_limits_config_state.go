@@ -0,0 +1,49 @@
+package state
+
+import (
+	"github.com/MetalBlockchain/antelopevm/chain/resource"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
+)
+
+func (s *Session) FindResourceLimitsConfigObject(id types.IdType) (*resource.ResourceLimitsConfigObject, error) {
+	key := getObjectKeyByIndex(&resource.ResourceLimitsConfigObject{ID: id}, "id")
+	item, err := s.transaction.Get(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := item.ValueCopy(nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := &resource.ResourceLimitsConfigObject{}
+	if _, err := Codec.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (s *Session) CreateResourceLimitsConfigObject(in *resource.ResourceLimitsConfigObject) error {
+	err := s.create(true, func(id types.IdType) error {
+		in.ID = id
+		return nil
+	}, in)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Session) ModifyResourceLimitsConfigObject(in *resource.ResourceLimitsConfigObject, modifyFunc func()) error {
+	if err := s.modify(in, modifyFunc); err != nil {
+		return err
+	}
+
+	return nil
+}
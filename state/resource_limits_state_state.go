@@ -0,0 +1,49 @@
+package state
+
+import (
+	"github.com/MetalBlockchain/antelopevm/chain/resource"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
+)
+
+func (s *Session) FindResourceLimitsStateObject(id types.IdType) (*resource.ResourceLimitsStateObject, error) {
+	key := getObjectKeyByIndex(&resource.ResourceLimitsStateObject{ID: id}, "id")
+	item, err := s.transaction.Get(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := item.ValueCopy(nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := &resource.ResourceLimitsStateObject{}
+	if _, err := Codec.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (s *Session) CreateResourceLimitsStateObject(in *resource.ResourceLimitsStateObject) error {
+	err := s.create(true, func(id types.IdType) error {
+		in.ID = id
+		return nil
+	}, in)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Session) ModifyResourceLimitsStateObject(in *resource.ResourceLimitsStateObject, modifyFunc func()) error {
+	if err := s.modify(in, modifyFunc); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package state
 
 import (
 	"encoding/binary"
+	"fmt"
 
 	"github.com/MetalBlockchain/antelopevm/chain/account"
 	"github.com/MetalBlockchain/antelopevm/chain/entity"
@@ -14,7 +15,7 @@ import (
 
 type Session struct {
 	state               *State
-	transaction         *badger.Txn
+	transaction         *layeredTxn
 	accountCache        *cache.LRU[types.IdType, *account.Account]
 	tableCache          *cache.LRU[types.IdType, *table.Table]
 	kvCache             *cache.LRU[types.IdType, *table.KeyValue]
@@ -24,9 +25,13 @@ type Session struct {
 }
 
 func NewSession(state *State, transaction *badger.Txn) *Session {
+	return newLayeredSession(state, transaction, nil)
+}
+
+func newLayeredSession(state *State, transaction *badger.Txn, layer *stateLayer) *Session {
 	session := &Session{
 		state:               state,
-		transaction:         transaction,
+		transaction:         &layeredTxn{txn: transaction, layer: layer},
 		accountCache:        &cache.LRU[types.IdType, *account.Account]{Size: blockCacheSize},
 		tableCache:          &cache.LRU[types.IdType, *table.Table]{Size: blockCacheSize},
 		kvCache:             &cache.LRU[types.IdType, *table.KeyValue]{Size: blockCacheSize},
@@ -133,8 +138,71 @@ func (s *Session) increment(key []byte) (uint64, error) {
 	return id, nil
 }
 
+// UndoSession is a savepoint of a Session, the writes made after it was
+// started are either kept with Squash or reverted with Undo. Undo sessions can
+// be nested, closing one also closes the sessions started after it
+type UndoSession struct {
+	session *Session
+	depth   int
+	closed  bool
+}
+
+// StartUndoSession takes a savepoint of the current state of the session
+func (s *Session) StartUndoSession() *UndoSession {
+	return &UndoSession{session: s, depth: s.transaction.pushSavepoint()}
+}
+
+// Squash keeps the writes made since the undo session was started, they are
+// reverted when an enclosing undo session is undone
+func (u *UndoSession) Squash() {
+	if u.closed {
+		return
+	}
+
+	u.closed = true
+	u.session.transaction.squashSavepoint(u.depth)
+}
+
+// Undo reverts the writes made since the undo session was started, it does
+// nothing once the session was squashed so it can be deferred
+func (u *UndoSession) Undo() error {
+	if u.closed {
+		return nil
+	}
+
+	u.closed = true
+
+	if err := u.session.transaction.undoSavepoint(u.depth); err != nil {
+		return err
+	}
+
+	// Cached objects may hold the reverted values
+	u.session.flushCaches()
+
+	return nil
+}
+
+func (s *Session) flushCaches() {
+	s.accountCache.Flush()
+	s.tableCache.Flush()
+	s.kvCache.Flush()
+	s.indexObjectCache.Flush()
+	s.resourceUsageCache.Flush()
+	s.resourceLimitsCache.Flush()
+}
+
 func (s *Session) Commit() error {
-	return s.transaction.Commit()
+	if s.transaction.layer != nil {
+		return fmt.Errorf("speculative sessions cannot be committed, accept their block instead")
+	}
+
+	err := s.transaction.Commit()
+
+	if err == badger.ErrConflict {
+		s.state.Metrics.DatabaseConflict()
+	}
+
+	return err
 }
 
 func (s *Session) Discard() {
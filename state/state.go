@@ -1,12 +1,18 @@
 package state
 
 import (
+	"fmt"
+	"sort"
+
+	"github.com/MetalBlockchain/antelopevm/metrics"
 	"github.com/MetalBlockchain/metalgo/ids"
 	"github.com/dgraph-io/badger/v3"
 )
 
 var (
-	initializedKey = []byte("initialized")
+	initializedKey      = []byte("initialized")
+	commitInProgressKey = []byte("commitInProgress")
+	commitJournalPrefix = []byte("commitJournal__")
 )
 
 type State struct {
@@ -14,6 +20,9 @@ type State struct {
 	sequences    map[string]*badger.Sequence
 	lastAccepted ids.ID
 	vm           VM
+
+	// Metrics is optional, commit conflicts are counted when it is set
+	Metrics *metrics.Metrics
 }
 
 func NewState(vm VM, db *badger.DB) *State {
@@ -30,6 +39,207 @@ func (s *State) CreateSession(update bool) *Session {
 	return NewSession(s, transaction)
 }
 
+// CreateSpeculativeSession creates a session whose writes are kept in memory on
+// top of [parent]'s writes instead of being written to the database. This is
+// used to execute blocks that have not been accepted by consensus yet.
+func (s *State) CreateSpeculativeSession(parent *Block) *Session {
+	var parentLayer *stateLayer
+
+	if parent != nil {
+		parentLayer = parent.layer
+	}
+
+	return newLayeredSession(s, s.db.NewTransaction(false), newStateLayer(parentLayer))
+}
+
+// CommitBlock writes the speculative changes of [block] to the database and
+// marks it as the last accepted block. The parent of [block] must have been
+// committed before.
+//
+// Blocks can write more than fits in a single Badger transaction. The writes
+// are first copied to a journal, the block is then accepted together with a
+// commit marker in a single transaction, after which the journal is moved over
+// to the live keys. A commit interrupted by a crash is finished or rolled back
+// by RecoverCommit.
+func (s *State) CommitBlock(block *Block) error {
+	if block.layer != nil && block.layer.parent != nil && !block.layer.parent.flushed {
+		return fmt.Errorf("cannot commit block %s before its parent", block.ID())
+	}
+
+	if block.layer != nil {
+		if err := s.writeJournal(block.layer.writes); err != nil {
+			return err
+		}
+	}
+
+	session := s.CreateSession(true)
+	defer session.Discard()
+
+	if err := session.AcceptBlock(block); err != nil {
+		return err
+	}
+
+	if block.layer != nil {
+		if err := session.transaction.Set(commitInProgressKey, block.Hash[:]); err != nil {
+			return err
+		}
+	}
+
+	if err := session.Commit(); err != nil {
+		return err
+	}
+
+	if block.layer != nil {
+		if err := s.applyJournal(); err != nil {
+			return err
+		}
+
+		block.layer.markFlushed()
+	}
+
+	return nil
+}
+
+// RecoverCommit finishes a block commit that was interrupted after the block
+// was accepted and drops the journal of one that was interrupted before
+func (s *State) RecoverCommit() error {
+	err := s.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(commitInProgressKey)
+		return err
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return s.splitWrites(func(write func(key []byte, value []byte) error) error {
+			return s.iterateJournal(func(key []byte, _ []byte) error {
+				return write(key, nil)
+			})
+		})
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return s.applyJournal()
+}
+
+// writeJournal copies [writes] to the journal in key order, a nil value
+// deletes the key
+func (s *State) writeJournal(writes map[string][]byte) error {
+	keys := make([]string, 0, len(writes))
+
+	for key := range writes {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return s.splitWrites(func(write func(key []byte, value []byte) error) error {
+		for _, key := range keys {
+			entry := []byte{0}
+
+			if value := writes[key]; value != nil {
+				entry = append([]byte{1}, value...)
+			}
+
+			if err := write(append(append([]byte{}, commitJournalPrefix...), key...), entry); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// applyJournal moves the journal over to the live keys and clears the commit
+// marker once it is empty. Every entry is removed from the journal in the same
+// transaction that applies it, so it can be resumed at any point.
+func (s *State) applyJournal() error {
+	err := s.splitWrites(func(write func(key []byte, value []byte) error) error {
+		return s.iterateJournal(func(key []byte, entry []byte) error {
+			var value []byte
+
+			if entry[0] == 1 {
+				value = entry[1:]
+			}
+
+			if err := write(key[len(commitJournalPrefix):], value); err != nil {
+				return err
+			}
+
+			return write(key, nil)
+		})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(commitInProgressKey)
+	})
+}
+
+// iterateJournal calls [f] with every journal key and entry in key order
+func (s *State) iterateJournal(f func(key []byte, entry []byte) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = commitJournalPrefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			entry, err := it.Item().ValueCopy(nil)
+
+			if err != nil {
+				return err
+			}
+
+			if err := f(it.Item().KeyCopy(nil), entry); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// splitWrites runs [f] with a write function that spreads its writes over as
+// many Badger transactions as needed, a nil value deletes the key
+func (s *State) splitWrites(f func(write func(key []byte, value []byte) error) error) error {
+	txn := s.db.NewTransaction(true)
+	defer func() { txn.Discard() }()
+
+	set := func(key []byte, value []byte) error {
+		if value == nil {
+			return txn.Delete(key)
+		}
+
+		return txn.Set(key, value)
+	}
+
+	write := func(key []byte, value []byte) error {
+		err := set(key, value)
+
+		if err == badger.ErrTxnTooBig {
+			if err := txn.Commit(); err != nil {
+				return err
+			}
+
+			txn = s.db.NewTransaction(true)
+			err = set(key, value)
+		}
+
+		return err
+	}
+
+	if err := f(write); err != nil {
+		return err
+	}
+
+	return txn.Commit()
+}
+
 func (s *State) Close() error {
 	return s.db.Close()
 }
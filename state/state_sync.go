@@ -0,0 +1,380 @@
+package state
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash"
+
+	"github.com/MetalBlockchain/antelopevm/chain/entity"
+	"github.com/MetalBlockchain/antelopevm/crypto"
+	"github.com/dgraph-io/badger/v3"
+)
+
+// syncedObjectTypes are the objects that make up the chain state and are
+// transferred during state sync. Blocks and transaction traces are only kept
+// locally, the traces are not deterministic.
+var syncedObjectTypes = []uint8{
+	entity.AccountType,
+	entity.PermissionType,
+	entity.PermissionLinkType,
+	entity.TableType,
+	entity.KeyValueType,
+	entity.IndexObjectType,
+	entity.ResourceUsageType,
+	entity.ResourceLimitType,
+	entity.GlobalPropertyObjectType,
+	entity.TransactionObjectType,
+	entity.AccountMetaDataObjectType,
+	entity.AccountRamCorrectionObjectType,
+	entity.CodeObjectType,
+	entity.ProtocolStateObjectType,
+	entity.ResourceLimitsConfigObjectType,
+	entity.ResourceLimitsStateObjectType,
+	entity.BlockSummaryObjectType,
+	entity.GeneratedTransactionObjectType,
+}
+
+// isSyncedKey reports whether [key] belongs to one of the synced object types,
+// this includes the ID counter of the type which is stored under its type byte
+func isSyncedKey(key []byte) bool {
+	if len(key) == 0 {
+		return false
+	}
+
+	for _, objectType := range syncedObjectTypes {
+		if key[0] == objectType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StateChunk is a range of consecutive synced keys, [Next] is the key the next
+// chunk starts at and is empty once all keys have been sent
+type StateChunk struct {
+	Keys   [][]byte
+	Values [][]byte
+	Next   []byte
+}
+
+// stateHasher calculates the state root, the synced keys are hashed in order
+// together with their values
+type stateHasher struct {
+	hash    hash.Hash
+	lastKey []byte
+}
+
+func newStateHasher() *stateHasher {
+	return &stateHasher{hash: crypto.NewSha256()}
+}
+
+func (h *stateHasher) add(key []byte, value []byte) error {
+	if !isSyncedKey(key) {
+		return fmt.Errorf("key %x is not part of the synced state", key)
+	}
+
+	if h.lastKey != nil && bytes.Compare(key, h.lastKey) <= 0 {
+		return fmt.Errorf("key %x is not sorted after %x", key, h.lastKey)
+	}
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(key)))
+	h.hash.Write(length[:])
+	h.hash.Write(key)
+	binary.BigEndian.PutUint32(length[:], uint32(len(value)))
+	h.hash.Write(length[:])
+	h.hash.Write(value)
+	h.lastKey = append(h.lastKey[:0], key...)
+
+	return nil
+}
+
+func (h *stateHasher) root() crypto.Sha256 {
+	return *crypto.NewSha256Byte(h.hash.Sum(nil))
+}
+
+// Snapshot is a consistent read-only view of the state at the height of a
+// state summary, chunks are served from it while newer blocks are accepted
+type Snapshot struct {
+	txn *badger.Txn
+}
+
+func (s *State) NewSnapshot() *Snapshot {
+	return &Snapshot{txn: s.db.NewTransaction(false)}
+}
+
+// Root returns the state root of the snapshot
+func (s *Snapshot) Root() (crypto.Sha256, error) {
+	hasher := newStateHasher()
+	var next []byte
+
+	for {
+		chunk, err := s.Chunk(next, 1<<20)
+
+		if err != nil {
+			return crypto.Sha256{}, err
+		}
+
+		for i := range chunk.Keys {
+			if err := hasher.add(chunk.Keys[i], chunk.Values[i]); err != nil {
+				return crypto.Sha256{}, err
+			}
+		}
+
+		if len(chunk.Next) == 0 {
+			return hasher.root(), nil
+		}
+
+		next = chunk.Next
+	}
+}
+
+// Chunk returns the synced keys starting at [start], it stops once roughly
+// [maxBytes] of keys and values have been collected
+func (s *Snapshot) Chunk(start []byte, maxBytes int) (*StateChunk, error) {
+	chunk := &StateChunk{
+		Keys:   make([][]byte, 0),
+		Values: make([][]byte, 0),
+	}
+	iterator := s.txn.NewIterator(badger.DefaultIteratorOptions)
+	defer iterator.Close()
+	size := 0
+
+	for iterator.Seek(start); iterator.Valid(); {
+		item := iterator.Item()
+		key := item.Key()
+
+		// Object types are stored first, everything after them is local data
+		if key[0] > syncedObjectTypes[len(syncedObjectTypes)-1] {
+			break
+		}
+
+		// Skip over local objects such as blocks
+		if !isSyncedKey(key) {
+			iterator.Seek([]byte{key[0] + 1})
+			continue
+		}
+
+		if size >= maxBytes {
+			chunk.Next = item.KeyCopy(nil)
+			break
+		}
+
+		value, err := item.ValueCopy(nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		chunk.Keys = append(chunk.Keys, item.KeyCopy(nil))
+		chunk.Values = append(chunk.Values, value)
+		size += len(key) + len(value)
+		iterator.Next()
+	}
+
+	return chunk, nil
+}
+
+func (s *Snapshot) Discard() {
+	s.txn.Discard()
+}
+
+// StateSyncWriter replaces the synced state with the chunks received from
+// other nodes and checks them against the expected state root. The chunks are
+// staged next to the state of this node, which is only replaced once the root
+// matches.
+type StateSyncWriter struct {
+	state  *State
+	batch  *badger.WriteBatch
+	hasher *stateHasher
+}
+
+// NewStateSyncWriter drops whatever an earlier sync left staged
+func (s *State) NewStateSyncWriter() (*StateSyncWriter, error) {
+	if err := s.db.DropPrefix(stateSyncPrefix); err != nil {
+		return nil, err
+	}
+
+	return &StateSyncWriter{
+		state:  s,
+		batch:  s.db.NewWriteBatch(),
+		hasher: newStateHasher(),
+	}, nil
+}
+
+func (w *StateSyncWriter) Write(chunk *StateChunk) error {
+	if len(chunk.Keys) != len(chunk.Values) {
+		return fmt.Errorf("chunk has %d keys but %d values", len(chunk.Keys), len(chunk.Values))
+	}
+
+	for i := range chunk.Keys {
+		if err := w.hasher.add(chunk.Keys[i], chunk.Values[i]); err != nil {
+			return err
+		}
+
+		if err := w.batch.Set(append(append([]byte{}, stateSyncPrefix...), chunk.Keys[i]...), chunk.Values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Commit checks the received state against [root], replaces the synced state
+// with it and makes [block] the last accepted block. The swap goes through the
+// commit journal, so it is finished by RecoverCommit when it is interrupted.
+func (w *StateSyncWriter) Commit(root crypto.Sha256, block *Block) error {
+	if received := w.hasher.root(); !received.Equals(root) {
+		w.Cancel()
+		return fmt.Errorf("state root %s does not match the summary root %s", received, root)
+	}
+
+	if err := w.batch.Flush(); err != nil {
+		return err
+	}
+
+	s := w.state
+	err := s.splitWrites(func(write func(key []byte, value []byte) error) error {
+		journalKey := func(key []byte) []byte {
+			return append(append([]byte{}, commitJournalPrefix...), key...)
+		}
+
+		// Every synced key is deleted unless the staged state sets it again
+		err := s.db.View(func(txn *badger.Txn) error {
+			for _, objectType := range syncedObjectTypes {
+				opts := badger.DefaultIteratorOptions
+				opts.PrefetchValues = false
+				opts.Prefix = []byte{objectType}
+				it := txn.NewIterator(opts)
+
+				for it.Rewind(); it.Valid(); it.Next() {
+					if err := write(journalKey(it.Item().Key()), []byte{0}); err != nil {
+						it.Close()
+						return err
+					}
+				}
+
+				it.Close()
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return s.db.View(func(txn *badger.Txn) error {
+			opts := badger.DefaultIteratorOptions
+			opts.Prefix = stateSyncPrefix
+			it := txn.NewIterator(opts)
+			defer it.Close()
+
+			for it.Rewind(); it.Valid(); it.Next() {
+				value, err := it.Item().ValueCopy(nil)
+
+				if err != nil {
+					return err
+				}
+
+				if err := write(journalKey(it.Item().Key()[len(stateSyncPrefix):]), append([]byte{1}, value...)); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	session := s.CreateSession(true)
+	defer session.Discard()
+
+	if err := session.AcceptBlock(block); err != nil {
+		return err
+	}
+
+	if err := session.transaction.Set(commitInProgressKey, block.Hash[:]); err != nil {
+		return err
+	}
+
+	if err := session.Commit(); err != nil {
+		return err
+	}
+
+	if err := s.applyJournal(); err != nil {
+		return err
+	}
+
+	return s.db.DropPrefix(stateSyncPrefix)
+}
+
+// Cancel drops the staged state, the state of this node is left untouched
+func (w *StateSyncWriter) Cancel() error {
+	w.batch.Cancel()
+
+	return w.state.db.DropPrefix(stateSyncPrefix)
+}
+
+var (
+	stateSyncPrefix        = []byte("stateSync__")
+	stateSummaryPrefix     = []byte("stateSummary__")
+	ongoingStateSummaryKey = []byte("ongoingStateSummary")
+)
+
+func stateSummaryKey(height uint64) []byte {
+	key := make([]byte, 0, len(stateSummaryPrefix)+8)
+	key = append(key, stateSummaryPrefix...)
+
+	return append(key, uint64ToBytes(height)...)
+}
+
+func (s *State) PutStateSummary(height uint64, summary []byte) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(stateSummaryKey(height), summary)
+	})
+}
+
+func (s *State) GetStateSummary(height uint64) ([]byte, error) {
+	return s.get(stateSummaryKey(height))
+}
+
+// PutOngoingStateSummary remembers the summary this node is syncing to, so the
+// sync can be restarted when the node is stopped before it completes
+func (s *State) PutOngoingStateSummary(summary []byte) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(ongoingStateSummaryKey, summary)
+	})
+}
+
+func (s *State) GetOngoingStateSummary() ([]byte, error) {
+	return s.get(ongoingStateSummaryKey)
+}
+
+func (s *State) DeleteOngoingStateSummary() error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(ongoingStateSummaryKey)
+	})
+}
+
+func (s *State) get(key []byte) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+
+		if err != nil {
+			return err
+		}
+
+		value, err = item.ValueCopy(nil)
+
+		return err
+	})
+
+	return value, err
+}
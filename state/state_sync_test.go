@@ -0,0 +1,117 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/MetalBlockchain/antelopevm/chain/block"
+	"github.com/MetalBlockchain/antelopevm/chain/entity"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateSync(t *testing.T) {
+	source := newStateSyncTestState(t)
+	session := source.CreateSession(true)
+	assert.NoError(t, session.transaction.Set([]byte{entity.AccountType, 1}, []byte("account")))
+	assert.NoError(t, session.transaction.Set([]byte{entity.KeyValueType, 1}, []byte("kv")))
+	assert.NoError(t, session.transaction.Set([]byte{entity.BlockType, 1}, []byte("block")))
+	assert.NoError(t, session.transaction.Set([]byte("local"), []byte("local")))
+	assert.NoError(t, session.Commit())
+
+	snapshot := source.NewSnapshot()
+	defer snapshot.Discard()
+	root, err := snapshot.Root()
+	assert.NoError(t, err)
+
+	// Writes made after the snapshot was taken are not part of it
+	session = source.CreateSession(true)
+	assert.NoError(t, session.transaction.Set([]byte{entity.AccountType, 2}, []byte("later")))
+	assert.NoError(t, session.Commit())
+
+	target := newStateSyncTestState(t)
+	session = target.CreateSession(true)
+	assert.NoError(t, session.transaction.Set([]byte{entity.AccountType, 9}, []byte("stale")))
+	assert.NoError(t, session.Commit())
+
+	writer, err := target.NewStateSyncWriter()
+	assert.NoError(t, err)
+	var next []byte
+
+	for {
+		// A tiny chunk size makes sure the state is sent over multiple chunks
+		chunk, err := snapshot.Chunk(next, 1)
+		assert.NoError(t, err)
+		assert.NoError(t, writer.Write(chunk))
+
+		if len(chunk.Next) == 0 {
+			break
+		}
+
+		next = chunk.Next
+	}
+
+	blk := &Block{Hash: block.BlockHash{1}}
+	assert.NoError(t, writer.Commit(root, blk))
+
+	session = target.CreateSession(false)
+	defer session.Discard()
+	item, err := session.transaction.Get([]byte{entity.KeyValueType, 1})
+	assert.NoError(t, err)
+	value, _ := item.ValueCopy(nil)
+	assert.Equal(t, []byte("kv"), value)
+	_, err = session.transaction.Get([]byte{entity.AccountType, 9})
+	assert.ErrorIs(t, err, badger.ErrKeyNotFound)
+	_, err = session.transaction.Get([]byte{entity.BlockType, 1})
+	assert.ErrorIs(t, err, badger.ErrKeyNotFound)
+	lastAccepted, err := session.GetLastAccepted()
+	assert.NoError(t, err)
+	assert.Equal(t, blk.ID(), lastAccepted)
+}
+
+func TestStateSyncRootMismatch(t *testing.T) {
+	target := newStateSyncTestState(t)
+	session := target.CreateSession(true)
+	assert.NoError(t, session.transaction.Set([]byte{entity.AccountType, 9}, []byte("local")))
+	assert.NoError(t, session.Commit())
+
+	writer, err := target.NewStateSyncWriter()
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Write(&StateChunk{
+		Keys:   [][]byte{{entity.AccountType, 1}},
+		Values: [][]byte{[]byte("account")},
+	}))
+
+	// Keys have to arrive in order
+	assert.Error(t, writer.Write(&StateChunk{
+		Keys:   [][]byte{{entity.AccountType, 0}},
+		Values: [][]byte{[]byte("account")},
+	}))
+
+	blk := &Block{}
+	assert.Error(t, writer.Commit(newStateHasher().root(), blk))
+
+	// The failed sync left the state of this node as it was
+	session = target.CreateSession(false)
+	defer session.Discard()
+	item, err := session.transaction.Get([]byte{entity.AccountType, 9})
+	assert.NoError(t, err)
+	value, _ := item.ValueCopy(nil)
+	assert.Equal(t, []byte("local"), value)
+	_, err = session.transaction.Get([]byte{entity.AccountType, 1})
+	assert.ErrorIs(t, err, badger.ErrKeyNotFound)
+	_, err = session.GetLastAccepted()
+	assert.Error(t, err)
+
+	it := session.transaction.txn.NewIterator(badger.IteratorOptions{Prefix: stateSyncPrefix})
+	defer it.Close()
+	it.Rewind()
+	assert.False(t, it.Valid())
+}
+
+func newStateSyncTestState(t *testing.T) *State {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return NewState(nil, db)
+}
@@ -25,6 +25,33 @@ func (s *Session) FindTransactionObject(id types.IdType) (*transaction.Transacti
 	return out, nil
 }
 
+func (s *Session) FindTransactionObjectByTrxId(trxId transaction.TransactionIdType) (*transaction.TransactionObject, error) {
+	key := getObjectKeyByIndex(&transaction.TransactionObject{TrxId: trxId}, "byTrxId")
+	item, err := s.transaction.Get(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := item.ValueCopy(nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.FindTransactionObject(types.NewIdType(data))
+}
+
+// FindTransactionObjectsByExpiration iterates over the transaction objects
+// ordered by their expiration, the oldest first
+func (s *Session) FindTransactionObjectsByExpiration() *Iterator[transaction.TransactionObject] {
+	key := getPartialKey("byExpiration", &transaction.TransactionObject{})
+
+	return newIterator(s, key, func(b []byte) (*transaction.TransactionObject, error) {
+		return s.FindTransactionObject(types.NewIdType(b))
+	})
+}
+
 func (s *Session) FindTransactionByHash(hash transaction.TransactionIdType) (*transaction.TransactionTrace, error) {
 	key := getObjectKeyByIndex(&transaction.TransactionTrace{Hash: hash}, "byHash")
 	item, err := s.transaction.Get(key)
@@ -43,5 +70,8 @@ func (s *Session) FindTransactionByHash(hash transaction.TransactionIdType) (*tr
 }
 
 func (s *Session) CreateTransaction(in *transaction.TransactionTrace) error {
-	return s.create(false, nil, in)
+	return s.create(true, func(id types.IdType) error {
+		in.ID = id
+		return nil
+	}, in)
 }
@@ -29,5 +29,12 @@ func (s *Session) FindTransaction(id types.IdType) (*transaction.TransactionTrac
 }
 
 func (s *Session) CreateTransactionObject(in *transaction.TransactionObject) error {
-	return s.create(false, nil, in)
+	return s.create(true, func(id types.IdType) error {
+		in.ID = id
+		return nil
+	}, in)
+}
+
+func (s *Session) RemoveTransactionObject(in *transaction.TransactionObject) error {
+	return s.remove(in)
 }
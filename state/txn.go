@@ -0,0 +1,408 @@
+package state
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// stateLayer holds uncommitted writes of a block on top of the writes of its
+// parent block. Layers form a tree that mirrors the blocks that have been
+// verified but not yet decided, so that competing children of the same parent
+// each see their own version of the state. A nil value marks a deleted key.
+//
+// Layers are immutable once the block that owns them has been verified. The
+// consensus engine serializes Verify, Accept and Reject while it holds the snow
+// context lock, sessions opened outside of the engine must hold that lock for
+// reading as long as they use a layer, as Accept flushes and drops them.
+type stateLayer struct {
+	parent  *stateLayer
+	writes  map[string][]byte
+	flushed bool
+}
+
+func newStateLayer(parent *stateLayer) *stateLayer {
+	return &stateLayer{
+		parent: parent,
+		writes: make(map[string][]byte),
+	}
+}
+
+// get walks up the layer tree until it finds [key] or reaches a layer which has
+// already been written to the database
+func (l *stateLayer) get(key []byte) (value []byte, deleted bool, found bool) {
+	for current := l; current != nil && !current.flushed; current = current.parent {
+		if value, ok := current.writes[string(key)]; ok {
+			return value, value == nil, true
+		}
+	}
+
+	return nil, false, false
+}
+
+// collect returns all writes visible from this layer matching [prefix], the
+// closest layer wins when the same key was written more than once
+func (l *stateLayer) collect(prefix []byte) map[string][]byte {
+	out := make(map[string][]byte)
+
+	for current := l; current != nil && !current.flushed; current = current.parent {
+		for key, value := range current.writes {
+			if _, ok := out[key]; ok {
+				continue
+			}
+
+			if bytes.HasPrefix([]byte(key), prefix) {
+				out[key] = value
+			}
+		}
+	}
+
+	return out
+}
+
+// markFlushed is called once the writes of this layer have been committed to
+// the database, the writes are dropped as they can now be read from disk
+func (l *stateLayer) markFlushed() {
+	l.flushed = true
+	l.parent = nil
+	l.writes = nil
+}
+
+// layeredTxn reads through an optional stateLayer before falling back to the
+// underlying Badger transaction. Writes go to the layer when there is one,
+// otherwise they go directly to the Badger transaction.
+type layeredTxn struct {
+	txn   *badger.Txn
+	layer *stateLayer
+
+	// undo holds one savepoint per open undo session, the last one is the
+	// innermost session
+	undo []*savepoint
+}
+
+// savepoint records the value every key written since the savepoint was taken
+// had before, a nil value marks a key that did not exist
+type savepoint struct {
+	previous map[string][]byte
+}
+
+type layeredItem struct {
+	key   []byte
+	value []byte
+	item  *badger.Item
+}
+
+func (i *layeredItem) Key() []byte {
+	if i == nil {
+		return nil
+	}
+
+	if i.item != nil {
+		return i.item.Key()
+	}
+
+	return i.key
+}
+
+func (i *layeredItem) ValueCopy(dst []byte) ([]byte, error) {
+	if i == nil {
+		return nil, badger.ErrKeyNotFound
+	}
+
+	if i.item != nil {
+		return i.item.ValueCopy(dst)
+	}
+
+	return append(dst[:0], i.value...), nil
+}
+
+func (t *layeredTxn) Get(key []byte) (*layeredItem, error) {
+	if t.layer != nil {
+		if value, deleted, found := t.layer.get(key); found {
+			if deleted {
+				return nil, badger.ErrKeyNotFound
+			}
+
+			return &layeredItem{key: key, value: value}, nil
+		}
+	}
+
+	item, err := t.txn.Get(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &layeredItem{item: item}, nil
+}
+
+func (t *layeredTxn) Set(key []byte, value []byte) error {
+	if err := t.record(key); err != nil {
+		return err
+	}
+
+	return t.set(key, value)
+}
+
+func (t *layeredTxn) Delete(key []byte) error {
+	if err := t.record(key); err != nil {
+		return err
+	}
+
+	return t.delete(key)
+}
+
+func (t *layeredTxn) set(key []byte, value []byte) error {
+	if t.layer != nil {
+		t.layer.writes[string(key)] = append([]byte{}, value...)
+		return nil
+	}
+
+	return t.txn.Set(key, value)
+}
+
+func (t *layeredTxn) delete(key []byte) error {
+	if t.layer != nil {
+		t.layer.writes[string(key)] = nil
+		return nil
+	}
+
+	return t.txn.Delete(key)
+}
+
+// record remembers the current value of [key] in the innermost savepoint the
+// first time the key is written after it was taken
+func (t *layeredTxn) record(key []byte) error {
+	if len(t.undo) == 0 {
+		return nil
+	}
+
+	current := t.undo[len(t.undo)-1]
+
+	if _, ok := current.previous[string(key)]; ok {
+		return nil
+	}
+
+	item, err := t.Get(key)
+
+	if err == badger.ErrKeyNotFound {
+		current.previous[string(key)] = nil
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	value, err := item.ValueCopy(nil)
+
+	if err != nil {
+		return err
+	}
+
+	current.previous[string(key)] = value
+
+	return nil
+}
+
+// pushSavepoint opens a new innermost savepoint and returns its depth
+func (t *layeredTxn) pushSavepoint() int {
+	t.undo = append(t.undo, &savepoint{previous: make(map[string][]byte)})
+
+	return len(t.undo)
+}
+
+// undoSavepoint restores the values recorded by the savepoint at [depth] and
+// by every savepoint opened after it
+func (t *layeredTxn) undoSavepoint(depth int) error {
+	for len(t.undo) >= depth {
+		current := t.undo[len(t.undo)-1]
+		t.undo = t.undo[:len(t.undo)-1]
+
+		for key, value := range current.previous {
+			var err error
+
+			if value == nil {
+				err = t.delete([]byte(key))
+			} else {
+				err = t.set([]byte(key), value)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// squashSavepoint keeps the writes of the savepoint at [depth] and of every
+// savepoint opened after it, they can still be undone by the enclosing one
+func (t *layeredTxn) squashSavepoint(depth int) {
+	for len(t.undo) >= depth {
+		current := t.undo[len(t.undo)-1]
+		t.undo = t.undo[:len(t.undo)-1]
+
+		if len(t.undo) == 0 {
+			continue
+		}
+
+		// The enclosing savepoint keeps the older value of a key
+		parent := t.undo[len(t.undo)-1]
+
+		for key, value := range current.previous {
+			if _, ok := parent.previous[key]; !ok {
+				parent.previous[key] = value
+			}
+		}
+	}
+}
+
+func (t *layeredTxn) Commit() error {
+	return t.txn.Commit()
+}
+
+func (t *layeredTxn) Discard() {
+	t.txn.Discard()
+}
+
+// NewIterator merges the keys written in the layer with the keys stored in
+// the database, honouring the prefix and direction of [opts]
+func (t *layeredTxn) NewIterator(opts badger.IteratorOptions) *layeredIterator {
+	it := &layeredIterator{
+		iterator: t.txn.NewIterator(opts),
+		reverse:  opts.Reverse,
+		prefix:   opts.Prefix,
+	}
+
+	if t.layer != nil {
+		it.overlay = t.layer.collect(opts.Prefix)
+		it.keys = make([]string, 0, len(it.overlay))
+
+		for key := range it.overlay {
+			it.keys = append(it.keys, key)
+		}
+
+		sort.Strings(it.keys)
+
+		if it.reverse {
+			for i, j := 0, len(it.keys)-1; i < j; i, j = i+1, j-1 {
+				it.keys[i], it.keys[j] = it.keys[j], it.keys[i]
+			}
+		}
+	}
+
+	return it
+}
+
+type layeredIterator struct {
+	iterator *badger.Iterator
+	overlay  map[string][]byte
+	keys     []string
+	position int
+	reverse  bool
+	prefix   []byte
+	current  *layeredItem
+}
+
+func (i *layeredIterator) Rewind() {
+	i.iterator.Rewind()
+	i.position = 0
+	i.settle()
+}
+
+func (i *layeredIterator) Seek(key []byte) {
+	i.iterator.Seek(key)
+	i.position = sort.Search(len(i.keys), func(n int) bool {
+		if i.reverse {
+			return i.keys[n] <= string(key)
+		}
+
+		return i.keys[n] >= string(key)
+	})
+	i.settle()
+}
+
+func (i *layeredIterator) Next() {
+	if i.current == nil {
+		return
+	}
+
+	key := i.current.Key()
+
+	if i.position < len(i.keys) && i.keys[i.position] == string(key) {
+		i.position++
+	}
+
+	if i.iterator.Valid() && bytes.Equal(i.iterator.Item().Key(), key) {
+		i.iterator.Next()
+	}
+
+	i.settle()
+}
+
+func (i *layeredIterator) Valid() bool {
+	return i.current != nil
+}
+
+func (i *layeredIterator) ValidForPrefix(prefix []byte) bool {
+	return i.current != nil && bytes.HasPrefix(i.current.Key(), prefix)
+}
+
+func (i *layeredIterator) Item() *layeredItem {
+	return i.current
+}
+
+func (i *layeredIterator) Close() {
+	i.iterator.Close()
+}
+
+// before reports whether [a] comes before [b] in the direction of iteration
+func (i *layeredIterator) before(a []byte, b []byte) bool {
+	if i.reverse {
+		return bytes.Compare(a, b) > 0
+	}
+
+	return bytes.Compare(a, b) < 0
+}
+
+// settle moves [current] to the next visible key, overlay keys shadow keys
+// stored in the database and deleted overlay keys are skipped
+func (i *layeredIterator) settle() {
+	for {
+		var dbKey []byte
+
+		if i.iterator.Valid() {
+			dbKey = i.iterator.Item().Key()
+		}
+
+		if i.position >= len(i.keys) {
+			if dbKey == nil {
+				i.current = nil
+			} else {
+				i.current = &layeredItem{item: i.iterator.Item()}
+			}
+
+			return
+		}
+
+		overlayKey := []byte(i.keys[i.position])
+
+		if dbKey != nil && i.before(dbKey, overlayKey) {
+			i.current = &layeredItem{item: i.iterator.Item()}
+			return
+		}
+
+		if dbKey != nil && bytes.Equal(dbKey, overlayKey) {
+			i.iterator.Next()
+		}
+
+		if value := i.overlay[i.keys[i.position]]; value != nil {
+			i.current = &layeredItem{key: overlayKey, value: value}
+			return
+		}
+
+		// Key was deleted in the overlay, skip it
+		i.position++
+	}
+}
@@ -0,0 +1,277 @@
+package state
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpeculativeSessionsFork(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	assert.NoError(t, err)
+	state := NewState(nil, db)
+
+	session := state.CreateSession(true)
+	assert.NoError(t, session.transaction.Set([]byte("a"), []byte("genesis")))
+	assert.NoError(t, session.transaction.Set([]byte("b"), []byte("genesis")))
+	assert.NoError(t, session.Commit())
+
+	parent := &Block{}
+	session = state.CreateSpeculativeSession(nil)
+	assert.NoError(t, session.transaction.Set([]byte("a"), []byte("parent")))
+	parent.layer = session.transaction.layer
+	session.Discard()
+
+	// Two competing children of the same parent
+	left, right := &Block{}, &Block{}
+	session = state.CreateSpeculativeSession(parent)
+	item, err := session.transaction.Get([]byte("a"))
+	assert.NoError(t, err)
+	value, _ := item.ValueCopy(nil)
+	assert.Equal(t, []byte("parent"), value)
+	assert.NoError(t, session.transaction.Set([]byte("c"), []byte("left")))
+	assert.NoError(t, session.transaction.Delete([]byte("b")))
+	left.layer = session.transaction.layer
+	session.Discard()
+
+	session = state.CreateSpeculativeSession(parent)
+	assert.NoError(t, session.transaction.Set([]byte("c"), []byte("right")))
+	_, err = session.transaction.Get([]byte("b"))
+	assert.NoError(t, err)
+	right.layer = session.transaction.layer
+	session.Discard()
+
+	// Children cannot be accepted before their parent
+	assert.Error(t, state.CommitBlock(right))
+	assert.NoError(t, state.CommitBlock(parent))
+	assert.NoError(t, state.CommitBlock(right))
+
+	session = state.CreateSession(false)
+	defer session.Discard()
+	item, err = session.transaction.Get([]byte("a"))
+	assert.NoError(t, err)
+	value, _ = item.ValueCopy(nil)
+	assert.Equal(t, []byte("parent"), value)
+	item, err = session.transaction.Get([]byte("c"))
+	assert.NoError(t, err)
+	value, _ = item.ValueCopy(nil)
+	assert.Equal(t, []byte("right"), value)
+	_, err = session.transaction.Get([]byte("b"))
+	assert.NoError(t, err)
+}
+
+func TestSpeculativeSessionIterator(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	assert.NoError(t, err)
+	state := NewState(nil, db)
+
+	session := state.CreateSession(true)
+	assert.NoError(t, session.transaction.Set([]byte("p1"), []byte("1")))
+	assert.NoError(t, session.transaction.Set([]byte("p3"), []byte("3")))
+	assert.NoError(t, session.transaction.Set([]byte("p5"), []byte("5")))
+	assert.NoError(t, session.Commit())
+
+	session = state.CreateSpeculativeSession(nil)
+	defer session.Discard()
+	assert.NoError(t, session.transaction.Set([]byte("p2"), []byte("2")))
+	assert.NoError(t, session.transaction.Set([]byte("p3"), []byte("33")))
+	assert.NoError(t, session.transaction.Delete([]byte("p5")))
+
+	collect := func(reverse bool) []string {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte("p")
+		opts.Reverse = reverse
+		iterator := session.transaction.NewIterator(opts)
+		defer iterator.Close()
+		values := make([]string, 0)
+
+		// Like Badger, reverse iteration has to seek past the end of the prefix
+		if reverse {
+			iterator.Seek([]byte("p\xff"))
+		} else {
+			iterator.Rewind()
+		}
+
+		for ; iterator.Valid(); iterator.Next() {
+			value, err := iterator.Item().ValueCopy(nil)
+			assert.NoError(t, err)
+			values = append(values, string(value))
+		}
+
+		return values
+	}
+
+	assert.Equal(t, []string{"1", "2", "33"}, collect(false))
+	assert.Equal(t, []string{"33", "2", "1"}, collect(true))
+}
+
+func TestUndoSessions(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	assert.NoError(t, err)
+	state := NewState(nil, db)
+
+	session := state.CreateSession(true)
+	assert.NoError(t, session.transaction.Set([]byte("a"), []byte("1")))
+	assert.NoError(t, session.Commit())
+
+	get := func(session *Session, key string) string {
+		item, err := session.transaction.Get([]byte(key))
+
+		if err != nil {
+			return ""
+		}
+
+		value, _ := item.ValueCopy(nil)
+
+		return string(value)
+	}
+
+	for _, session := range []*Session{state.CreateSession(true), state.CreateSpeculativeSession(nil)} {
+		// An undone session reverts the writes of the sessions nested in it
+		outer := session.StartUndoSession()
+		assert.NoError(t, session.transaction.Set([]byte("a"), []byte("2")))
+		inner := session.StartUndoSession()
+		assert.NoError(t, session.transaction.Set([]byte("a"), []byte("3")))
+		assert.NoError(t, session.transaction.Set([]byte("b"), []byte("3")))
+		inner.Squash()
+		assert.Equal(t, "3", get(session, "a"))
+		assert.NoError(t, outer.Undo())
+		assert.Equal(t, "1", get(session, "a"))
+		assert.Equal(t, "", get(session, "b"))
+
+		// Only the failed transaction is reverted
+		outer = session.StartUndoSession()
+		assert.NoError(t, session.transaction.Delete([]byte("a")))
+		inner = session.StartUndoSession()
+		assert.NoError(t, session.transaction.Set([]byte("a"), []byte("4")))
+		assert.NoError(t, inner.Undo())
+		assert.Equal(t, "", get(session, "a"))
+		outer.Squash()
+		assert.NoError(t, outer.Undo())
+		assert.Equal(t, "", get(session, "a"))
+
+		session.Discard()
+	}
+}
+
+func TestCommitLargeBlock(t *testing.T) {
+	// A small memtable keeps the transaction size limit low
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil).WithMemTableSize(1 << 20).WithValueThreshold(1 << 10))
+	assert.NoError(t, err)
+	state := NewState(nil, db)
+
+	session := state.CreateSpeculativeSession(nil)
+	value := make([]byte, 512)
+
+	for i := uint64(0); i < 1024; i++ {
+		assert.NoError(t, session.transaction.Set(uint64ToBytes(i), value))
+	}
+
+	blk := &Block{layer: session.transaction.layer}
+	session.Discard()
+
+	// The writes do not fit in a single transaction
+	txn := db.NewTransaction(true)
+	for key, value := range blk.layer.writes {
+		if err = txn.Set([]byte(key), value); err != nil {
+			break
+		}
+	}
+	txn.Discard()
+	assert.ErrorIs(t, err, badger.ErrTxnTooBig)
+
+	assert.NoError(t, state.CommitBlock(blk))
+
+	session = state.CreateSession(false)
+	defer session.Discard()
+	for i := uint64(0); i < 1024; i++ {
+		_, err := session.transaction.Get(uint64ToBytes(i))
+		assert.NoError(t, err)
+	}
+}
+
+func TestCommitInterrupted(t *testing.T) {
+	// A small memtable makes the commit span several transactions
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil).WithMemTableSize(1 << 20).WithValueThreshold(1 << 10))
+	assert.NoError(t, err)
+	state := NewState(nil, db)
+
+	session := state.CreateSession(true)
+	assert.NoError(t, session.transaction.Set([]byte("removed"), []byte{1}))
+	assert.NoError(t, session.Commit())
+
+	session = state.CreateSpeculativeSession(nil)
+	value := make([]byte, 512)
+
+	for i := uint64(0); i < 1024; i++ {
+		assert.NoError(t, session.transaction.Set(uint64ToBytes(i), value))
+	}
+
+	assert.NoError(t, session.transaction.Delete([]byte("removed")))
+	blk := &Block{Hash: [32]byte{1}, layer: session.transaction.layer}
+	session.Discard()
+
+	committed := func(expected bool) {
+		session := state.CreateSession(false)
+		defer session.Discard()
+
+		for i := uint64(0); i < 1024; i++ {
+			_, err := session.transaction.Get(uint64ToBytes(i))
+			assert.Equal(t, expected, err == nil)
+		}
+
+		_, err := session.transaction.Get([]byte("removed"))
+		assert.Equal(t, expected, err == badger.ErrKeyNotFound)
+		_, err = session.transaction.Get(commitInProgressKey)
+		assert.ErrorIs(t, err, badger.ErrKeyNotFound)
+
+		entries := 0
+		assert.NoError(t, state.iterateJournal(func(key []byte, entry []byte) error {
+			entries++
+			return nil
+		}))
+		assert.Zero(t, entries)
+	}
+
+	// A commit interrupted before the block was accepted is rolled back
+	assert.NoError(t, state.writeJournal(blk.layer.writes))
+	assert.NoError(t, state.RecoverCommit())
+	committed(false)
+
+	// A commit interrupted halfway through the flush is finished
+	assert.NoError(t, state.writeJournal(blk.layer.writes))
+	session = state.CreateSession(true)
+	assert.NoError(t, session.AcceptBlock(blk))
+	assert.NoError(t, session.transaction.Set(commitInProgressKey, blk.Hash[:]))
+	assert.NoError(t, session.Commit())
+
+	errCrash := errors.New("crash")
+	moved := 0
+	err = state.splitWrites(func(write func(key []byte, value []byte) error) error {
+		return state.iterateJournal(func(key []byte, entry []byte) error {
+			if moved == 512 {
+				return errCrash
+			}
+
+			moved++
+
+			if err := write(key[len(commitJournalPrefix):], entry[1:]); err != nil {
+				return err
+			}
+
+			return write(key, nil)
+		})
+	})
+	assert.ErrorIs(t, err, errCrash)
+
+	assert.NoError(t, state.RecoverCommit())
+	committed(true)
+
+	session = state.CreateSession(false)
+	defer session.Discard()
+	lastAccepted, err := session.GetLastAccepted()
+	assert.NoError(t, err)
+	assert.Equal(t, blk.ID(), lastAccepted)
+}
@@ -15,5 +15,9 @@ type VM interface {
 	State() *State
 	GetStoredBlock(context.Context, ids.ID) (*Block, error)
 	GetMempool() *mempool.Mempool
-	ExecuteTransaction(*transaction.PackedTransaction, *Block, *Session) (*transaction.TransactionTrace, error)
+	StartBlock(block *Block, parent *Block, session *Session) error
+	FinalizeBlock(block *Block, session *Session) error
+	ExecuteTransaction(trx *transaction.PackedTransaction, billedCpuTimeUs uint32, block *Block, session *Session) (*transaction.TransactionTrace, error)
+	ScheduledTransactions(block *Block, session *Session) ([]transaction.TransactionIdType, error)
+	ExecuteScheduledTransaction(trxId transaction.TransactionIdType, billedCpuTimeUs uint32, block *Block, session *Session) (*transaction.TransactionTrace, error)
 }
@@ -0,0 +1,252 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MetalBlockchain/antelopevm/chain"
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/crypto/ecc"
+	"github.com/MetalBlockchain/antelopevm/vm/service"
+	log "github.com/inconshreveable/log15"
+)
+
+const (
+	defaultMempoolSize = 100
+	defaultLogLevel    = "info"
+
+	// A state summary is produced every time a block at a multiple of this
+	// height is accepted
+	defaultStateSummaryInterval = 1024
+
+	// adminPlugin serves endpoints that change the node, it has to be enabled
+	// explicitly
+	adminPlugin = "admin_api_plugin"
+)
+
+// Config holds the node-local settings operators pass to the VM through
+// configData, none of them affect consensus
+type Config struct {
+	MempoolSize       int      `json:"mempool-size"`
+	ActorWhitelist    []string `json:"actor-whitelist"`
+	ActorBlacklist    []string `json:"actor-blacklist"`
+	ContractWhitelist []string `json:"contract-whitelist"`
+	ContractBlacklist []string `json:"contract-blacklist"`
+	KeyBlacklist      []string `json:"key-blacklist"`
+	ReadOnly          bool     `json:"read-only"`
+	APIPlugins        []string `json:"api-plugins"`
+	LogLevel          string   `json:"log-level"`
+	CPUProfilePath    string   `json:"cpu-profile-path"`
+
+	StateSyncEnabled     bool   `json:"state-sync-enabled"`
+	StateSummaryInterval uint64 `json:"state-summary-interval"`
+}
+
+// DefaultConfig enables every API plugin but the admin one and keeps the lists
+// empty
+func DefaultConfig() Config {
+	plugins := make([]string, 0)
+
+	for _, plugin := range service.GetPlugins() {
+		if plugin != adminPlugin {
+			plugins = append(plugins, plugin)
+		}
+	}
+
+	return Config{
+		MempoolSize:          defaultMempoolSize,
+		APIPlugins:           plugins,
+		LogLevel:             defaultLogLevel,
+		StateSyncEnabled:     true,
+		StateSummaryInterval: defaultStateSummaryInterval,
+	}
+}
+
+// ParseConfig reads [configData] on top of the default config and validates
+// the result, empty config data means the defaults are used
+func ParseConfig(configData []byte) (Config, error) {
+	config := DefaultConfig()
+
+	if len(configData) > 0 {
+		// Misspelled options would otherwise silently fall back to their defaults
+		decoder := json.NewDecoder(bytes.NewReader(configData))
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(&config); err != nil {
+			return Config{}, fmt.Errorf("failed to parse config: %w", err)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return config, nil
+}
+
+func (c Config) Validate() error {
+	if c.MempoolSize <= 0 {
+		return fmt.Errorf("mempool-size must be positive, got %d", c.MempoolSize)
+	}
+
+	if _, err := parseAccessLists(c.AccessLists()); err != nil {
+		return err
+	}
+
+	plugins := service.GetPlugins()
+
+	for _, plugin := range c.APIPlugins {
+		if !contains(plugins, plugin) {
+			return fmt.Errorf("unknown api plugin %q, expected one of %s", plugin, strings.Join(plugins, ", "))
+		}
+	}
+
+	if _, err := log.LvlFromString(c.LogLevel); err != nil {
+		return fmt.Errorf("log-level: %w", err)
+	}
+
+	if c.StateSummaryInterval == 0 {
+		return fmt.Errorf("state-summary-interval must be positive")
+	}
+
+	return nil
+}
+
+// Apply sets the account and key lists and read-only mode on [controller]
+func (c Config) Apply(controller *chain.Controller) error {
+	lists, err := parseAccessLists(c.AccessLists())
+
+	if err != nil {
+		return err
+	}
+
+	controller.SetAccessLists(lists)
+	controller.ReadOnly = c.ReadOnly
+
+	return nil
+}
+
+// AccessLists returns the account and key lists of the config
+func (c Config) AccessLists() service.AccessLists {
+	return service.AccessLists{
+		ActorWhitelist:    c.ActorWhitelist,
+		ActorBlacklist:    c.ActorBlacklist,
+		ContractWhitelist: c.ContractWhitelist,
+		ContractBlacklist: c.ContractBlacklist,
+		KeyBlacklist:      c.KeyBlacklist,
+	}
+}
+
+// parseAccessLists validates every account name and key of [lists], the error
+// names the option holding the invalid entry
+func parseAccessLists(lists service.AccessLists) (chain.AccessLists, error) {
+	var (
+		parsed chain.AccessLists
+		err    error
+	)
+
+	accounts := []struct {
+		option string
+		in     []string
+		out    *name.NameSet
+	}{
+		{"actor-whitelist", lists.ActorWhitelist, &parsed.ActorWhitelist},
+		{"actor-blacklist", lists.ActorBlacklist, &parsed.ActorBlacklist},
+		{"contract-whitelist", lists.ContractWhitelist, &parsed.ContractWhitelist},
+		{"contract-blacklist", lists.ContractBlacklist, &parsed.ContractBlacklist},
+	}
+
+	for _, list := range accounts {
+		if *list.out, err = parseNameSet(list.in); err != nil {
+			return chain.AccessLists{}, fmt.Errorf("%s: %w", list.option, err)
+		}
+	}
+
+	if parsed.KeyBlacklist, err = parsePublicKeySet(lists.KeyBlacklist); err != nil {
+		return chain.AccessLists{}, fmt.Errorf("key-blacklist: %w", err)
+	}
+
+	return parsed, nil
+}
+
+// formatAccessLists is the inverse of parseAccessLists
+func formatAccessLists(lists chain.AccessLists) service.AccessLists {
+	names := func(set name.NameSet) []string {
+		out := make([]string, 0, set.Size())
+
+		for _, n := range set.Slice() {
+			out = append(out, n.String())
+		}
+
+		sort.Strings(out)
+
+		return out
+	}
+	keys := make([]string, 0, lists.KeyBlacklist.Size())
+
+	for _, key := range lists.KeyBlacklist.Slice() {
+		keys = append(keys, key.String())
+	}
+
+	sort.Strings(keys)
+
+	return service.AccessLists{
+		ActorWhitelist:    names(lists.ActorWhitelist),
+		ActorBlacklist:    names(lists.ActorBlacklist),
+		ContractWhitelist: names(lists.ContractWhitelist),
+		ContractBlacklist: names(lists.ContractBlacklist),
+		KeyBlacklist:      keys,
+	}
+}
+
+// PluginEnabled reports whether the handlers of [plugin] are served
+func (c Config) PluginEnabled(plugin string) bool {
+	return contains(c.APIPlugins, plugin)
+}
+
+// parseNameSet rejects names that do not survive a round trip, such as names
+// with upper case letters or more than 13 characters
+func parseNameSet(accounts []string) (name.NameSet, error) {
+	set := name.NewNameSet(len(accounts))
+
+	for _, account := range accounts {
+		n := name.StringToName(account)
+
+		if account == "" || n.String() != account {
+			return nil, fmt.Errorf("invalid account name %q", account)
+		}
+
+		set.Insert(n)
+	}
+
+	return set, nil
+}
+
+func parsePublicKeySet(keys []string) (ecc.PublicKeySet, error) {
+	set := ecc.NewPublicKeySet(len(keys))
+
+	for _, key := range keys {
+		publicKey, err := ecc.NewPublicKey(key)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key %q: %w", key, err)
+		}
+
+		set.Insert(publicKey)
+	}
+
+	return set, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,88 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/MetalBlockchain/antelopevm/chain"
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/crypto"
+	"github.com/MetalBlockchain/antelopevm/vm/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	config, err := ParseConfig(nil)
+	assert.NoError(err)
+	assert.Equal(DefaultConfig(), config)
+	assert.True(config.PluginEnabled("chain_api_plugin"))
+	assert.False(config.PluginEnabled("admin_api_plugin"))
+
+	config, err = ParseConfig([]byte(`{
+		"mempool-size": 500,
+		"actor-blacklist": ["alice", "eosio.token"],
+		"contract-whitelist": ["eosio"],
+		"key-blacklist": ["EOS5XPRJt1zUiLH98rtDLj9TnPi52DLQ7gTZbkRvBGJXLv6ak6Cdq"],
+		"read-only": true,
+		"api-plugins": [],
+		"log-level": "debug",
+		"state-sync-enabled": false,
+		"state-summary-interval": 16
+	}`))
+	assert.NoError(err)
+	assert.Equal(500, config.MempoolSize)
+	assert.False(config.StateSyncEnabled)
+	assert.Equal(uint64(16), config.StateSummaryInterval)
+	assert.False(config.PluginEnabled("chain_api_plugin"))
+
+	controller := chain.NewController(crypto.Sha256{}, nil)
+	assert.NoError(config.Apply(controller))
+	assert.True(controller.ReadOnly)
+	assert.True(controller.ActorBlacklist.Contains(name.StringToName("eosio.token")))
+	assert.True(controller.ContractWhitelist.Contains(name.StringToName("eosio")))
+	assert.Equal(1, controller.KeyBlackist.Size())
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	tests := map[string]string{
+		"malformed":        `{"mempool-size": "big"}`,
+		"mempool size":     `{"mempool-size": 0}`,
+		"account name":     `{"actor-whitelist": ["Alice"]}`,
+		"long name":        `{"contract-blacklist": ["averyveryverylongname"]}`,
+		"public key":       `{"key-blacklist": ["EOS123"]}`,
+		"unknown plugin":   `{"api-plugins": ["producer_api_plugin"]}`,
+		"log level":        `{"log-level": "verbose"}`,
+		"summary interval": `{"state-summary-interval": 0}`,
+	}
+
+	for name, config := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(config))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseConfigUnknownOption(t *testing.T) {
+	_, err := ParseConfig([]byte(`{"mempool-size": 500, "mempool-sise": 500}`))
+	assert.ErrorContains(t, err, `unknown field "mempool-sise"`)
+}
+
+func TestAccessLists(t *testing.T) {
+	lists := service.AccessLists{
+		ActorWhitelist:    []string{},
+		ActorBlacklist:    []string{"bob", "alice"},
+		ContractWhitelist: []string{},
+		ContractBlacklist: []string{"eosio.token"},
+		KeyBlacklist:      []string{"EOS5XPRJt1zUiLH98rtDLj9TnPi52DLQ7gTZbkRvBGJXLv6ak6Cdq"},
+	}
+	parsed, err := parseAccessLists(lists)
+	assert.NoError(t, err)
+
+	lists.ActorBlacklist = []string{"alice", "bob"}
+	assert.Equal(t, lists, formatAccessLists(parsed))
+
+	_, err = parseAccessLists(service.AccessLists{ActorWhitelist: []string{"Alice"}})
+	assert.ErrorContains(t, err, "actor-whitelist")
+}
@@ -0,0 +1,210 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	chainTime "github.com/MetalBlockchain/antelopevm/chain/time"
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
+	"github.com/MetalBlockchain/metalgo/cache"
+	"github.com/MetalBlockchain/metalgo/ids"
+	"github.com/MetalBlockchain/metalgo/snow/engine/common"
+	log "github.com/inconshreveable/log15"
+)
+
+const (
+	gossipFrequency    = 250 * time.Millisecond
+	maxGossipBatchSize = 64
+	seenTxsCacheSize   = 8192
+
+	// Gossip messages are kept well below the message size limit of the
+	// network, a larger transaction is sent on its own
+	maxGossipBatchBytes = 512 * 1024
+)
+
+// GossipMessage is the payload of an AppGossip message, it carries a batch of
+// packed transactions encoded like Antelope does
+type GossipMessage struct {
+	Transactions []transaction.PackedTransaction
+}
+
+// Gossiper broadcasts transactions that were added to the mempool to the other
+// validators and adds the transactions they gossip to our own mempool
+type Gossiper struct {
+	vm        *VM
+	appSender common.AppSender
+
+	// IDs of transactions we have already sent or received, these are not
+	// gossiped again
+	seen *cache.LRU[transaction.TransactionIdType, struct{}]
+
+	stop       chan struct{}
+	doneGossip chan struct{}
+}
+
+func (vm *VM) NewGossiper(appSender common.AppSender) *Gossiper {
+	return &Gossiper{
+		vm:         vm,
+		appSender:  appSender,
+		seen:       &cache.LRU[transaction.TransactionIdType, struct{}]{Size: seenTxsCacheSize},
+		stop:       vm.stop,
+		doneGossip: vm.doneGossip,
+	}
+}
+
+// Gossip periodically broadcasts new mempool transactions until the VM stops
+func (g *Gossiper) Gossip() {
+	defer close(g.doneGossip)
+
+	ticker := time.NewTicker(gossipFrequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := g.GossipTxs(context.Background()); err != nil {
+				log.Warn("failed to gossip transactions", "error", err)
+			}
+		case <-g.stop:
+			return
+		}
+	}
+}
+
+// GossipTxs sends all transactions that were added to the mempool since the
+// last call and have not been seen before
+func (g *Gossiper) GossipTxs(ctx context.Context) error {
+	for {
+		txs := g.vm.mempool.NewTxs(maxGossipBatchSize)
+
+		if len(txs) == 0 {
+			return nil
+		}
+
+		// Nodes started without a sender, like the ones in tests, have no peers
+		if g.appSender == nil {
+			continue
+		}
+
+		batch := make([]transaction.PackedTransaction, 0, len(txs))
+		size := 0
+
+		for _, tx := range txs {
+			id, err := tx.ID()
+
+			if err != nil {
+				return err
+			}
+
+			if _, ok := g.seen.Get(*id); ok {
+				continue
+			}
+
+			encoded, err := rlp.EncodeToBytes(tx)
+
+			if err != nil {
+				return fmt.Errorf("failed to encode gossiped transaction: %w", err)
+			}
+
+			if len(batch) > 0 && size+len(encoded) > maxGossipBatchBytes {
+				if err := g.sendTxs(ctx, batch); err != nil {
+					return err
+				}
+
+				batch = batch[:0]
+				size = 0
+			}
+
+			g.seen.Put(*id, struct{}{})
+			batch = append(batch, *tx)
+			size += len(encoded)
+		}
+
+		if err := g.sendTxs(ctx, batch); err != nil {
+			return err
+		}
+	}
+}
+
+// sendTxs gossips [txs] in a single message
+func (g *Gossiper) sendTxs(ctx context.Context, txs []transaction.PackedTransaction) error {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	bytes, err := rlp.EncodeToBytes(&GossipMessage{Transactions: txs})
+
+	if err != nil {
+		return fmt.Errorf("failed to encode gossip message: %w", err)
+	}
+
+	log.Debug("gossiping transactions", "count", len(txs))
+
+	return g.appSender.SendAppGossip(ctx, bytes)
+}
+
+// HandleAppGossip validates the transactions gossiped by [nodeID] and adds the
+// ones we have not seen before to the mempool
+func (g *Gossiper) HandleAppGossip(ctx context.Context, nodeID ids.NodeID, bytes []byte) error {
+	// Read-only nodes do not accept transactions
+	if g.vm.controller.ReadOnly {
+		return nil
+	}
+
+	var msg GossipMessage
+
+	// Malformed gossip is dropped, returning an error would shut down the chain
+	if err := rlp.DecodeBytes(bytes, &msg); err != nil {
+		log.Debug("dropping malformed gossip message", "nodeID", nodeID, "error", err)
+		return nil
+	}
+
+	for i := range msg.Transactions {
+		tx := &msg.Transactions[i]
+
+		if err := tx.UnpackTransaction(); err != nil {
+			log.Debug("dropping gossiped transaction", "nodeID", nodeID, "error", err)
+			continue
+		}
+
+		id, err := tx.ID()
+
+		if err != nil {
+			log.Debug("dropping gossiped transaction", "nodeID", nodeID, "error", err)
+			continue
+		}
+
+		if _, ok := g.seen.Get(*id); ok {
+			continue
+		}
+
+		if err := g.validateTx(tx); err != nil {
+			log.Debug("dropping invalid gossiped transaction", "nodeID", nodeID, "id", id, "error", err)
+			continue
+		}
+
+		// Mark it as seen before adding it so we do not gossip it back
+		g.seen.Put(*id, struct{}{})
+		g.vm.mempool.Add(tx)
+	}
+
+	return nil
+}
+
+// validateTx makes sure [tx] can still be included on top of the last accepted
+// block and executes it speculatively, gossiped transactions are admitted to
+// the mempool under the same rules as the ones pushed through the API
+func (g *Gossiper) validateTx(tx *transaction.PackedTransaction) error {
+	session := g.vm.state.CreateSession(false)
+	defer session.Discard()
+
+	if err := g.vm.controller.ValidateTransaction(session, tx, chainTime.Now()); err != nil {
+		return err
+	}
+
+	_, err := g.vm.ComputeTransaction(tx, transaction.Input)
+
+	return err
+}
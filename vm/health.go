@@ -0,0 +1,71 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// maxAcceptedBlockAge is how long the chain may go without accepting a block
+// while there are transactions waiting in the mempool before it is unhealthy
+const maxAcceptedBlockAge = 30 * time.Second
+
+// HealthReport is returned by HealthCheck and shows up in the node's health API
+type HealthReport struct {
+	Bootstrapped       bool   `json:"bootstrapped"`
+	LastAcceptedHeight uint64 `json:"lastAcceptedHeight"`
+	LastAcceptedAge    string `json:"lastAcceptedAge"`
+	MempoolSize        int    `json:"mempoolSize"`
+	VerifiedBlocks     int    `json:"verifiedBlocks"`
+	DatabaseReachable  bool   `json:"databaseReachable"`
+	DatabaseError      string `json:"databaseError,omitempty"`
+}
+
+// HealthCheck returns a report about the state of this VM, an error is
+// returned alongside the report when the VM is unhealthy
+func (vm *VM) HealthCheck(ctx context.Context) (interface{}, error) {
+	report := HealthReport{
+		Bootstrapped:      vm.bootstrapped,
+		LastAcceptedAge:   time.Since(vm.lastAcceptedTime).Round(time.Millisecond).String(),
+		MempoolSize:       vm.mempool.Len(),
+		VerifiedBlocks:    len(vm.verifiedBlocks),
+		DatabaseReachable: true,
+	}
+
+	if err := vm.checkDatabase(ctx, &report); err != nil {
+		report.DatabaseReachable = false
+		report.DatabaseError = err.Error()
+
+		return report, fmt.Errorf("database is unreachable: %w", err)
+	}
+
+	if age := time.Since(vm.lastAcceptedTime); report.MempoolSize > 0 && age > maxAcceptedBlockAge {
+		return report, fmt.Errorf("no block accepted for %s while %d transactions are pending", age.Round(time.Second), report.MempoolSize)
+	}
+
+	return report, nil
+}
+
+// checkDatabase reads the last accepted block to make sure the database can
+// still be read from
+func (vm *VM) checkDatabase(ctx context.Context, report *HealthReport) error {
+	if vm.db.IsClosed() {
+		return fmt.Errorf("database is closed")
+	}
+
+	lastAccepted, err := vm.LastAccepted(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	block, err := vm.GetStoredBlock(ctx, lastAccepted)
+
+	if err != nil {
+		return err
+	}
+
+	report.LastAcceptedHeight = block.Height()
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package admin_api_plugin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/MetalBlockchain/antelopevm/vm/service"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	service.RegisterHandler("/v1/admin/get_whitelist_blacklist", service.Handler{
+		Methods:     []string{http.MethodGet, http.MethodPost},
+		HandlerFunc: GetWhitelistBlacklist,
+	})
+	service.RegisterHandler("/v1/admin/set_whitelist_blacklist", service.Handler{
+		Methods:     []string{http.MethodPost},
+		HandlerFunc: SetWhitelistBlacklist,
+	})
+}
+
+func GetWhitelistBlacklist(vm service.VM) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, vm.GetAccessLists())
+	}
+}
+
+// SetWhitelistBlacklist replaces all account and key lists, lists missing
+// from the request are cleared
+func SetWhitelistBlacklist(vm service.VM) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body service.AccessLists
+
+		if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+			c.JSON(400, "failed to decode body")
+			return
+		}
+
+		if err := vm.SetAccessLists(body); err != nil {
+			c.JSON(400, service.NewError(400, err.Error()))
+			return
+		}
+
+		c.JSON(200, vm.GetAccessLists())
+	}
+}
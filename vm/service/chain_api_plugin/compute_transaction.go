@@ -0,0 +1,53 @@
+package chain_api_plugin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/MetalBlockchain/antelopevm/vm/service"
+	"github.com/gin-gonic/gin"
+)
+
+type ComputeTransactionRequest struct {
+	Transaction transaction.PackedTransaction `json:"transaction"`
+}
+
+func init() {
+	service.RegisterHandler("/v1/chain/compute_transaction", service.Handler{
+		Methods:     []string{http.MethodPost},
+		HandlerFunc: ComputeTransaction(transaction.DryRun),
+	})
+	service.RegisterHandler("/v1/chain/send_read_only_transaction", service.Handler{
+		Methods:     []string{http.MethodPost},
+		HandlerFunc: ComputeTransaction(transaction.ReadOnly),
+	})
+}
+
+// ComputeTransaction executes a transaction against the head state and
+// returns its trace, none of its changes are kept
+func ComputeTransaction(trxType transaction.TransactionType) func(service.VM) gin.HandlerFunc {
+	return func(vm service.VM) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			var body ComputeTransactionRequest
+
+			if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+				c.JSON(400, "failed to decode body")
+				return
+			}
+
+			trace, err := vm.ComputeTransaction(&body.Transaction, trxType)
+
+			// A failed execution still has a trace telling where it failed
+			if trace == nil {
+				c.JSON(400, service.NewError(400, err.Error()))
+				return
+			}
+
+			c.JSON(200, PushTransactionResults{
+				TransactionId: trace.Hash.String(),
+				Processed:     *trace,
+			})
+		}
+	}
+}
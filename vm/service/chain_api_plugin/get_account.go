@@ -6,6 +6,7 @@ import (
 
 	"github.com/MetalBlockchain/antelopevm/chain/authority"
 	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/resource"
 	"github.com/MetalBlockchain/antelopevm/chain/time"
 	"github.com/MetalBlockchain/antelopevm/vm/service"
 	"github.com/gin-gonic/gin"
@@ -16,11 +17,21 @@ type GetAccountRequest struct {
 }
 
 type Limit struct {
-	Available           uint64 `json:"available"`
-	CurrentUsed         uint64 `json:"current_used"`
+	Available           int64  `json:"available"`
+	CurrentUsed         int64  `json:"current_used"`
 	LastUsageUpdateTime string `json:"last_usage_update_time"`
-	Max                 uint64 `json:"max"`
-	Used                uint64 `json:"used"`
+	Max                 int64  `json:"max"`
+	Used                int64  `json:"used"`
+}
+
+func newLimit(limit *resource.AccountResourceLimit) Limit {
+	return Limit{
+		Available:           limit.Available,
+		CurrentUsed:         limit.CurrentUsed,
+		LastUsageUpdateTime: limit.LastUsageUpdateTime.ToTimePoint().String(),
+		Max:                 limit.Max,
+		Used:                limit.Used,
+	}
 }
 
 type Resources struct {
@@ -39,17 +50,17 @@ type Permission struct {
 type GetAccountResponse struct {
 	AccountName       string       `json:"account_name"`
 	CpuLimit          Limit        `json:"cpu_limit"`
-	CpuWeight         uint64       `json:"cpu_weight"`
+	CpuWeight         int64        `json:"cpu_weight"`
 	Created           string       `json:"created"`
 	CoreLiquidBalance string       `json:"core_liquid_balance"`
 	HeadBlockNum      uint64       `json:"head_block_num"`
 	HeadBlockTime     string       `json:"head_block_time"`
 	LastCodeUpdate    string       `json:"last_code_update"`
 	NetLimit          Limit        `json:"net_limit"`
-	NetWeight         uint64       `json:"net_weight"`
+	NetWeight         int64        `json:"net_weight"`
 	Permissions       []Permission `json:"permissions"`
 	Privileged        bool         `json:"privileged"`
-	RamQuota          uint64       `json:"ram_quota"`
+	RamQuota          int64        `json:"ram_quota"`
 	RamUsage          uint64       `json:"ram_usage"`
 	TotalResources    Resources    `json:"total_resources"`
 }
@@ -97,33 +108,50 @@ func GetAccount(vm service.VM) gin.HandlerFunc {
 			return
 		}
 
+		resourceLimits := vm.GetController().GetResourceLimitsManager(session)
+		var ramBytes, netWeight, cpuWeight int64
+
+		if err := resourceLimits.GetAccountLimits(acc.Name, &ramBytes, &netWeight, &cpuWeight); err != nil {
+			c.JSON(500, service.NewError(500, "failed to get account limits"))
+			return
+		}
+
+		cpuLimit, err := resourceLimits.GetAccountCpuLimit(acc.Name)
+
+		if err != nil {
+			c.JSON(500, service.NewError(500, "failed to get account cpu limit"))
+			return
+		}
+
+		netLimit, err := resourceLimits.GetAccountNetLimit(acc.Name)
+
+		if err != nil {
+			c.JSON(500, service.NewError(500, "failed to get account net limit"))
+			return
+		}
+
+		usage, err := session.FindResourceUsageByOwner(acc.Name)
+
+		if err != nil {
+			c.JSON(500, service.NewError(500, "failed to get account usage"))
+			return
+		}
+
 		response := GetAccountResponse{
-			AccountName: body.AccountName,
-			CpuLimit: Limit{
-				Available:           16346040,
-				CurrentUsed:         0,
-				LastUsageUpdateTime: time.Now().String(),
-				Max:                 16346421,
-				Used:                381,
-			},
-			CpuWeight:         500000,
+			AccountName:       body.AccountName,
+			CpuLimit:          newLimit(cpuLimit),
+			CpuWeight:         cpuWeight,
 			Created:           acc.CreationDate.ToTimePoint().String(),
 			CoreLiquidBalance: "1000.0000 SYS",
 			HeadBlockNum:      0,
 			HeadBlockTime:     time.Now().String(),
 			LastCodeUpdate:    accountMetaData.LastCodeUpdate.String(),
-			NetLimit: Limit{
-				Available:           88094630,
-				CurrentUsed:         0,
-				LastUsageUpdateTime: time.Now().String(),
-				Max:                 88094878,
-				Used:                248,
-			},
-			NetWeight:   500000,
-			Permissions: make([]Permission, 0),
-			Privileged:  accountMetaData.IsPrivileged(),
-			RamQuota:    525686,
-			RamUsage:    5544,
+			NetLimit:          newLimit(netLimit),
+			NetWeight:         netWeight,
+			Permissions:       make([]Permission, 0),
+			Privileged:        accountMetaData.IsPrivileged(),
+			RamQuota:          ramBytes,
+			RamUsage:          usage.RamUsage,
 			TotalResources: Resources{
 				CpuWeight: "50.0000 SYS",
 				NetWeight: "50.0000 SYS",
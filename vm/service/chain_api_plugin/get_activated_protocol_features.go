@@ -1,16 +1,41 @@
 package chain_api_plugin
 
 import (
+	"encoding/json"
 	"net/http"
 
-	"github.com/MetalBlockchain/antelopevm/chain/protocol"
+	"github.com/MetalBlockchain/antelopevm/chain"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
 	"github.com/MetalBlockchain/antelopevm/vm/service"
 	"github.com/gin-gonic/gin"
 )
 
+type GetActivatedProtocolFeaturesRequest struct {
+	LowerBound       *uint32 `json:"lower_bound"`
+	UpperBound       *uint32 `json:"upper_bound"`
+	Limit            uint32  `json:"limit"`
+	SearchByBlockNum bool    `json:"search_by_block_num"`
+	Reverse          bool    `json:"reverse"`
+}
+
+type ProtocolFeatureSpecification struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type ActivatedProtocolFeature struct {
+	FeatureDigest       types.DigestType               `json:"feature_digest"`
+	ActivationOrdinal   uint32                         `json:"activation_ordinal"`
+	ActivationBlockNum  uint32                         `json:"activation_block_num"`
+	DescriptionDigest   types.DigestType               `json:"description_digest"`
+	Dependencies        []types.DigestType             `json:"dependencies"`
+	ProtocolFeatureType string                         `json:"protocol_feature_type"`
+	Specification       []ProtocolFeatureSpecification `json:"specification"`
+}
+
 type GetActivatedProtocolFeaturesResults struct {
-	ActivatedProtocolFeatures []protocol.ProtocolFeature `json:"activated_protocol_features"`
-	More                      uint32                     `json:"more"`
+	ActivatedProtocolFeatures []ActivatedProtocolFeature `json:"activated_protocol_features"`
+	More                      *uint32                    `json:"more,omitempty"`
 }
 
 func init() {
@@ -22,10 +47,74 @@ func init() {
 
 func GetActivatedProtocolFeatures(vm service.VM) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		body := GetActivatedProtocolFeaturesRequest{Limit: 10}
+		json.NewDecoder(c.Request.Body).Decode(&body)
+
+		if body.LowerBound != nil && body.UpperBound != nil && *body.LowerBound > *body.UpperBound {
+			c.JSON(400, service.NewError(400, "upper bound must be greater than or equal to lower bound"))
+			return
+		}
+
+		session := vm.GetState().CreateSession(false)
+		defer session.Discard()
+		activated, err := vm.GetController().GetProtocolFeatureManager(session).GetActivatedFeatures()
+
+		if err != nil {
+			c.JSON(500, service.NewError(500, "failed to get activated protocol features"))
+			return
+		}
+
 		result := GetActivatedProtocolFeaturesResults{
-			ActivatedProtocolFeatures: make([]protocol.ProtocolFeature, 0),
-			More:                      0,
+			ActivatedProtocolFeatures: make([]ActivatedProtocolFeature, 0),
+		}
+
+		for i := range activated {
+			ordinal := uint32(i)
+
+			if body.Reverse {
+				ordinal = uint32(len(activated) - 1 - i)
+			}
+
+			// Bounds apply to either the activation ordinal or block number
+			key := ordinal
+			if body.SearchByBlockNum {
+				key = activated[ordinal].ActivationBlockNum
+			}
+
+			if (body.LowerBound != nil && key < *body.LowerBound) || (body.UpperBound != nil && key > *body.UpperBound) {
+				continue
+			}
+
+			if uint32(len(result.ActivatedProtocolFeatures)) >= body.Limit {
+				result.More = &key
+				break
+			}
+
+			result.ActivatedProtocolFeatures = append(result.ActivatedProtocolFeatures, newActivatedProtocolFeature(activated[ordinal].FeatureDigest, ordinal, activated[ordinal].ActivationBlockNum))
 		}
+
 		c.JSON(200, result)
 	}
 }
+
+func newActivatedProtocolFeature(digest types.DigestType, ordinal uint32, blockNum uint32) ActivatedProtocolFeature {
+	feature := ActivatedProtocolFeature{
+		FeatureDigest:       digest,
+		ActivationOrdinal:   ordinal,
+		ActivationBlockNum:  blockNum,
+		Dependencies:        make([]types.DigestType, 0),
+		ProtocolFeatureType: "builtin",
+		Specification:       make([]ProtocolFeatureSpecification, 0),
+	}
+
+	if recognized := chain.GetRecognizedProtocolFeature(digest); recognized != nil {
+		feature.DescriptionDigest = recognized.DescriptionDigest
+		feature.Dependencies = append(feature.Dependencies, recognized.Dependencies...)
+		feature.Specification = append(feature.Specification, ProtocolFeatureSpecification{
+			Name:  "builtin_feature_codename",
+			Value: chain.BuiltinProtocolFeatureCodenames[recognized.CodeName].CodeName,
+		})
+	}
+
+	return feature
+}
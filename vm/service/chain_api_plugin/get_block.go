@@ -33,31 +33,49 @@ func (fi *BlockNumOrId) UnmarshalJSON(b []byte) error {
 }
 
 type GetBlockRequest struct {
-	BlockNumOrId BlockNumOrId `json:"block_num_or_id"`
+	BlockNumOrId         BlockNumOrId `json:"block_num_or_id"`
+	PruneContextFreeData bool         `json:"prune_context_free_data"`
 }
 
 type GetBlockResponse struct {
-	Timestamp    string                           `json:"timestamp"`
-	Producer     string                           `json:"producer"`
-	Confirmed    int                              `json:"confirmed"`
-	Previous     string                           `json:"previous"`
-	ID           string                           `json:"id"`
-	BlockNum     uint64                           `json:"block_num"`
-	Transactions []transaction.TransactionReceipt `json:"transactions"`
+	Timestamp             string                                    `json:"timestamp"`
+	Producer              string                                    `json:"producer"`
+	Confirmed             int                                       `json:"confirmed"`
+	Previous              string                                    `json:"previous"`
+	ID                    string                                    `json:"id"`
+	BlockNum              uint64                                    `json:"block_num"`
+	ScheduledTransactions []transaction.ScheduledTransactionReceipt `json:"scheduled_transactions"`
+	Transactions          []transaction.TransactionReceipt          `json:"transactions"`
 }
 
-func NewGetBlockResponse(block *state.Block) GetBlockResponse {
+func NewGetBlockResponse(block *state.Block, pruneContextFreeData bool) (GetBlockResponse, error) {
+	scheduled := append([]transaction.ScheduledTransactionReceipt{}, block.ScheduledTransactions...)
 	transactions := append([]transaction.TransactionReceipt{}, block.Transactions...)
 
-	return GetBlockResponse{
-		Timestamp:    block.Header.Timestamp.ToTimePoint().String(),
-		Producer:     "eosio",
-		Confirmed:    1,
-		Previous:     block.Header.Previous.String(),
-		ID:           block.ID().Hex(),
-		BlockNum:     uint64(block.Header.BlockNum()),
-		Transactions: transactions,
+	// Pruned transactions keep the digest of their context free data so the
+	// receipts still add up to the transaction merkle root
+	if pruneContextFreeData {
+		for i := range transactions {
+			pruned, err := transactions[i].Transaction.PruneContextFreeData()
+
+			if err != nil {
+				return GetBlockResponse{}, err
+			}
+
+			transactions[i].Transaction = *pruned
+		}
 	}
+
+	return GetBlockResponse{
+		Timestamp:             block.Header.Timestamp.ToTimePoint().String(),
+		Producer:              "eosio",
+		Confirmed:             1,
+		Previous:              block.Header.Previous.String(),
+		ID:                    block.ID().Hex(),
+		BlockNum:              uint64(block.Header.BlockNum()),
+		ScheduledTransactions: scheduled,
+		Transactions:          transactions,
+	}, nil
 }
 
 func init() {
@@ -83,7 +101,7 @@ func GetBlock(vm service.VM) gin.HandlerFunc {
 				return
 			}
 
-			c.JSON(200, NewGetBlockResponse(block))
+			writeGetBlockResponse(c, block, body.PruneContextFreeData)
 			return
 		}
 
@@ -108,6 +126,17 @@ func GetBlock(vm service.VM) gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(200, NewGetBlockResponse(block))
+		writeGetBlockResponse(c, block, body.PruneContextFreeData)
+	}
+}
+
+func writeGetBlockResponse(c *gin.Context, block *state.Block, pruneContextFreeData bool) {
+	response, err := NewGetBlockResponse(block, pruneContextFreeData)
+
+	if err != nil {
+		c.JSON(500, service.NewError(500, "failed to prune context free data"))
+		return
 	}
+
+	c.JSON(200, response)
 }
@@ -32,8 +32,8 @@ func NewGetBlockInfoResponse(block *state.Block) GetBlockInfoResponse {
 		Previous:       block.Header.Previous.String(),
 		ID:             block.Hash.Hex(),
 		BlockNum:       uint64(block.Header.BlockNum()),
-		RefBlockNum:    uint64(block.Header.BlockNum()),
-		RefBlockPrefix: uint64(block.Header.BlockNum()),
+		RefBlockNum:    uint64(uint16(block.Header.BlockNum())),
+		RefBlockPrefix: uint64(block.Hash.RefBlockPrefix()),
 	}
 }
 
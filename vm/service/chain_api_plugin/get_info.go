@@ -59,9 +59,25 @@ func NewChainInfoResponse(version string, lastAcceptedBlock *state.Block, chainI
 func GetInfo(vm service.VM) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		version := "aaa"
+		session := vm.GetState().CreateSession(false)
+		defer session.Discard()
+
 		lastAcceptedId, _ := vm.LastAccepted(context.Background())
-		lastAccepted, _ := vm.GetState().CreateSession(false).FindBlockByHash(block.BlockHash(lastAcceptedId))
-		info := NewChainInfoResponse(version, lastAccepted, vm.GetController().ChainId)
+		lastAccepted, err := session.FindBlockByHash(block.BlockHash(lastAcceptedId))
+
+		if err != nil {
+			c.JSON(500, service.NewError(500, "could not find last accepted block"))
+			return
+		}
+
+		gpo, err := session.FindGlobalPropertyObject(0)
+
+		if err != nil {
+			c.JSON(500, service.NewError(500, "could not find global properties"))
+			return
+		}
+
+		info := NewChainInfoResponse(version, lastAccepted, gpo.ChainId)
 
 		c.JSON(200, info)
 	}
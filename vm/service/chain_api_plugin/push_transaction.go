@@ -1,9 +1,11 @@
 package chain_api_plugin
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 
+	"github.com/MetalBlockchain/antelopevm/chain/time"
 	"github.com/MetalBlockchain/antelopevm/chain/transaction"
 	"github.com/MetalBlockchain/antelopevm/vm/service"
 	"github.com/gin-gonic/gin"
@@ -14,6 +16,15 @@ type PushTransactionResults struct {
 	Processed     transaction.TransactionTrace `json:"processed"`
 }
 
+type SendTransaction2Request struct {
+	// ReturnFailureTrace returns the trace of a failed transaction instead
+	// of an error
+	ReturnFailureTrace bool `json:"return_failure_trace"`
+	// RetryTrx waits until the transaction is included in an accepted block
+	RetryTrx    bool                          `json:"retry_trx"`
+	Transaction transaction.PackedTransaction `json:"transaction"`
+}
+
 func init() {
 	service.RegisterHandler("/v1/chain/send_transaction", service.Handler{
 		Methods:     []string{http.MethodPost},
@@ -23,6 +34,10 @@ func init() {
 		Methods:     []string{http.MethodPost},
 		HandlerFunc: PushTransaction,
 	})
+	service.RegisterHandler("/v1/chain/send_transaction2", service.Handler{
+		Methods:     []string{http.MethodPost},
+		HandlerFunc: SendTransaction2,
+	})
 }
 
 func PushTransaction(vm service.VM) gin.HandlerFunc {
@@ -34,24 +49,80 @@ func PushTransaction(vm service.VM) gin.HandlerFunc {
 			return
 		}
 
-		if ok := vm.GetMempool().Add(&trx); !ok {
-			c.JSON(400, "could not submit trx")
+		sendTransaction(vm, c, &trx, false, false)
+	}
+}
+
+func SendTransaction2(vm service.VM) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := SendTransaction2Request{ReturnFailureTrace: true}
+
+		if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+			c.JSON(400, "failed to decode body")
 			return
 		}
 
-		id, _ := trx.ID()
-
-		c.JSON(202, PushTransactionResults{
-			TransactionId: id.String(),
-			Processed: transaction.TransactionTrace{
-				Hash: *id,
-				Receipt: transaction.TransactionReceipt{
-					TransactionReceiptHeader: transaction.TransactionReceiptHeader{
-						Status: transaction.TransactionStatusExecuted,
-					},
-				},
-				ActionTraces: make([]transaction.ActionTrace, 0),
-			},
-		})
+		sendTransaction(vm, c, &body.Transaction, body.ReturnFailureTrace, body.RetryTrx)
+	}
+}
+
+// sendTransaction executes [trx] speculatively and only submits it to the
+// mempool when it succeeds, the trace of the speculative execution is returned
+// unless [wait] is set, then the caller gets the trace recorded in the block
+func sendTransaction(vm service.VM, c *gin.Context, trx *transaction.PackedTransaction, returnFailureTrace bool, wait bool) {
+	if vm.GetController().ReadOnly {
+		c.JSON(400, "node is in read-only mode")
+		return
 	}
+
+	session := vm.GetState().CreateSession(false)
+	defer session.Discard()
+
+	// Reject transactions that could never make it into a block early
+	if err := vm.GetController().ValidateTransaction(session, trx, time.Now()); err != nil {
+		c.JSON(400, service.NewError(400, err.Error()))
+		return
+	}
+
+	trace, err := vm.ComputeTransaction(trx, transaction.Input)
+
+	if err != nil {
+		if trace != nil && returnFailureTrace {
+			c.JSON(200, PushTransactionResults{TransactionId: trace.Hash.String(), Processed: *trace})
+		} else {
+			c.JSON(400, service.NewTransactionError(400, err))
+		}
+
+		return
+	}
+
+	unpacked, err := trx.GetTransaction()
+
+	if err != nil {
+		c.JSON(400, service.NewError(400, err.Error()))
+		return
+	}
+
+	// Nobody waits for a transaction that can no longer be included
+	ctx, cancel := context.WithDeadline(c.Request.Context(), unpacked.Expiration.ToTimePoint().ToTime())
+	defer cancel()
+
+	if err := vm.SubmitTransaction(ctx, trx, wait); err != nil {
+		c.JSON(400, service.NewError(400, err.Error()))
+		return
+	}
+
+	if wait {
+		session := vm.GetState().CreateSession(false)
+		defer session.Discard()
+
+		if included, err := session.FindTransactionByHash(trace.Hash); err == nil {
+			trace = included
+		}
+	}
+
+	c.JSON(http.StatusOK, PushTransactionResults{
+		TransactionId: trace.Hash.String(),
+		Processed:     *trace,
+	})
 }
@@ -1,8 +1,11 @@
 package service
 
+import "github.com/MetalBlockchain/antelopevm/chain/transaction"
+
 type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    int                    `json:"code"`
+	Message string                 `json:"message"`
+	Error   *transaction.Exception `json:"error,omitempty"`
 }
 
 func NewError(code int, message string) ErrorResponse {
@@ -11,3 +14,12 @@ func NewError(code int, message string) ErrorResponse {
 		Message: message,
 	}
 }
+
+// NewTransactionError describes the error that made a transaction fail
+func NewTransactionError(code int, err error) ErrorResponse {
+	return ErrorResponse{
+		Code:    code,
+		Message: err.Error(),
+		Error:   transaction.NewException(err),
+	}
+}
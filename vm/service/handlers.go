@@ -1,6 +1,9 @@
 package service
 
 import (
+	"sort"
+	"strings"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -22,3 +25,33 @@ func RegisterHandler(path string, handler Handler) {
 func GetHandlers() map[string]Handler {
 	return handlers
 }
+
+// PluginName returns the API plugin serving [path], handlers under
+// /v1/chain/ belong to the chain_api_plugin
+func PluginName(path string) string {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 3)
+
+	if len(parts) < 3 {
+		return ""
+	}
+
+	return parts[1] + "_api_plugin"
+}
+
+// GetPlugins returns the sorted names of all plugins with registered handlers
+func GetPlugins() []string {
+	plugins := make([]string, 0)
+
+	for path := range handlers {
+		plugin := PluginName(path)
+		i := sort.SearchStrings(plugins, plugin)
+
+		if i == len(plugins) || plugins[i] != plugin {
+			plugins = append(plugins, "")
+			copy(plugins[i+1:], plugins[i:])
+			plugins[i] = plugin
+		}
+	}
+
+	return plugins
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/MetalBlockchain/antelopevm/chain"
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
 	"github.com/MetalBlockchain/antelopevm/mempool"
 	"github.com/MetalBlockchain/antelopevm/state"
 	"github.com/MetalBlockchain/metalgo/ids"
@@ -14,4 +15,18 @@ type VM interface {
 	GetController() *chain.Controller
 	GetMempool() *mempool.Mempool
 	LastAccepted(ctx context.Context) (ids.ID, error)
+	ComputeTransaction(trx *transaction.PackedTransaction, trxType transaction.TransactionType) (*transaction.TransactionTrace, error)
+	SubmitTransaction(ctx context.Context, trx *transaction.PackedTransaction, wait bool) error
+	GetAccessLists() AccessLists
+	SetAccessLists(lists AccessLists) error
+}
+
+// AccessLists are the node-local account and key lists in the form they are
+// configured in
+type AccessLists struct {
+	ActorWhitelist    []string `json:"actor_whitelist"`
+	ActorBlacklist    []string `json:"actor_blacklist"`
+	ContractWhitelist []string `json:"contract_whitelist"`
+	ContractBlacklist []string `json:"contract_blacklist"`
+	KeyBlacklist      []string `json:"key_blacklist"`
 }
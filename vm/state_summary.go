@@ -0,0 +1,78 @@
+package vm
+
+import (
+	"context"
+
+	"github.com/MetalBlockchain/antelopevm/crypto"
+	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
+	"github.com/MetalBlockchain/metalgo/ids"
+	"github.com/MetalBlockchain/metalgo/snow/engine/snowman/block"
+)
+
+var _ block.StateSummary = &StateSummary{}
+
+// StateSummary describes the state of the chain after the block at
+// [SummaryHeight] was accepted, nodes use it to sync to that state without
+// executing all blocks that came before it
+type StateSummary struct {
+	SummaryHeight uint64
+	StateRoot     crypto.Sha256
+	BlockBytes    []byte
+
+	vm    *VM
+	id    ids.ID
+	bytes []byte
+}
+
+func (vm *VM) newStateSummary(height uint64, root crypto.Sha256, blockBytes []byte) (*StateSummary, error) {
+	summary := &StateSummary{
+		SummaryHeight: height,
+		StateRoot:     root,
+		BlockBytes:    blockBytes,
+	}
+	bytes, err := rlp.EncodeToBytes(summary)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return vm.initStateSummary(summary, bytes), nil
+}
+
+func (vm *VM) parseStateSummary(bytes []byte) (*StateSummary, error) {
+	summary := &StateSummary{}
+
+	if err := rlp.DecodeBytes(bytes, summary); err != nil {
+		return nil, err
+	}
+
+	return vm.initStateSummary(summary, bytes), nil
+}
+
+func (vm *VM) initStateSummary(summary *StateSummary, bytes []byte) *StateSummary {
+	summary.vm = vm
+	summary.bytes = bytes
+	hash := crypto.NewSha256()
+	hash.Write(bytes)
+	copy(summary.id[:], hash.Sum(nil))
+
+	return summary
+}
+
+func (s *StateSummary) ID() ids.ID {
+	return s.id
+}
+
+func (s *StateSummary) Height() uint64 {
+	return s.SummaryHeight
+}
+
+func (s *StateSummary) Bytes() []byte {
+	return s.bytes
+}
+
+// Accept starts syncing to this summary, it is skipped when this node is
+// already past it
+func (s *StateSummary) Accept(ctx context.Context) (block.StateSyncMode, error) {
+	return s.vm.acceptStateSummary(ctx, s)
+}
@@ -0,0 +1,422 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
+	"github.com/MetalBlockchain/antelopevm/state"
+	"github.com/MetalBlockchain/metalgo/database"
+	"github.com/MetalBlockchain/metalgo/ids"
+	"github.com/MetalBlockchain/metalgo/snow/engine/common"
+	"github.com/MetalBlockchain/metalgo/snow/engine/snowman/block"
+	"github.com/MetalBlockchain/metalgo/utils/set"
+	"github.com/dgraph-io/badger/v3"
+	log "github.com/inconshreveable/log15"
+)
+
+const (
+	stateChunkSize          = 512 * 1024
+	stateSyncRequestTimeout = 10 * time.Second
+	maxStateSyncAttempts    = 32
+)
+
+var (
+	_ block.StateSyncableVM = &VM{}
+
+	errNoStateSyncPeers = errors.New("no peers to sync state from")
+)
+
+// StateChunkRequest asks a peer for the synced keys of the summary at [Height]
+// starting at [Start]
+type StateChunkRequest struct {
+	Height uint64
+	Start  []byte
+}
+
+// StateChunkResponse is sent back for a StateChunkRequest, [Available] is false
+// when the peer can no longer serve the summary
+type StateChunkResponse struct {
+	Available bool
+	Keys      [][]byte
+	Values    [][]byte
+	Next      []byte
+}
+
+// stateSyncServer serves chunks of the last state summary produced by this node
+type stateSyncServer struct {
+	lock     sync.Mutex
+	summary  *StateSummary
+	snapshot *state.Snapshot
+
+	// Summaries are produced in the background, they are waited for on shutdown
+	producers sync.WaitGroup
+}
+
+// stateSyncClient keeps track of the peers and the requests made while syncing
+type stateSyncClient struct {
+	lock          sync.Mutex
+	peers         set.Set[ids.NodeID]
+	nextRequestID uint32
+	requests      map[uint32]chan []byte
+
+	// Set when syncing failed, bootstrapping is not started after that
+	err error
+}
+
+func (c *stateSyncClient) setErr(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.err = err
+}
+
+func (c *stateSyncClient) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.err
+}
+
+func (vm *VM) StateSyncEnabled(ctx context.Context) (bool, error) {
+	return vm.stateSyncEnabled, nil
+}
+
+// GetOngoingSyncStateSummary returns the summary this node was syncing to
+// before it was stopped
+func (vm *VM) GetOngoingSyncStateSummary(ctx context.Context) (block.StateSummary, error) {
+	bytes, err := vm.state.GetOngoingStateSummary()
+
+	if err == badger.ErrKeyNotFound {
+		return nil, database.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return vm.parseStateSummary(bytes)
+}
+
+// GetLastStateSummary returns the last summary this node is able to serve
+func (vm *VM) GetLastStateSummary(ctx context.Context) (block.StateSummary, error) {
+	vm.syncServer.lock.Lock()
+	defer vm.syncServer.lock.Unlock()
+
+	if vm.syncServer.summary == nil {
+		return nil, database.ErrNotFound
+	}
+
+	return vm.syncServer.summary, nil
+}
+
+func (vm *VM) ParseStateSummary(ctx context.Context, summaryBytes []byte) (block.StateSummary, error) {
+	return vm.parseStateSummary(summaryBytes)
+}
+
+// GetStateSummary returns the summary produced at [summaryHeight], it is used by
+// syncing nodes to check that validators agree on a summary
+func (vm *VM) GetStateSummary(ctx context.Context, summaryHeight uint64) (block.StateSummary, error) {
+	bytes, err := vm.state.GetStateSummary(summaryHeight)
+
+	if err == badger.ErrKeyNotFound {
+		return nil, database.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return vm.parseStateSummary(bytes)
+}
+
+// produceStateSummary takes a snapshot of the state right after [blk] was
+// accepted, the summary is computed from it in the background so accepting
+// blocks does not wait for the whole state to be hashed
+func (vm *VM) produceStateSummary(blk *state.Block) {
+	if vm.stateSummaryInterval == 0 || blk.Height()%vm.stateSummaryInterval != 0 {
+		return
+	}
+
+	snapshot := vm.state.NewSnapshot()
+	vm.syncServer.producers.Add(1)
+
+	go func() {
+		defer vm.syncServer.producers.Done()
+
+		// The block is accepted at this point, failing to produce a summary
+		// only means this node cannot serve it
+		if err := vm.storeStateSummary(blk, snapshot); err != nil {
+			log.Error("failed to produce state summary", "height", blk.Height(), "error", err)
+		}
+	}()
+}
+
+// storeStateSummary stores the summary of [snapshot] and keeps the snapshot
+// around to serve it, unless a later summary was stored in the meantime
+func (vm *VM) storeStateSummary(blk *state.Block, snapshot *state.Snapshot) error {
+	root, err := snapshot.Root()
+
+	if err != nil {
+		snapshot.Discard()
+		return err
+	}
+
+	summary, err := vm.newStateSummary(blk.Height(), root, blk.Bytes())
+
+	if err != nil {
+		snapshot.Discard()
+		return err
+	}
+
+	if err := vm.state.PutStateSummary(summary.Height(), summary.Bytes()); err != nil {
+		snapshot.Discard()
+		return err
+	}
+
+	vm.syncServer.lock.Lock()
+	defer vm.syncServer.lock.Unlock()
+
+	if vm.syncServer.summary != nil && vm.syncServer.summary.Height() > summary.Height() {
+		snapshot.Discard()
+		return nil
+	}
+
+	if vm.syncServer.snapshot != nil {
+		vm.syncServer.snapshot.Discard()
+	}
+
+	vm.syncServer.summary = summary
+	vm.syncServer.snapshot = snapshot
+	log.Info("produced state summary", "height", summary.Height(), "root", root)
+
+	return nil
+}
+
+// handleStateChunkRequest answers a StateChunkRequest from [nodeID]
+func (vm *VM) handleStateChunkRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, request []byte) error {
+	var req StateChunkRequest
+
+	// Malformed requests are dropped, returning an error would shut down the chain
+	if err := rlp.DecodeBytes(request, &req); err != nil {
+		log.Debug("dropping malformed state chunk request", "nodeID", nodeID, "error", err)
+		return nil
+	}
+
+	response := StateChunkResponse{}
+	vm.syncServer.lock.Lock()
+
+	if vm.syncServer.summary != nil && vm.syncServer.summary.Height() == req.Height {
+		chunk, err := vm.syncServer.snapshot.Chunk(req.Start, stateChunkSize)
+
+		if err != nil {
+			vm.syncServer.lock.Unlock()
+			return fmt.Errorf("failed to read state chunk: %w", err)
+		}
+
+		response = StateChunkResponse{
+			Available: true,
+			Keys:      chunk.Keys,
+			Values:    chunk.Values,
+			Next:      chunk.Next,
+		}
+	}
+
+	vm.syncServer.lock.Unlock()
+	bytes, err := rlp.EncodeToBytes(&response)
+
+	if err != nil {
+		return err
+	}
+
+	return vm.appSender.SendAppResponse(ctx, nodeID, requestID, bytes)
+}
+
+// handleStateChunkResponse hands [response] to the request waiting for it, a
+// nil response means the request failed
+func (vm *VM) handleStateChunkResponse(requestID uint32, response []byte) {
+	vm.syncClient.lock.Lock()
+	defer vm.syncClient.lock.Unlock()
+
+	if ch, ok := vm.syncClient.requests[requestID]; ok {
+		delete(vm.syncClient.requests, requestID)
+		ch <- response
+	}
+}
+
+// acceptStateSummary starts syncing to [summary] in the background, the engine
+// is notified once the sync has finished
+func (vm *VM) acceptStateSummary(ctx context.Context, summary *StateSummary) (block.StateSyncMode, error) {
+	lastAcceptedID, err := vm.LastAccepted(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	lastAccepted, err := vm.GetStoredBlock(ctx, lastAcceptedID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if summary.Height() <= lastAccepted.Height() {
+		log.Info("skipping state sync", "summaryHeight", summary.Height(), "lastAcceptedHeight", lastAccepted.Height())
+		return block.StateSyncSkipped, nil
+	}
+
+	if err := vm.state.PutOngoingStateSummary(summary.Bytes()); err != nil {
+		return 0, err
+	}
+
+	log.Info("starting state sync", "height", summary.Height(), "root", summary.StateRoot)
+
+	go func() {
+		if err := vm.syncState(summary); err != nil {
+			log.Error("state sync failed", "height", summary.Height(), "error", err)
+			vm.syncClient.setErr(err)
+		}
+
+		vm.toEngine <- common.StateSyncDone
+	}()
+
+	return block.StateSyncStatic, nil
+}
+
+// syncState fetches the state of [summary] from our peers, checks it against
+// the state root and makes the summary block our last accepted block
+func (vm *VM) syncState(summary *StateSummary) error {
+	blk := &state.Block{}
+
+	if _, err := state.Codec.Unmarshal(summary.BlockBytes, blk); err != nil {
+		return fmt.Errorf("failed to parse summary block: %w", err)
+	}
+
+	blk.Initialize(vm)
+	writer, err := vm.state.NewStateSyncWriter()
+
+	if err != nil {
+		return err
+	}
+
+	next := []byte{}
+
+	for {
+		response, err := vm.requestStateChunk(StateChunkRequest{Height: summary.Height(), Start: next})
+
+		if err != nil {
+			writer.Cancel()
+			return err
+		}
+
+		if err := writer.Write(&state.StateChunk{Keys: response.Keys, Values: response.Values}); err != nil {
+			writer.Cancel()
+			return err
+		}
+
+		if len(response.Next) == 0 {
+			break
+		}
+
+		next = response.Next
+	}
+
+	vm.ctx.Lock.Lock()
+	defer vm.ctx.Lock.Unlock()
+
+	if err := writer.Commit(summary.StateRoot, blk); err != nil {
+		return err
+	}
+
+	if err := vm.state.DeleteOngoingStateSummary(); err != nil {
+		return err
+	}
+
+	vm.lastAcceptedTime = time.Now()
+	log.Info("state sync finished", "height", summary.Height(), "block", blk.ID())
+
+	return vm.SetPreference(context.Background(), blk.ID())
+}
+
+// requestStateChunk sends [request] to random peers until one of them answers
+func (vm *VM) requestStateChunk(request StateChunkRequest) (*StateChunkResponse, error) {
+	bytes, err := rlp.EncodeToBytes(&request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < maxStateSyncAttempts; attempt++ {
+		nodeID, ok := vm.randomPeer()
+
+		if !ok {
+			time.Sleep(time.Second)
+			continue
+		}
+
+		response, err := vm.sendStateChunkRequest(nodeID, bytes)
+
+		if err != nil {
+			log.Debug("state chunk request failed", "nodeID", nodeID, "error", err)
+			continue
+		}
+
+		return response, nil
+	}
+
+	return nil, errNoStateSyncPeers
+}
+
+func (vm *VM) sendStateChunkRequest(nodeID ids.NodeID, request []byte) (*StateChunkResponse, error) {
+	ch := make(chan []byte, 1)
+	vm.syncClient.lock.Lock()
+	requestID := vm.syncClient.nextRequestID
+	vm.syncClient.nextRequestID++
+	vm.syncClient.requests[requestID] = ch
+	vm.syncClient.lock.Unlock()
+
+	nodeIDs := set.NewSet[ids.NodeID](1)
+	nodeIDs.Add(nodeID)
+
+	if err := vm.appSender.SendAppRequest(context.Background(), nodeIDs, requestID, request); err != nil {
+		vm.handleStateChunkResponse(requestID, nil)
+		return nil, err
+	}
+
+	var bytes []byte
+
+	select {
+	case bytes = <-ch:
+	case <-time.After(stateSyncRequestTimeout):
+		vm.handleStateChunkResponse(requestID, nil)
+		return nil, fmt.Errorf("request timed out")
+	}
+
+	if bytes == nil {
+		return nil, fmt.Errorf("request failed")
+	}
+
+	response := &StateChunkResponse{}
+
+	if err := rlp.DecodeBytes(bytes, response); err != nil {
+		return nil, err
+	}
+
+	if !response.Available {
+		return nil, fmt.Errorf("peer cannot serve the requested summary")
+	}
+
+	return response, nil
+}
+
+func (vm *VM) randomPeer() (ids.NodeID, bool) {
+	vm.syncClient.lock.Lock()
+	defer vm.syncClient.lock.Unlock()
+
+	peers := vm.syncClient.peers.List()
+
+	if len(peers) == 0 {
+		return ids.NodeID{}, false
+	}
+
+	return peers[rand.Intn(len(peers))], true
+}
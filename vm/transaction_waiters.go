@@ -0,0 +1,80 @@
+package vm
+
+import (
+	"sync"
+
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/MetalBlockchain/antelopevm/state"
+	log "github.com/inconshreveable/log15"
+)
+
+// transactionWaiters lets API calls wait until their transaction is included
+// in an accepted block
+type transactionWaiters struct {
+	lock    sync.Mutex
+	waiters map[transaction.TransactionIdType][]chan struct{}
+}
+
+func newTransactionWaiters() *transactionWaiters {
+	return &transactionWaiters{
+		waiters: make(map[transaction.TransactionIdType][]chan struct{}),
+	}
+}
+
+// register returns a channel that is closed once [id] is accepted, it has to
+// be called before the transaction is submitted so the acceptance is not missed
+func (w *transactionWaiters) register(id transaction.TransactionIdType) chan struct{} {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	done := make(chan struct{})
+	w.waiters[id] = append(w.waiters[id], done)
+
+	return done
+}
+
+// unregister drops [done] when its caller stopped waiting
+func (w *transactionWaiters) unregister(id transaction.TransactionIdType, done chan struct{}) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	waiters := w.waiters[id]
+
+	for i, waiter := range waiters {
+		if waiter == done {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+
+	if len(waiters) == 0 {
+		delete(w.waiters, id)
+	} else {
+		w.waiters[id] = waiters
+	}
+}
+
+// accepted wakes up everyone waiting for a transaction of [block]
+func (w *transactionWaiters) accepted(block *state.Block) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if len(w.waiters) == 0 {
+		return
+	}
+
+	for _, receipt := range block.Transactions {
+		id, err := receipt.Transaction.ID()
+
+		if err != nil {
+			log.Error("failed to compute transaction id", "error", err)
+			continue
+		}
+
+		for _, done := range w.waiters[*id] {
+			close(done)
+		}
+
+		delete(w.waiters, *id)
+	}
+}
@@ -0,0 +1,30 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/MetalBlockchain/antelopevm/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionWaiters(t *testing.T) {
+	waiters := newTransactionWaiters()
+	trx, err := transaction.NewPackedTransactionFromSignedTransaction(*transaction.NewSignedTransaction(&transaction.Transaction{}, nil, nil), transaction.CompressionNone)
+	assert.NoError(t, err)
+	id, err := trx.ID()
+	assert.NoError(t, err)
+
+	done := waiters.register(*id)
+	gone := waiters.register(*id)
+	waiters.unregister(*id, gone)
+
+	// Only blocks including the transaction wake up its waiters
+	waiters.accepted(&state.Block{})
+	assert.Len(t, waiters.waiters[*id], 1)
+
+	waiters.accepted(&state.Block{Transactions: []transaction.TransactionReceipt{{Transaction: *trx}}})
+	_, open := <-done
+	assert.False(t, open)
+	assert.Empty(t, waiters.waiters)
+}
@@ -15,9 +15,9 @@ import (
 	"github.com/MetalBlockchain/antelopevm/chain/name"
 	chainTime "github.com/MetalBlockchain/antelopevm/chain/time"
 	"github.com/MetalBlockchain/antelopevm/chain/transaction"
-	"github.com/MetalBlockchain/antelopevm/chain/types"
 	"github.com/MetalBlockchain/antelopevm/crypto"
 	"github.com/MetalBlockchain/antelopevm/mempool"
+	"github.com/MetalBlockchain/antelopevm/metrics"
 	"github.com/MetalBlockchain/antelopevm/state"
 	"github.com/MetalBlockchain/antelopevm/vm/service"
 	"github.com/MetalBlockchain/metalgo/database/manager"
@@ -30,8 +30,10 @@ import (
 	"github.com/MetalBlockchain/metalgo/version"
 	"github.com/dgraph-io/badger/v3"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 
 	// Initializes service plugins
+	_ "github.com/MetalBlockchain/antelopevm/vm/service/admin_api_plugin"
 	_ "github.com/MetalBlockchain/antelopevm/vm/service/chain_api_plugin"
 
 	log "github.com/inconshreveable/log15"
@@ -65,6 +67,10 @@ type VM struct {
 	dbPath     string
 	state      *state.State
 	controller *chain.Controller
+	metrics    *metrics.Metrics
+
+	// Node-local settings passed through configData
+	config Config
 
 	// ID of the preferred block
 	preferred ids.ID
@@ -75,6 +81,9 @@ type VM struct {
 	// Proposed pieces of data that haven't been put into a block and proposed yet
 	mempool *mempool.Mempool
 
+	// API calls waiting for their transaction to be accepted
+	trxWaiters *transactionWaiters
+
 	// Block ID --> Block
 	// Each element is a block that passed verification but
 	// hasn't yet been accepted/rejected
@@ -83,6 +92,18 @@ type VM struct {
 	// Indicates that this VM has finised bootstrapping for the chain
 	bootstrapped bool
 	builder      BlockBuilder
+	gossiper     *Gossiper
+	appSender    common.AppSender
+
+	// State sync
+	stateSyncEnabled     bool
+	stateSummaryInterval uint64
+	syncServer           stateSyncServer
+	syncClient           stateSyncClient
+
+	// Local time at which the last block was accepted, used to detect a
+	// stalled chain
+	lastAcceptedTime time.Time
 
 	chainId crypto.Sha256
 
@@ -112,7 +133,7 @@ func (vm *VM) Initialize(
 	configData []byte,
 	toEngine chan<- common.Message,
 	_ []*common.Fx,
-	_ common.AppSender,
+	appSender common.AppSender,
 ) error {
 	log.Info("initializing Antelope VM", "version", "0.0.1")
 	vm.dbManager = dbManager
@@ -120,29 +141,93 @@ func (vm *VM) Initialize(
 	vm.toEngine = toEngine
 	vm.verifiedBlocks = make(map[chainBlock.BlockHash]*state.Block)
 
+	config, err := ParseConfig(configData)
+	if err != nil {
+		return err
+	}
+
+	vm.config = config
+	level, _ := log.LvlFromString(config.LogLevel)
+	log.Root().SetHandler(log.LvlFilterHandler(level, log.StreamHandler(os.Stderr, log.TerminalFormat())))
+
+	if config.CPUProfilePath != "" {
+		if err := vm.startCPUProfiler(config.CPUProfilePath); err != nil {
+			return err
+		}
+	}
+
+	genesis, err := chain.ParseGenesisData(genesisData)
+	if err != nil {
+		return fmt.Errorf("failed to parse genesis data: %w", err)
+	}
+
+	upgrades, err := chain.ParseUpgradeSchedule(upgradeData)
+	if err != nil {
+		return err
+	}
+
+	// The chain ID is derived from the genesis state so that every network has its own
+	chainId, err := genesis.ComputeChainId()
+	if err != nil {
+		return fmt.Errorf("failed to compute chain id: %w", err)
+	}
+
 	// Create new state and controller
-	vm.chainId = types.ChainIdType(*crypto.NewSha256String("cf057bbfb72640471fd910bcb67639c22df9f92470936cddc1ade0e2f2e7dc4f"))
+	vm.chainId = *chainId
 	vm.dbPath = filepath.Join(vm.ctx.ChainDataDir, chainCtx.NodeID.String())
 
-	if db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true)); err == nil {
+	if db, err := vm.openDatabase(); err == nil {
 		vm.db = db
 	} else {
-		return err
+		return fmt.Errorf("failed to open database at %s: %w", vm.dbPath, err)
+	}
+
+	registry := prometheus.NewRegistry()
+
+	if vm.metrics, err = metrics.New(registry); err != nil {
+		return fmt.Errorf("failed to create metrics: %w", err)
+	}
+
+	if err := chainCtx.Metrics.Register(registry); err != nil {
+		return fmt.Errorf("failed to register metrics: %w", err)
 	}
 
 	vm.state = state.NewState(vm, vm.db)
-	vm.mempool = mempool.New(100)
-	vm.builder = vm.NewBlockBuilder()
+	vm.state.Metrics = vm.metrics
+
+	if err := vm.state.RecoverCommit(); err != nil {
+		return fmt.Errorf("failed to recover block commit: %w", err)
+	}
+
+	vm.mempool = mempool.New(config.MempoolSize, vm.metrics)
+	vm.trxWaiters = newTransactionWaiters()
 	vm.controller = chain.NewController(vm.chainId, vm.state)
+	vm.controller.Metrics = vm.metrics
+	vm.controller.Upgrades = upgrades
+
+	if err := config.Apply(vm.controller); err != nil {
+		return err
+	}
 
 	// Init channels
 	vm.stop = make(chan struct{})
 	vm.builderStop = make(chan struct{})
 	vm.doneBuild = make(chan struct{})
 	vm.doneGossip = make(chan struct{})
+	vm.builder = vm.NewBlockBuilder()
+	vm.gossiper = vm.NewGossiper(appSender)
+	vm.appSender = appSender
+	vm.stateSyncEnabled = config.StateSyncEnabled
+	vm.stateSummaryInterval = config.StateSummaryInterval
+	vm.syncClient.requests = make(map[uint32]chan []byte)
 
 	// Initialize genesis
-	if err := vm.initGenesis(genesisData); err != nil {
+	if err := vm.initGenesis(genesis); err != nil {
+		return err
+	}
+
+	// Make sure we are not opening state that belongs to another chain
+	if err := vm.verifyChainId(); err != nil {
 		return err
 	}
 
@@ -154,6 +239,7 @@ func (vm *VM) Initialize(
 	}
 
 	log.Info("initializing last accepted block", "lastAccepted", lastAccepted)
+	vm.lastAcceptedTime = time.Now()
 
 	// Build off the most recently accepted block
 	if err := vm.SetPreference(ctx, lastAccepted); err != nil {
@@ -161,12 +247,46 @@ func (vm *VM) Initialize(
 	}
 
 	go vm.builder.Build()
+	go vm.gossiper.Gossip()
+
+	return nil
+}
+
+// openDatabase opens the Badger database backing this VM's state. State is
+// persisted under [vm.dbPath] so that a restarting node picks up where it left
+// off, only when the node has no chain data directory (e.g. in tests) do we
+// fall back to an in-memory database.
+func (vm *VM) openDatabase() (*badger.DB, error) {
+	if vm.ctx.ChainDataDir == "" {
+		return badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	}
+
+	if err := os.MkdirAll(vm.dbPath, 0o750); err != nil {
+		return nil, err
+	}
+
+	return badger.Open(badger.DefaultOptions(vm.dbPath).WithLogger(nil))
+}
+
+// startCPUProfiler writes a CPU profile to [path] until the VM is shut down
+func (vm *VM) startCPUProfiler(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create cpu profile: %w", err)
+	}
+
+	if err := pprof.StartCPUProfile(file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to start cpu profiler: %w", err)
+	}
+
+	vm.cpuProfiler = file
 
 	return nil
 }
 
 // Initializes Genesis if required
-func (vm *VM) initGenesis(genesisData []byte) error {
+func (vm *VM) initGenesis(genesisFile *chain.GenesisState) error {
 	session := vm.state.CreateSession(true)
 	defer session.Discard()
 	stateInitialized, err := vm.state.IsInitialized()
@@ -176,14 +296,10 @@ func (vm *VM) initGenesis(genesisData []byte) error {
 	}
 
 	if stateInitialized {
+		log.Info("found existing state, skipping genesis", "path", vm.dbPath)
 		return nil
 	}
 
-	genesisFile, err := chain.ParseGenesisData(genesisData)
-	if err != nil {
-		return err
-	}
-
 	if err := vm.controller.InitGenesis(session, genesisFile); err != nil {
 		return err
 	}
@@ -222,6 +338,24 @@ func (vm *VM) initGenesis(genesisData []byte) error {
 	return nil
 }
 
+// verifyChainId checks that the chain ID persisted in state matches the one
+// computed from the genesis data this VM was started with
+func (vm *VM) verifyChainId() error {
+	session := vm.state.CreateSession(false)
+	defer session.Discard()
+
+	gpo, err := session.FindGlobalPropertyObject(0)
+	if err != nil {
+		return fmt.Errorf("failed to find global property object: %w", err)
+	}
+
+	if gpo.ChainId != vm.chainId {
+		return fmt.Errorf("chain id %s in state does not match chain id %s computed from genesis", gpo.ChainId, vm.chainId)
+	}
+
+	return nil
+}
+
 // CreateHandlers returns a map where:
 // Keys: The path extension for this VM's API (empty in this case)
 // Values: The handler for the API
@@ -230,11 +364,15 @@ func (vm *VM) CreateHandlers(ctx context.Context) (map[string]*common.HTTPHandle
 	router := gin.Default()
 
 	for path, handler := range service.GetHandlers() {
+		if !vm.config.PluginEnabled(service.PluginName(path)) {
+			continue
+		}
+
 		for _, method := range handler.Methods {
 			if method == http.MethodPost {
-				router.POST("/ext/bc/"+vm.ctx.ChainID.String()+path, handler.HandlerFunc(vm))
+				router.POST("/ext/bc/"+vm.ctx.ChainID.String()+path, vm.instrumentHandler(path), handler.HandlerFunc(vm))
 			} else if method == http.MethodGet {
-				router.GET("/ext/bc/"+vm.ctx.ChainID.String()+path, handler.HandlerFunc(vm))
+				router.GET("/ext/bc/"+vm.ctx.ChainID.String()+path, vm.instrumentHandler(path), handler.HandlerFunc(vm))
 			}
 		}
 
@@ -247,6 +385,15 @@ func (vm *VM) CreateHandlers(ctx context.Context) (map[string]*common.HTTPHandle
 	return handlers, nil
 }
 
+// instrumentHandler records the latency of the requests served under [path]
+func (vm *VM) instrumentHandler(path string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		vm.metrics.ObserveAPIRequest(path, time.Since(start))
+	}
+}
+
 // CreateStaticHandlers returns a map where:
 // Keys: The path extension for this VM's static API
 // Values: The handler for that static API
@@ -254,11 +401,6 @@ func (vm *VM) CreateStaticHandlers(ctx context.Context) (map[string]*common.HTTP
 	return nil, nil
 }
 
-// Health implements the common.VM interface
-func (vm *VM) HealthCheck(ctx context.Context) (interface{}, error) {
-	return nil, nil
-}
-
 // BuildBlock returns a block that this vm wants to add to consensus
 func (vm *VM) BuildBlock(ctx context.Context) (snowman.Block, error) {
 	defer vm.builder.HandleGenerateBlock()
@@ -270,6 +412,7 @@ func (vm *VM) BuildBlock(ctx context.Context) (snowman.Block, error) {
 	}
 
 	log.Debug("block built successfully", "block", newBlock.ID())
+	vm.metrics.BlockBuilt()
 
 	return newBlock, nil
 }
@@ -318,6 +461,10 @@ func (vm *VM) ParseBlock(ctx context.Context, bytes []byte) (snowman.Block, erro
 		return nil, err
 	}
 
+	if err := block.VerifyHash(); err != nil {
+		return nil, err
+	}
+
 	// Initialize the block
 	block.Initialize(vm)
 	block.SetStatus(choices.Processing)
@@ -343,7 +490,8 @@ func (vm *VM) NewBlock(parentID chainBlock.BlockHash, height uint64, receipts []
 			Producer:  name.StringToName("eosio"),
 			Confirmed: 1,
 		},
-		Transactions: receipts,
+		Transactions:          receipts,
+		ScheduledTransactions: make([]transaction.ScheduledTransactionReceipt, 0),
 	}
 
 	// Initialize the block by providing it with its byte representation
@@ -357,8 +505,29 @@ func (vm *VM) NewBlock(parentID chainBlock.BlockHash, height uint64, receipts []
 
 // Shutdown this vm
 func (vm *VM) Shutdown(ctx context.Context) error {
-	pprof.StopCPUProfile()
-	vm.cpuProfiler.Close()
+	if vm.cpuProfiler != nil {
+		pprof.StopCPUProfile()
+		vm.cpuProfiler.Close()
+	}
+
+	if vm.stop != nil {
+		close(vm.stop)
+		<-vm.doneBuild
+		<-vm.doneGossip
+	}
+
+	vm.syncServer.producers.Wait()
+
+	if vm.syncServer.snapshot != nil {
+		vm.syncServer.snapshot.Discard()
+	}
+
+	if vm.controller != nil {
+		if err := vm.controller.WasmRuntime.Close(ctx); err != nil {
+			return err
+		}
+	}
+
 	if vm.state == nil {
 		return nil
 	}
@@ -378,36 +547,30 @@ func (vm *VM) SetPreference(ctx context.Context, id ids.ID) error {
 
 func (vm *VM) Verified(block *state.Block) error {
 	vm.verifiedBlocks[block.Hash] = block
+	vm.metrics.BlockVerified()
+
 	return nil
 }
 
 func (vm *VM) Accepted(block *state.Block) error {
-	session := vm.state.CreateSession(true)
-	defer session.Discard()
-	block.SetStatus(choices.Accepted) // Change state of this block
-	blkID := block.ID()
-
-	// Persist data
-	if err := session.CreateBlock(block); err != nil {
-		return fmt.Errorf("failed to insert block: %s", err)
-	}
-
-	if err := session.SetLastAccepted(blkID); err != nil {
-		return fmt.Errorf("failed to set last accepted: %s", err)
-	}
-
-	if err := session.Commit(); err != nil {
-		return fmt.Errorf("failed to commit session: %s", err)
+	// Flush the changes made by this block and persist it
+	if err := vm.state.CommitBlock(block); err != nil {
+		return fmt.Errorf("failed to commit block: %s", err)
 	}
 
 	// Delete this block from verified blocks as it's accepted
 	delete(vm.verifiedBlocks, block.Hash)
+	vm.lastAcceptedTime = time.Now()
+	vm.metrics.BlockAccepted()
+	vm.trxWaiters.accepted(block)
+	vm.produceStateSummary(block)
 
 	return nil
 }
 
 func (vm *VM) Rejected(block *state.Block) error {
 	delete(vm.verifiedBlocks, block.Hash)
+	vm.metrics.BlockRejected()
 
 	return nil
 }
@@ -431,20 +594,133 @@ func (vm *VM) GetStoredBlock(context context.Context, blkID ids.ID) (*state.Bloc
 	return stBlk, nil
 }
 
-func (vm *VM) ExecuteTransaction(trx *transaction.PackedTransaction, block *state.Block, session *state.Session) (*transaction.TransactionTrace, error) {
+// StartBlock applies the scheduled changes that take effect at [block]
+func (vm *VM) StartBlock(block *state.Block, parent *state.Block, session *state.Session) error {
+	return vm.controller.StartBlock(session, block, parent)
+}
+
+// FinalizeBlock applies the changes that take effect once all transactions
+// of [block] have been executed
+func (vm *VM) FinalizeBlock(block *state.Block, session *state.Session) error {
+	return vm.controller.FinalizeBlock(session, block)
+}
+
+// ExecuteTransaction pushes [trx] into [block], a non zero [billedCpuTimeUs]
+// bills the CPU time recorded in the block instead of measuring it
+func (vm *VM) ExecuteTransaction(trx *transaction.PackedTransaction, billedCpuTimeUs uint32, block *state.Block, session *state.Session) (*transaction.TransactionTrace, error) {
 	if err := trx.UnpackTransaction(); err != nil {
 		return nil, err
 	}
 
 	trxMeta, err := transaction.RecoverKeys(trx, vm.chainId, chainTime.MaxMicroseconds(), transaction.Input, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	trxMeta.BilledCpuTimeUs = billedCpuTimeUs
 	trace, err := vm.controller.PushTransaction(*trxMeta, block, session)
 
 	if err != nil {
 		log.Error("failed to execute trx", "error", err)
+		vm.metrics.TransactionFailed()
 		return nil, err
 	}
 
 	log.Info("done processing transaction")
+	vm.metrics.TransactionExecuted()
+
+	return trace, nil
+}
+
+// ComputeTransaction executes [trx] on top of the preferred block without
+// keeping any of its changes, [trxType] is either transaction.DryRun or
+// transaction.ReadOnly. A trace is returned as well when the execution fails
+func (vm *VM) ComputeTransaction(trx *transaction.PackedTransaction, trxType transaction.TransactionType) (*transaction.TransactionTrace, error) {
+	// API handlers run outside of the consensus engine, the lock keeps the
+	// preferred block, the verified blocks and their state layers from changing
+	// until the speculative session is done with them
+	vm.ctx.Lock.RLock()
+	defer vm.ctx.Lock.RUnlock()
+
+	parent, err := vm.GetStoredBlock(context.Background(), vm.preferred)
+
+	if err != nil {
+		return nil, err
+	}
+
+	session := vm.state.CreateSpeculativeSession(parent)
+	defer session.Discard()
+	block := state.NewBlock(vm, chainTime.Now(), parent.Hash, uint64(parent.Header.BlockNum())+1)
+
+	if block.Header.Timestamp <= parent.Header.Timestamp {
+		block.Header.Timestamp = parent.Header.Timestamp + 1
+	}
+
+	if err := trx.UnpackTransaction(); err != nil {
+		return nil, err
+	}
+
+	trxMeta, err := transaction.RecoverKeys(trx, vm.chainId, chainTime.MaxMicroseconds(), trxType, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return vm.controller.PushTransaction(*trxMeta, block, session)
+}
+
+// SubmitTransaction adds [trx] to the mempool, when [wait] is set it blocks
+// until [trx] is included in an accepted block or [ctx] is done
+func (vm *VM) SubmitTransaction(ctx context.Context, trx *transaction.PackedTransaction, wait bool) error {
+	id, err := trx.ID()
+
+	if err != nil {
+		return err
+	}
+
+	var done chan struct{}
+
+	if wait {
+		done = vm.trxWaiters.register(*id)
+		defer vm.trxWaiters.unregister(*id, done)
+	}
+
+	if ok := vm.mempool.Add(trx); !ok {
+		return fmt.Errorf("could not submit trx")
+	}
+
+	if !wait {
+		return nil
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("transaction %s was not included in a block: %w", id, ctx.Err())
+	}
+}
+
+// ScheduledTransactions returns the deferred transactions that are due in
+// [block]
+func (vm *VM) ScheduledTransactions(block *state.Block, session *state.Session) ([]transaction.TransactionIdType, error) {
+	return vm.controller.ScheduledTransactions(session, block)
+}
+
+// ExecuteScheduledTransaction retires the deferred transaction [trxId] in
+// [block], a non zero [billedCpuTimeUs] bills the CPU time recorded in the
+// block instead of measuring it
+func (vm *VM) ExecuteScheduledTransaction(trxId transaction.TransactionIdType, billedCpuTimeUs uint32, block *state.Block, session *state.Session) (*transaction.TransactionTrace, error) {
+	trace, err := vm.controller.PushScheduledTransaction(trxId, billedCpuTimeUs, block, session)
+
+	if err != nil {
+		log.Error("failed to execute scheduled trx", "error", err)
+		vm.metrics.TransactionFailed()
+		return nil, err
+	}
+
+	vm.metrics.TransactionExecuted()
 
 	return trace, nil
 }
@@ -465,6 +741,11 @@ func (vm *VM) SetState(ctx context.Context, state snow.State) error {
 
 // onBootstrapStarted marks this VM as bootstrapping
 func (vm *VM) onBootstrapStarted() error {
+	// Do not continue with a partially synced state
+	if err := vm.syncClient.Err(); err != nil {
+		return err
+	}
+
 	vm.bootstrapped = false
 	return nil
 }
@@ -485,30 +766,43 @@ func (vm *VM) Version(ctx context.Context) (string, error) {
 }
 
 func (vm *VM) Connected(ctx context.Context, id ids.NodeID, nodeVersion *version.Application) error {
-	return nil // noop
+	vm.syncClient.lock.Lock()
+	defer vm.syncClient.lock.Unlock()
+
+	if id != vm.ctx.NodeID {
+		vm.syncClient.peers.Add(id)
+	}
+
+	return nil
 }
 
 func (vm *VM) Disconnected(ctx context.Context, id ids.NodeID) error {
-	return nil // noop
+	vm.syncClient.lock.Lock()
+	defer vm.syncClient.lock.Unlock()
+
+	vm.syncClient.peers.Remove(id)
+
+	return nil
 }
 
-// This VM doesn't (currently) have any app-specific messages
+// AppGossip handles transactions gossiped by other validators
 func (vm *VM) AppGossip(ctx context.Context, nodeID ids.NodeID, msg []byte) error {
-	return nil
+	return vm.gossiper.HandleAppGossip(ctx, nodeID, msg)
 }
 
-// This VM doesn't (currently) have any app-specific messages
-func (vm *VM) AppRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, time time.Time, request []byte) error {
-	return nil
+// AppRequest serves state chunks to nodes that are state syncing
+func (vm *VM) AppRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, deadline time.Time, request []byte) error {
+	return vm.handleStateChunkRequest(ctx, nodeID, requestID, request)
 }
 
-// This VM doesn't (currently) have any app-specific messages
+// AppResponse receives the state chunks requested while state syncing
 func (vm *VM) AppResponse(ctx context.Context, nodeID ids.NodeID, requestID uint32, response []byte) error {
+	vm.handleStateChunkResponse(requestID, response)
 	return nil
 }
 
-// This VM doesn't (currently) have any app-specific messages
 func (vm *VM) AppRequestFailed(ctx context.Context, nodeID ids.NodeID, requestID uint32) error {
+	vm.handleStateChunkResponse(requestID, nil)
 	return nil
 }
 
@@ -537,3 +831,23 @@ func (vm *VM) GetState() *state.State {
 func (vm *VM) GetController() *chain.Controller {
 	return vm.controller
 }
+
+// GetAccessLists returns the account and key lists the controller enforces
+func (vm *VM) GetAccessLists() service.AccessLists {
+	return formatAccessLists(vm.controller.GetAccessLists())
+}
+
+// SetAccessLists validates [lists] and replaces the lists of the controller,
+// the lists from the node config are used again after a restart
+func (vm *VM) SetAccessLists(lists service.AccessLists) error {
+	parsed, err := parseAccessLists(lists)
+
+	if err != nil {
+		return err
+	}
+
+	vm.controller.SetAccessLists(parsed)
+	log.Info("reloaded access lists", "lists", lists)
+
+	return nil
+}
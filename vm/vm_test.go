@@ -1,11 +1,25 @@
 package vm
 
 import (
+	"bytes"
 	"context"
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/MetalBlockchain/antelopevm/chain"
+	"github.com/MetalBlockchain/antelopevm/chain/account"
+	"github.com/MetalBlockchain/antelopevm/chain/authority"
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+	chainTime "github.com/MetalBlockchain/antelopevm/chain/time"
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/MetalBlockchain/antelopevm/chain/types"
+	"github.com/MetalBlockchain/antelopevm/config"
+	"github.com/MetalBlockchain/antelopevm/crypto"
+	"github.com/MetalBlockchain/antelopevm/crypto/ecc"
+	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
+	"github.com/MetalBlockchain/antelopevm/state"
 	"github.com/MetalBlockchain/metalgo/database/manager"
 	"github.com/MetalBlockchain/metalgo/ids"
 	"github.com/MetalBlockchain/metalgo/snow"
@@ -41,3 +55,384 @@ func newTestVM() (*VM, *snow.Context, chan common.Message, error) {
 	err = vm.Initialize(context.TODO(), snowCtx, dbManager, byteValue, nil, nil, msgChan, nil, nil)
 	return vm, snowCtx, msgChan, err
 }
+
+func TestVMRestartKeepsState(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.TODO()
+	genesisData, err := os.ReadFile("../chain/genesis_test.json")
+	assert.NoError(err)
+
+	snowCtx := snow.DefaultContextTest()
+	snowCtx.ChainDataDir = t.TempDir()
+	dbManager := manager.NewMemDB(&version.Semantic{Major: 1})
+
+	vm := &VM{}
+	assert.NoError(vm.Initialize(ctx, snowCtx, dbManager, genesisData, nil, nil, make(chan common.Message, 1), nil, nil))
+	lastAccepted, err := vm.LastAccepted(ctx)
+	assert.NoError(err)
+	assert.NoError(vm.Shutdown(ctx))
+
+	// Metrics can only be registered once per context
+	restartedCtx := snow.DefaultContextTest()
+	restartedCtx.ChainDataDir = snowCtx.ChainDataDir
+	restarted := &VM{}
+	assert.NoError(restarted.Initialize(ctx, restartedCtx, dbManager, genesisData, nil, nil, make(chan common.Message, 1), nil, nil))
+	defer restarted.Shutdown(ctx)
+	ok, err := restarted.state.IsInitialized()
+	assert.NoError(err)
+	assert.True(ok)
+	restartedLastAccepted, err := restarted.LastAccepted(ctx)
+	assert.NoError(err)
+	assert.Equal(lastAccepted, restartedLastAccepted)
+}
+
+func TestVMChainIdFromGenesis(t *testing.T) {
+	assert := assert.New(t)
+	vm, _, _, err := newTestVM()
+	assert.NoError(err)
+	assert.Equal("384da888112027f0321850a169f737c33e53b388aad48b5adace4bab97f437e0", vm.controller.ChainId.String())
+	session := vm.state.CreateSession(false)
+	defer session.Discard()
+	gpo, err := session.FindGlobalPropertyObject(0)
+	assert.NoError(err)
+	assert.Equal(vm.controller.ChainId, gpo.ChainId)
+}
+
+func TestVMHealthCheck(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.TODO()
+	vm, _, _, err := newTestVM()
+	assert.NoError(err)
+	result, err := vm.HealthCheck(ctx)
+	assert.NoError(err)
+	report, ok := result.(HealthReport)
+	assert.True(ok)
+	assert.True(report.DatabaseReachable)
+	assert.Equal(uint64(1), report.LastAcceptedHeight)
+	assert.Equal(0, report.MempoolSize)
+
+	// A chain that has not accepted a block in a while is only unhealthy when
+	// there is work waiting
+	vm.lastAcceptedTime = time.Now().Add(-2 * maxAcceptedBlockAge)
+	_, err = vm.HealthCheck(ctx)
+	assert.NoError(err)
+}
+
+func TestVMBlocksExecuteOnBlock(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.TODO()
+	vm, _, _, err := newTestVM()
+	assert.NoError(err)
+	lastAccepted, err := vm.LastAccepted(ctx)
+	assert.NoError(err)
+
+	// The implicit onblock action is executed when building and verifying
+	blk, err := vm.BuildBlock(ctx)
+	assert.NoError(err)
+	assert.Equal(lastAccepted, blk.Parent())
+	assert.NoError(blk.Verify(ctx))
+	assert.NoError(blk.Accept(ctx))
+
+	session := vm.state.CreateSession(false)
+	defer session.Discard()
+	metadata, err := session.FindAccountMetaDataByName(config.SystemAccountName)
+	assert.NoError(err)
+	assert.Equal(uint64(1), metadata.RecvSequence)
+}
+
+// abortWasm is a contract whose apply function traps
+var abortWasm = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	0x01, 0x07, 0x01, 0x60, 0x03, 0x7e, 0x7e, 0x7e, 0x00,
+	0x03, 0x02, 0x01, 0x00,
+	0x05, 0x03, 0x01, 0x00, 0x01,
+	0x07, 0x12, 0x02, 0x05, 'a', 'p', 'p', 'l', 'y', 0x00, 0x00, 0x06, 'm', 'e', 'm', 'o', 'r', 'y', 0x02, 0x00,
+	0x0a, 0x05, 0x01, 0x03, 0x00, 0x00, 0x0b,
+}
+
+func TestVMBlocksTolerateFailingOnBlock(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.TODO()
+	vm, _, _, err := newTestVM()
+	assert.NoError(err)
+
+	// The system contract aborts on every action, onblock included
+	session := vm.state.CreateSession(true)
+	codeHash := *crypto.Hash256(abortWasm)
+	assert.NoError(session.CreateCodeObject(&account.CodeObject{CodeHash: codeHash, Code: abortWasm, CodeRefCount: 1}))
+	metadata, err := session.FindAccountMetaDataByName(config.SystemAccountName)
+	assert.NoError(err)
+	assert.NoError(session.ModifyAccountMetaData(metadata, func() {
+		metadata.CodeHash = codeHash
+	}))
+	assert.NoError(session.Commit())
+
+	blk, err := vm.BuildBlock(ctx)
+	assert.NoError(err)
+	assert.NoError(blk.Verify(ctx))
+	assert.NoError(blk.Accept(ctx))
+
+	lastAccepted, err := vm.LastAccepted(ctx)
+	assert.NoError(err)
+	assert.Equal(blk.ID(), lastAccepted)
+
+	// The failed onblock left no writes behind
+	session = vm.state.CreateSession(false)
+	defer session.Discard()
+	metadata, err = session.FindAccountMetaDataByName(config.SystemAccountName)
+	assert.NoError(err)
+	assert.Equal(uint64(0), metadata.RecvSequence)
+}
+
+func TestVMRejectsTamperedBlockHash(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.TODO()
+	vm, _, _, err := newTestVM()
+	assert.NoError(err)
+
+	blk, err := vm.BuildBlock(ctx)
+	assert.NoError(err)
+	tampered := blk.(*state.Block)
+	tampered.Hash[31]++
+
+	_, err = vm.ParseBlock(ctx, tampered.Bytes())
+	assert.ErrorContains(err, "does not match id")
+	assert.ErrorContains(tampered.Verify(ctx), "does not match id")
+}
+
+func TestVMGossipExecutesTransactions(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.TODO()
+	vm, _, _, err := newTestVM()
+	assert.NoError(err)
+	lastAccepted, err := vm.LastAccepted(ctx)
+	assert.NoError(err)
+	blk, err := vm.GetStoredBlock(ctx, lastAccepted)
+	assert.NoError(err)
+
+	// The summary of a block is recorded by its child
+	child, err := vm.BuildBlock(ctx)
+	assert.NoError(err)
+	assert.NoError(child.Verify(ctx))
+	assert.NoError(child.Accept(ctx))
+
+	// A valid header but an action nobody signed for
+	trx := &transaction.Transaction{
+		TransactionHeader: transaction.TransactionHeader{
+			Expiration:     chainTime.NewTimePointSecTp(chainTime.Now().AddUs(chainTime.Seconds(60))),
+			RefBlockNum:    uint16(blk.Header.BlockNum()),
+			RefBlockPrefix: blk.Hash.RefBlockPrefix(),
+		},
+		Actions: []*transaction.Action{{
+			Account:       config.SystemAccountName,
+			Name:          name.StringToName("updateauth"),
+			Authorization: []authority.PermissionLevel{{Actor: config.SystemAccountName, Permission: name.StringToName("active")}},
+		}},
+	}
+	packedTrx, err := transaction.NewPackedTransactionFromSignedTransaction(*transaction.NewSignedTransaction(trx, nil, nil), transaction.CompressionNone)
+	assert.NoError(err)
+
+	session := vm.state.CreateSession(false)
+	defer session.Discard()
+	assert.NoError(vm.controller.ValidateTransaction(session, packedTrx, chainTime.Now()))
+
+	msg, err := rlp.EncodeToBytes(&GossipMessage{Transactions: []transaction.PackedTransaction{*packedTrx}})
+	assert.NoError(err)
+	assert.NoError(vm.AppGossip(ctx, ids.EmptyNodeID, msg))
+	assert.Equal(0, vm.mempool.Len())
+}
+
+func TestVMProducesStateSummary(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.TODO()
+	vm, _, _, err := newTestVM()
+	assert.NoError(err)
+	vm.stateSummaryInterval = 1
+
+	blk, err := vm.BuildBlock(ctx)
+	assert.NoError(err)
+	assert.NoError(blk.Verify(ctx))
+	assert.NoError(blk.Accept(ctx))
+
+	// The summary is produced off the accept path
+	vm.syncServer.producers.Wait()
+	summary, err := vm.GetLastStateSummary(ctx)
+	assert.NoError(err)
+	assert.Equal(blk.Height(), summary.Height())
+
+	stored, err := vm.GetStateSummary(ctx, blk.Height())
+	assert.NoError(err)
+	assert.Equal(summary.ID(), stored.ID())
+}
+
+func TestVMGossipWithoutSender(t *testing.T) {
+	assert := assert.New(t)
+	vm, _, _, err := newTestVM()
+	assert.NoError(err)
+
+	trx := &transaction.Transaction{TransactionHeader: transaction.TransactionHeader{Expiration: 1}}
+	packedTrx, err := transaction.NewPackedTransactionFromSignedTransaction(*transaction.NewSignedTransaction(trx, nil, nil), transaction.CompressionNone)
+	assert.NoError(err)
+	assert.True(vm.mempool.Add(packedTrx))
+
+	assert.NoError(vm.gossiper.GossipTxs(context.TODO()))
+	assert.Empty(vm.mempool.NewTxs(maxGossipBatchSize))
+}
+
+func TestVMBlocksUndoFailedTransactions(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.TODO()
+
+	// Start from a genesis whose system account key we can sign with
+	key, err := ecc.NewRandomPrivateKey()
+	assert.NoError(err)
+	genesisData, err := os.ReadFile("../chain/genesis_test.json")
+	assert.NoError(err)
+	genesisData = bytes.Replace(genesisData, []byte("EOS5XPRJt1zUiLH98rtDLj9TnPi52DLQ7gTZbkRvBGJXLv6ak6Cdq"), []byte(key.PublicKey().String()), 1)
+	vm := &VM{}
+	assert.NoError(vm.Initialize(ctx, snow.DefaultContextTest(), manager.NewMemDB(&version.Semantic{Major: 1}), genesisData, nil, nil, make(chan common.Message, 1), nil, nil))
+	lastAccepted, err := vm.LastAccepted(ctx)
+	assert.NoError(err)
+	parent, err := vm.GetStoredBlock(ctx, lastAccepted)
+	assert.NoError(err)
+
+	// The summary of a block is recorded by its child
+	child, err := vm.BuildBlock(ctx)
+	assert.NoError(err)
+	assert.NoError(child.Verify(ctx))
+	assert.NoError(child.Accept(ctx))
+
+	auth := authority.Authority{Threshold: 1, Keys: []authority.KeyWeight{{Key: key.PublicKey(), Weight: 1}}}
+	level := []authority.PermissionLevel{{Actor: config.SystemAccountName, Permission: config.ActiveName}}
+	updateAuth := func(permission string, parent string) *transaction.Action {
+		data, err := rlp.EncodeToBytes(&chain.UpdateAuth{
+			Account:    config.SystemAccountName,
+			Permission: name.StringToName(permission),
+			Parent:     name.StringToName(parent),
+			Auth:       auth,
+		})
+		assert.NoError(err)
+		return &transaction.Action{Account: config.SystemAccountName, Name: name.StringToName("updateauth"), Authorization: level, Data: data}
+	}
+	submit := func(actions ...*transaction.Action) {
+		trx := &transaction.Transaction{
+			TransactionHeader: transaction.TransactionHeader{
+				Expiration:     chainTime.NewTimePointSecTp(chainTime.Now().AddUs(chainTime.Seconds(60))),
+				RefBlockNum:    uint16(parent.Header.BlockNum()),
+				RefBlockPrefix: parent.Hash.RefBlockPrefix(),
+			},
+			Actions: actions,
+		}
+		signedTrx := transaction.NewSignedTransaction(trx, nil, nil)
+		signedTrx.Sign(key, &vm.chainId)
+		packedTrx, err := transaction.NewPackedTransactionFromSignedTransaction(*signedTrx, transaction.CompressionNone)
+		assert.NoError(err)
+		assert.True(vm.mempool.Add(packedTrx))
+	}
+
+	// The first transaction fails on its last action, after its first one
+	// already created a permission. The last one only succeeds if that
+	// permission was left behind.
+	broken := updateAuth("gamma", "active")
+	broken.Data = nil
+	submit(updateAuth("beta", "active"), broken)
+	submit(updateAuth("alpha", "active"))
+	submit(updateAuth("delta", "beta"))
+
+	blk, err := vm.BuildBlock(ctx)
+	assert.NoError(err)
+	assert.Len(blk.(*state.Block).Transactions, 1)
+	assert.NoError(blk.Verify(ctx))
+	assert.NoError(blk.Accept(ctx))
+
+	session := vm.state.CreateSession(false)
+	defer session.Discard()
+	_, err = session.FindPermissionByOwner(config.SystemAccountName, name.StringToName("alpha"))
+	assert.NoError(err)
+	_, err = session.FindPermissionByOwner(config.SystemAccountName, name.StringToName("beta"))
+	assert.Error(err)
+	_, err = session.FindPermissionByOwner(config.SystemAccountName, name.StringToName("delta"))
+	assert.Error(err)
+}
+
+// gossipSender records the gossip messages sent by a VM
+type gossipSender struct {
+	common.AppSender
+	messages [][]byte
+}
+
+func (s *gossipSender) SendAppGossip(ctx context.Context, bytes []byte) error {
+	s.messages = append(s.messages, bytes)
+	return nil
+}
+
+func TestVMGossipBatchSize(t *testing.T) {
+	assert := assert.New(t)
+	vm, _, _, err := newTestVM()
+	assert.NoError(err)
+	sender := &gossipSender{}
+	vm.gossiper.appSender = sender
+
+	// Only two of these fit in a single message
+	contextFreeData := []types.HexBytes{make([]byte, maxGossipBatchBytes/3)}
+
+	for i := 0; i < 4; i++ {
+		trx := &transaction.Transaction{TransactionHeader: transaction.TransactionHeader{Expiration: chainTime.TimePointSec(i + 1)}}
+		packedTrx, err := transaction.NewPackedTransactionFromSignedTransaction(*transaction.NewSignedTransaction(trx, nil, contextFreeData), transaction.CompressionNone)
+		assert.NoError(err)
+		assert.True(vm.mempool.Add(packedTrx))
+	}
+
+	assert.NoError(vm.gossiper.GossipTxs(context.TODO()))
+	assert.Len(sender.messages, 2)
+
+	for _, bytes := range sender.messages {
+		var msg GossipMessage
+		assert.NoError(rlp.DecodeBytes(bytes, &msg))
+		assert.Len(msg.Transactions, 2)
+	}
+}
+
+func TestVMBlocksKeepTransactionsThatDoNotFit(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.TODO()
+	vm, _, _, err := newTestVM()
+	assert.NoError(err)
+	lastAccepted, err := vm.LastAccepted(ctx)
+	assert.NoError(err)
+	parent, err := vm.GetStoredBlock(ctx, lastAccepted)
+	assert.NoError(err)
+
+	// The summary of a block is recorded by its child
+	child, err := vm.BuildBlock(ctx)
+	assert.NoError(err)
+	assert.NoError(child.Verify(ctx))
+	assert.NoError(child.Accept(ctx))
+
+	// Leave no NET in the block for any transaction
+	session := vm.state.CreateSession(true)
+	limitsConfig, err := session.FindResourceLimitsConfigObject(0)
+	assert.NoError(err)
+	assert.NoError(session.ModifyResourceLimitsConfigObject(limitsConfig, func() {
+		limitsConfig.NetLimitParameters.Max = 1
+	}))
+	assert.NoError(session.Commit())
+
+	for i := 0; i < 2; i++ {
+		trx := &transaction.Transaction{
+			TransactionHeader: transaction.TransactionHeader{
+				Expiration:     chainTime.NewTimePointSecTp(chainTime.Now().AddUs(chainTime.Seconds(int64(60 + i)))),
+				RefBlockNum:    uint16(parent.Header.BlockNum()),
+				RefBlockPrefix: parent.Hash.RefBlockPrefix(),
+			},
+		}
+		packedTrx, err := transaction.NewPackedTransactionFromSignedTransaction(*transaction.NewSignedTransaction(trx, nil, nil), transaction.CompressionNone)
+		assert.NoError(err)
+		assert.True(vm.mempool.Add(packedTrx))
+	}
+
+	blk, err := vm.BuildBlock(ctx)
+	assert.NoError(err)
+	assert.Empty(blk.(*state.Block).Transactions)
+	assert.Equal(2, vm.mempool.Len())
+}
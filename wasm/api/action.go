@@ -1,12 +1,19 @@
 package api
 
-import "github.com/MetalBlockchain/antelopevm/chain/name"
+import (
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/protocol"
+)
 
 func init() {
 	Functions["read_action_data"] = readActionData
 	Functions["action_data_size"] = actionDataSize
 	Functions["current_receiver"] = currentReceiver
 	Functions["set_action_return_value"] = setActionReturnValue
+
+	RequiredFeatures["set_action_return_value"] = protocol.ActionReturnValue
+
+	ContextAware["set_action_return_value"] = true
 }
 
 func readActionData(context Context) interface{} {
@@ -10,6 +10,12 @@ func init() {
 	Functions["require_auth2"] = requireAuth2
 	Functions["require_recipient"] = requireRecipient
 	Functions["is_account"] = isAccount
+
+	ContextAware["require_auth"] = true
+	ContextAware["has_auth"] = true
+	ContextAware["require_auth2"] = true
+	ContextAware["require_recipient"] = true
+	ContextAware["is_account"] = true
 }
 
 func requireAuth(context Context) interface{} {
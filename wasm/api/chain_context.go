@@ -4,23 +4,29 @@ import (
 	"github.com/MetalBlockchain/antelopevm/chain/account"
 	"github.com/MetalBlockchain/antelopevm/chain/authority"
 	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/protocol"
 	"github.com/MetalBlockchain/antelopevm/chain/time"
 	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/MetalBlockchain/antelopevm/crypto"
 	"github.com/MetalBlockchain/antelopevm/crypto/ecc"
+	"github.com/MetalBlockchain/antelopevm/math"
 )
 
 type Controller interface {
 	GetActiveProducers() ([]name.Name, error)
+	PendingBlockTime() time.TimePoint
+	PendingBlockNum() uint32
 }
 
 type AuthorizationManager interface {
 	GetPermission(level authority.PermissionLevel) (*authority.Permission, error)
-	CheckAuthorization(actions []*transaction.Action, keys ecc.PublicKeySet, providedPermissions []authority.PermissionLevel, allowUnusedKeys bool, satisfiedAuthorizations authority.PermissionLevelSet) error
-	CheckAuthorizationByPermissionLevel(account name.AccountName, permission name.PermissionName, keys ecc.PublicKeySet, providedPermissions []authority.PermissionLevel, allowUnusedKeys bool) error
+	CheckAuthorization(actions []*transaction.Action, keys ecc.PublicKeySet, providedPermissions []authority.PermissionLevel, providedDelay time.Microseconds, allowUnusedKeys bool, satisfiedAuthorizations authority.PermissionLevelSet) error
+	CheckAuthorizationByPermissionLevel(account name.AccountName, permission name.PermissionName, keys ecc.PublicKeySet, providedPermissions []authority.PermissionLevel, providedDelay time.Microseconds, allowUnusedKeys bool) error
 }
 
 type TransactionContext interface {
 	GetPublicationTime() time.TimePoint
+	IsExplicitlyBilled() bool
 }
 
 type ApplyContext interface {
@@ -29,11 +35,13 @@ type ApplyContext interface {
 	RequireAuthorizationWithPermission(account name.AccountName, permission name.PermissionName) error
 	HasAuthorization(account name.AccountName) bool
 	FindAccount(account name.AccountName) (*account.Account, error)
+	FindAccountMetaData(account name.AccountName) (*account.AccountMetaDataObject, error)
 	IsAccount(account name.AccountName) bool
 	GetSender() (*name.ActionName, error)
 
 	GetAction() transaction.Action
 	GetReceiver() name.AccountName
+	IsContextFree() bool
 
 	// Database functions
 	FindI64(code name.AccountName, scope name.ScopeName, table name.TableName, primaryKey uint64) int
@@ -52,15 +60,25 @@ type ApplyContext interface {
 
 	SetActionReturnValue(value []byte)
 	GetPackedTransaction() *transaction.PackedTransaction
+	GetContextFreeData(index uint32) ([]byte, bool)
 
 	// Transaction functions
 	ExecuteInline(action transaction.Action) error
+	ExecuteContextFreeInline(action transaction.Action) error
+	ScheduleDeferredTransaction(senderId math.Uint128, payer name.AccountName, trx *transaction.Transaction, replaceExisting bool) error
+	CancelDeferredTransaction(senderId math.Uint128) (bool, error)
 
 	IsContextPrivileged() bool
 	IsPrivileged(name name.AccountName) (bool, error)
 	SetPrivileged(name name.AccountName, privileged bool) error
 }
 
+type ProtocolFeatureManager interface {
+	IsFeatureActivated(digest crypto.Sha256) (bool, error)
+	IsBuiltinFeatureActivated(codename protocol.BuiltinProtocolFeatureType) (bool, error)
+	PreactivateFeature(digest crypto.Sha256, pendingBlockTime time.TimePoint) error
+}
+
 type MultiIndex[S any] interface {
 	Store(scope name.ScopeName, tableName name.TableName, payer name.AccountName, primaryKey uint64, secondaryKey S) (int, error)
 	Remove(iterator int) error
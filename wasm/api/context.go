@@ -1,9 +1,18 @@
 package api
 
-import "github.com/MetalBlockchain/antelopevm/math"
+import (
+	"github.com/MetalBlockchain/antelopevm/chain/protocol"
+	"github.com/MetalBlockchain/antelopevm/math"
+)
 
 var (
 	Functions = make(map[string]func(context Context) interface{})
+	// Intrinsics listed here are only exported to contracts once the builtin
+	// protocol feature that introduced them has been activated
+	RequiredFeatures = make(map[string]protocol.BuiltinProtocolFeatureType)
+	// Intrinsics listed here depend on the chain state, the action's receiver or
+	// its authorizations, context free actions abort when they call them
+	ContextAware = make(map[string]bool)
 )
 
 type Context interface {
@@ -17,6 +26,7 @@ type Context interface {
 	GetIdxLongDouble() MultiIndex[math.Float128]
 	GetAuthorizationManager() AuthorizationManager
 	GetResourceLimitsManager() ResourceLimitsManager
+	GetProtocolFeatureManager() ProtocolFeatureManager
 	ReadMemory(start uint32, length uint32) []byte
 	WriteMemory(start uint32, data []byte)
 	GetMemorySize() uint32
@@ -10,24 +10,22 @@ func init() {
 
 func getContextFreeData(context Context) interface{} {
 	return func(index uint32, ptr uint32, length uint32) int32 {
-		trx, err := context.GetApplyContext().GetPackedTransaction().GetSignedTransaction()
-
-		if err != nil {
-			panic("could not get signed transaction")
+		if !context.GetApplyContext().IsContextFree() {
+			panic("this API may only be called from context_free apply")
 		}
 
-		if int(index) >= len(trx.ContextFreeData) {
+		data, ok := context.GetApplyContext().GetContextFreeData(index)
+
+		if !ok {
 			return -1
 		}
 
-		size := trx.ContextFreeData[index].Size()
-
 		if length == 0 {
-			return int32(size)
+			return int32(len(data))
 		}
 
-		copySize := utils.MinInt(int(length), size)
-		context.WriteMemory(ptr, trx.ContextFreeData[index][:copySize])
+		copySize := utils.MinInt(int(length), len(data))
+		context.WriteMemory(ptr, data[:copySize])
 
 		return int32(copySize)
 	}
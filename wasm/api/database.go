@@ -1,6 +1,8 @@
 package api
 
 import (
+	"strings"
+
 	"github.com/MetalBlockchain/antelopevm/chain/name"
 	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
 	"github.com/MetalBlockchain/antelopevm/math"
@@ -91,6 +93,12 @@ func init() {
 	Functions["db_idx_long_double_end"] = endIdx64
 	Functions["db_idx_long_double_next"] = nextIdx64
 	Functions["db_idx_long_double_previous"] = previousIdx64
+
+	for name := range Functions {
+		if strings.HasPrefix(name, "db_") {
+			ContextAware[name] = true
+		}
+	}
 }
 
 func storeI64(context Context) interface{} {
@@ -3,6 +3,7 @@ package api
 import (
 	"github.com/MetalBlockchain/antelopevm/chain/authority"
 	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/time"
 	"github.com/MetalBlockchain/antelopevm/chain/transaction"
 	"github.com/MetalBlockchain/antelopevm/crypto/ecc"
 	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
@@ -14,6 +15,11 @@ func init() {
 	Functions["check_permission_authorization"] = checkPermissionAuthorization
 	Functions["get_permission_last_used"] = getPermissionLastUsed
 	Functions["get_account_creation_time"] = getAccountCreationTime
+
+	ContextAware["check_transaction_authorization"] = true
+	ContextAware["check_permission_authorization"] = true
+	ContextAware["get_permission_last_used"] = true
+	ContextAware["get_account_creation_time"] = true
 }
 
 func checkTransactionAuthorization(context Context) interface{} {
@@ -24,7 +30,7 @@ func checkTransactionAuthorization(context Context) interface{} {
 
 		// Parse trx data
 		trx := transaction.Transaction{}
-		if err := rlp.DecodeBytes(trxDataBytes, trx); err != nil {
+		if err := rlp.DecodeBytes(trxDataBytes, &trx); err != nil {
 			panic("could not decode transaction data")
 		}
 
@@ -34,7 +40,7 @@ func checkTransactionAuthorization(context Context) interface{} {
 		// Parse permission levels
 		permLevelSet := buildPermissionLevelSet(permsBytes)
 
-		if err := context.GetAuthorizationManager().CheckAuthorization(trx.Actions, pubKeySet, permLevelSet.Slice(), false, nil); err == nil {
+		if err := context.GetAuthorizationManager().CheckAuthorization(trx.Actions, pubKeySet, permLevelSet.Slice(), time.Seconds(int64(trx.DelaySec)), false, nil); err == nil {
 			return 1
 		}
 
@@ -53,7 +59,7 @@ func checkPermissionAuthorization(context Context) interface{} {
 		// Parse permission levels
 		permLevelSet := buildPermissionLevelSet(permsBytes)
 
-		if err := context.GetAuthorizationManager().CheckAuthorizationByPermissionLevel(account, permission, pubKeySet, permLevelSet.Slice(), false); err == nil {
+		if err := context.GetAuthorizationManager().CheckAuthorizationByPermissionLevel(account, permission, pubKeySet, permLevelSet.Slice(), time.Microseconds(delay), false); err == nil {
 			return 1
 		}
 
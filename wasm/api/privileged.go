@@ -3,6 +3,9 @@ package api
 import (
 	"github.com/MetalBlockchain/antelopevm/chain/name"
 	"github.com/MetalBlockchain/antelopevm/chain/producer"
+	"github.com/MetalBlockchain/antelopevm/chain/protocol"
+	"github.com/MetalBlockchain/antelopevm/crypto"
+	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
 )
 
 func init() {
@@ -21,6 +24,29 @@ func init() {
 	Functions["set_parameters_packed"] = setParametersPacked
 	Functions["is_privileged"] = isPrivileged
 	Functions["set_privileged"] = setPrivileged
+
+	RequiredFeatures["preactivate_feature"] = protocol.PreactivateFeature
+	RequiredFeatures["get_wasm_parameters_packed"] = protocol.ConfigurableWasmLimits
+	RequiredFeatures["set_wasm_parameters_packed"] = protocol.ConfigurableWasmLimits
+	RequiredFeatures["set_proposed_producers_ex"] = protocol.WtmsigBlockSignatures
+	RequiredFeatures["get_parameters_packed"] = protocol.BlockchainParameters
+	RequiredFeatures["set_parameters_packed"] = protocol.BlockchainParameters
+
+	ContextAware["is_feature_active"] = true
+	ContextAware["activate_feature"] = true
+	ContextAware["preactivate_feature"] = true
+	ContextAware["set_resource_limits"] = true
+	ContextAware["get_resource_limits"] = true
+	ContextAware["get_wasm_parameters_packed"] = true
+	ContextAware["set_wasm_parameters_packed"] = true
+	ContextAware["set_proposed_producers"] = true
+	ContextAware["set_proposed_producers_ex"] = true
+	ContextAware["get_blockchain_parameters_packed"] = true
+	ContextAware["set_blockchain_parameters_packed"] = true
+	ContextAware["get_parameters_packed"] = true
+	ContextAware["set_parameters_packed"] = true
+	ContextAware["is_privileged"] = true
+	ContextAware["set_privileged"] = true
 }
 
 func isFeatureActive(context Context) interface{} {
@@ -43,7 +69,12 @@ func preactivateFeature(context Context) interface{} {
 	return func(ptr uint32) {
 		checkPrivileged(context)
 
-		panic("not supported")
+		digest := crypto.NewSha256Byte(context.ReadMemory(ptr, 32))
+		pendingBlockTime := context.GetController().PendingBlockTime()
+
+		if err := context.GetProtocolFeatureManager().PreactivateFeature(*digest, pendingBlockTime); err != nil {
+			panic(err)
+		}
 	}
 }
 
@@ -102,6 +133,7 @@ func setWasmParametersPacked(context Context) interface{} {
 func setProposedProducers(context Context) interface{} {
 	return func(ptr uint32, length uint32) int64 {
 		checkPrivileged(context)
+		checkProducerSchedule(context, ptr, length)
 
 		panic("not supported")
 	}
@@ -110,6 +142,7 @@ func setProposedProducers(context Context) interface{} {
 func setProposedProducersEx(context Context) interface{} {
 	return func(format uint64, ptr uint32, length uint32) int64 {
 		checkPrivileged(context)
+		checkProducerSchedule(context, ptr, length)
 
 		panic("not supported")
 	}
@@ -179,6 +212,25 @@ func setProposedProducersCommon(context Context, producers []producer.ProducerKe
 	panic("not implemented")
 }
 
+// checkProducerSchedule rejects empty producer schedules once
+// DISALLOW_EMPTY_PRODUCER_SCHEDULE is active, both schedule formats start with
+// the number of producers
+func checkProducerSchedule(context Context, ptr uint32, length uint32) {
+	producers, err := rlp.NewDecoder(context.ReadMemory(ptr, length)).ReadUvarint32()
+
+	if err != nil {
+		panic(err)
+	}
+
+	disallowEmpty, err := context.GetProtocolFeatureManager().IsBuiltinFeatureActivated(protocol.DisallowEmptyProducerSchedule)
+
+	if err != nil {
+		panic(err)
+	}
+
+	eosAssert(producers > 0 || !disallowEmpty, "Producer schedule cannot be empty")
+}
+
 func checkPrivileged(context Context) {
 	if !context.GetApplyContext().IsContextPrivileged() {
 		panic(context.GetApplyContext().GetReceiver().String() + " does not have permission to call this API")
@@ -7,6 +7,8 @@ import (
 
 func init() {
 	Functions["get_active_producers"] = getActiveProducers
+
+	ContextAware["get_active_producers"] = true
 }
 
 func getActiveProducers(context Context) interface{} {
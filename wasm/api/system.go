@@ -1,19 +1,46 @@
 package api
 
 import (
-	"github.com/MetalBlockchain/antelopevm/chain/time"
+	"github.com/MetalBlockchain/antelopevm/chain/fc"
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/protocol"
+	"github.com/MetalBlockchain/antelopevm/crypto"
+	"github.com/MetalBlockchain/antelopevm/crypto/rlp"
 )
 
+// codeHashResult is version 0 of the struct get_code_hash packs
+type codeHashResult struct {
+	StructVersion fc.UnsignedInt
+	CodeSequence  uint64
+	CodeHash      crypto.Sha256
+	VmType        uint8
+	VmVersion     uint8
+}
+
 func init() {
 	Functions["current_time"] = currentTime
 	Functions["publication_time"] = publicationTime
 	Functions["is_feature_activated"] = isFeatureActivated
 	Functions["get_sender"] = getSender
+	Functions["get_code_hash"] = getCodeHash
+	Functions["get_block_num"] = getBlockNum
+
+	RequiredFeatures["is_feature_activated"] = protocol.PreactivateFeature
+	RequiredFeatures["get_sender"] = protocol.GetSender
+	RequiredFeatures["get_code_hash"] = protocol.GetCodeHash
+	RequiredFeatures["get_block_num"] = protocol.GetBlockNum
+
+	ContextAware["current_time"] = true
+	ContextAware["publication_time"] = true
+	ContextAware["is_feature_activated"] = true
+	ContextAware["get_sender"] = true
+	ContextAware["get_code_hash"] = true
+	ContextAware["get_block_num"] = true
 }
 
 func currentTime(context Context) interface{} {
 	return func() uint64 {
-		currentTime := time.Now().TimeSinceEpoch().Count()
+		currentTime := context.GetController().PendingBlockTime().TimeSinceEpoch().Count()
 
 		return uint64(currentTime)
 	}
@@ -29,6 +56,17 @@ func publicationTime(context Context) interface{} {
 
 func isFeatureActivated(context Context) interface{} {
 	return func(ptr uint32) uint32 {
+		digest := crypto.NewSha256Byte(context.ReadMemory(ptr, 32))
+		activated, err := context.GetProtocolFeatureManager().IsFeatureActivated(*digest)
+
+		if err != nil {
+			panic(err)
+		}
+
+		if activated {
+			return 1
+		}
+
 		return 0
 	}
 }
@@ -46,3 +84,36 @@ func getSender(context Context) interface{} {
 		return 0
 	}
 }
+
+func getCodeHash(context Context) interface{} {
+	return func(account name.AccountName, structVersion uint32, ptr uint32, length uint32) uint32 {
+		result := codeHashResult{}
+
+		// Accounts without code, or no account at all, get an empty hash
+		if metadata, err := context.GetApplyContext().FindAccountMetaData(account); err == nil {
+			result.CodeSequence = metadata.CodeSequence
+			result.CodeHash = metadata.CodeHash
+			result.VmType = metadata.VmType
+			result.VmVersion = metadata.VmVersion
+		}
+
+		packed, err := rlp.EncodeToBytes(&result)
+
+		if err != nil {
+			panic(err)
+		}
+
+		// The caller retries with a bigger buffer when it is too small
+		if uint32(len(packed)) <= length {
+			context.WriteMemory(ptr, packed)
+		}
+
+		return uint32(len(packed))
+	}
+}
+
+func getBlockNum(context Context) interface{} {
+	return func() uint32 {
+		return context.GetController().PendingBlockNum()
+	}
+}
@@ -12,6 +12,11 @@ func init() {
 	Functions["send_context_free_inline"] = sendContextFreeInline
 	Functions["send_deferred"] = sendDeferred
 	Functions["cancel_deferred"] = cancelDeferred
+
+	ContextAware["send_inline"] = true
+	ContextAware["send_context_free_inline"] = true
+	ContextAware["send_deferred"] = true
+	ContextAware["cancel_deferred"] = true
 }
 
 func sendInline(context Context) interface{} {
@@ -46,7 +51,7 @@ func sendContextFreeInline(context Context) interface{} {
 			panic("failed to decode action")
 		}
 
-		if err := context.GetApplyContext().ExecuteInline(*action); err != nil {
+		if err := context.GetApplyContext().ExecuteContextFreeInline(*action); err != nil {
 			panic(err)
 		}
 	}
@@ -54,12 +59,33 @@ func sendContextFreeInline(context Context) interface{} {
 
 func sendDeferred(context Context) interface{} {
 	return func(ptrSender uint32, payer name.AccountName, ptrData, ptrLength, replaceExisting uint32) {
-		panic("not implemented")
+		senderId := readUint128(context, ptrSender)
+		data := context.ReadMemory(ptrData, ptrLength)
+		trx := &transaction.Transaction{}
+
+		if err := rlp.DecodeBytes(data, trx); err != nil {
+			panic("failed to decode transaction")
+		}
+
+		if err := context.GetApplyContext().ScheduleDeferredTransaction(senderId, payer, trx, replaceExisting > 0); err != nil {
+			panic(err)
+		}
 	}
 }
 
 func cancelDeferred(context Context) interface{} {
 	return func(ptr uint32) int32 {
+		senderId := readUint128(context, ptr)
+		cancelled, err := context.GetApplyContext().CancelDeferredTransaction(senderId)
+
+		if err != nil {
+			panic(err)
+		}
+
+		if cancelled {
+			return 1
+		}
+
 		return 0
 	}
 }
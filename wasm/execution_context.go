@@ -14,25 +14,31 @@ import (
 var _ wasmApi.Context = &ExecutionContext{}
 
 type ExecutionContext struct {
+	runtime               *Runtime
 	memory                api.Memory
 	controller            wasmApi.Controller
 	transactionContext    wasmApi.TransactionContext
 	applyContext          wasmApi.ApplyContext
 	authorizationManager  wasmApi.AuthorizationManager
 	resourceLimitsManager wasmApi.ResourceLimitsManager
+	protocolFeatures      wasmApi.ProtocolFeatureManager
 	idx64                 wasmApi.MultiIndex[uint64]
 	idx128                wasmApi.MultiIndex[math.Uint128]
 	idx256                wasmApi.MultiIndex[math.Uint256]
 	idxDouble             wasmApi.MultiIndex[float64]
 	idxLongDouble         wasmApi.MultiIndex[math.Float128]
+	instantiationTime     time.Duration
+	intrinsics            map[string]interface{}
 }
 
 func NewWasmExecutionContext(context context.Context,
+	runtime *Runtime,
 	controller wasmApi.Controller,
 	transactionContext wasmApi.TransactionContext,
 	applyContext wasmApi.ApplyContext,
 	authorizationManager wasmApi.AuthorizationManager,
 	resourceLimitsManager wasmApi.ResourceLimitsManager,
+	protocolFeatures wasmApi.ProtocolFeatureManager,
 	idx64 wasmApi.MultiIndex[uint64],
 	idx128 wasmApi.MultiIndex[math.Uint128],
 	idx256 wasmApi.MultiIndex[math.Uint256],
@@ -40,39 +46,68 @@ func NewWasmExecutionContext(context context.Context,
 	idxLongDouble wasmApi.MultiIndex[math.Float128],
 ) *ExecutionContext {
 	return &ExecutionContext{
+		runtime:               runtime,
 		controller:            controller,
 		transactionContext:    transactionContext,
 		applyContext:          applyContext,
 		authorizationManager:  authorizationManager,
 		resourceLimitsManager: resourceLimitsManager,
+		protocolFeatures:      protocolFeatures,
 		idx64:                 idx64,
 		idx128:                idx128,
 		idx256:                idx256,
 		idxDouble:             idxDouble,
 		idxLongDouble:         idxLongDouble,
+		intrinsics:            make(map[string]interface{}),
 	}
 }
 
-func (c *ExecutionContext) Exec(wasmCode []byte) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
-	defer cancel()
-	runtime := wazero.NewRuntime(ctx)
-	// This closes everything this runtime created
-	defer runtime.Close(ctx)
-	builder := runtime.NewHostModuleBuilder("env")
+// Exec runs the contract stored under [key] in a fresh module instance, the
+// compiled contract is shared and [loadCode] is only called to compile it
+func (c *ExecutionContext) Exec(key ModuleKey, loadCode func() ([]byte, error)) error {
+	// Host functions look up the action they are called for here
+	ctx := context.WithValue(context.Background(), executionContextKey{}, c)
 
-	for name, function := range wasmApi.Functions {
-		builder.NewFunctionBuilder().WithFunc(function(c)).Export(name)
+	// Transactions replayed from a block are bound by the CPU time billed by
+	// the block producer instead of the wall clock of this node
+	if c.transactionContext == nil || !c.transactionContext.IsExplicitlyBilled() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
 	}
 
-	if _, err := builder.Instantiate(ctx); err != nil {
+	if err := c.runtime.instantiateHostModule(); err != nil {
 		return err
 	}
 
-	module, err := runtime.Instantiate(ctx, wasmCode)
+	start := time.Now()
+	compiled, err := c.runtime.module(ctx, key, loadCode)
+
+	if err != nil {
+		c.instantiationTime = time.Since(start)
+		return err
+	}
+
+	// Contracts importing a gated intrinsic fail to instantiate until it is activated
+	for _, name := range compiled.imports {
+		activated, err := c.protocolFeatures.IsBuiltinFeatureActivated(wasmApi.RequiredFeatures[name])
+
+		if err != nil {
+			return err
+		}
+
+		if !activated {
+			return fmt.Errorf("%s is not exported in module env", name)
+		}
+	}
+
+	// Every action gets its own anonymous instance so memory is never shared
+	module, err := c.runtime.runtime.InstantiateModule(ctx, compiled.module, wazero.NewModuleConfig().WithName(""))
+	c.instantiationTime = time.Since(start)
 	if err != nil {
 		return err
 	}
+	defer module.Close(ctx)
 	c.memory = module.Memory()
 
 	// All Leap contracts export the apply function as the main entrypoint
@@ -93,6 +128,29 @@ func (c *ExecutionContext) Exec(wasmCode []byte) error {
 	return nil
 }
 
+// intrinsic returns the host function [name] bound to this context, context
+// free actions can import but not call context aware intrinsics
+func (c *ExecutionContext) intrinsic(name string) interface{} {
+	if c.applyContext.IsContextFree() && wasmApi.ContextAware[name] {
+		panic("only context free api's can be used in this context")
+	}
+
+	if function, ok := c.intrinsics[name]; ok {
+		return function
+	}
+
+	function := wasmApi.Functions[name](c)
+	c.intrinsics[name] = function
+
+	return function
+}
+
+// InstantiationTime returns how long it took to compile, when not cached, and
+// instantiate the module during the last call to Exec
+func (c *ExecutionContext) InstantiationTime() time.Duration {
+	return c.instantiationTime
+}
+
 // This function will read an array of bytes from the WASM memory, it panics on purpose when the read is out of range to kill the WASM execution environment
 func (c *ExecutionContext) ReadMemory(start uint32, length uint32) []byte {
 	if data, ok := c.memory.Read(start, length); !ok {
@@ -133,6 +191,10 @@ func (c *ExecutionContext) GetResourceLimitsManager() wasmApi.ResourceLimitsMana
 	return c.resourceLimitsManager
 }
 
+func (c *ExecutionContext) GetProtocolFeatureManager() wasmApi.ProtocolFeatureManager {
+	return c.protocolFeatures
+}
+
 func (c *ExecutionContext) GetIdx64() wasmApi.MultiIndex[uint64] {
 	return c.idx64
 }
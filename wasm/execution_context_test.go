@@ -0,0 +1,109 @@
+package wasm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/protocol"
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/MetalBlockchain/antelopevm/crypto"
+	wasmApi "github.com/MetalBlockchain/antelopevm/wasm/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// sendDeferredWasm is a contract whose apply function calls send_deferred
+var sendDeferredWasm = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	// Types: send_deferred(i32, i64, i32, i32, i32) and apply(i64, i64, i64)
+	0x01, 0x0f, 0x02, 0x60, 0x05, 0x7f, 0x7e, 0x7f, 0x7f, 0x7f, 0x00, 0x60, 0x03, 0x7e, 0x7e, 0x7e, 0x00,
+	// Imports: env.send_deferred
+	0x02, 0x15, 0x01, 0x03, 'e', 'n', 'v', 0x0d, 's', 'e', 'n', 'd', '_', 'd', 'e', 'f', 'e', 'r', 'r', 'e', 'd', 0x00, 0x00,
+	// Functions: apply
+	0x03, 0x02, 0x01, 0x01,
+	// Memory: one page
+	0x05, 0x03, 0x01, 0x00, 0x01,
+	// Exports: apply and memory
+	0x07, 0x12, 0x02, 0x05, 'a', 'p', 'p', 'l', 'y', 0x00, 0x01, 0x06, 'm', 'e', 'm', 'o', 'r', 'y', 0x02, 0x00,
+	// Code: apply calls send_deferred(0, 0, 0, 0, 0)
+	0x0a, 0x10, 0x01, 0x0e, 0x00, 0x41, 0x00, 0x42, 0x00, 0x41, 0x00, 0x41, 0x00, 0x41, 0x00, 0x10, 0x00, 0x0b,
+}
+
+func TestContextFreeActionCannotSendDeferred(t *testing.T) {
+	runtime := NewRuntime(DefaultModuleCacheSize)
+	defer runtime.Close(context.Background())
+
+	applyContext := &testApplyContext{
+		action: transaction.Action{
+			Account: name.StringToName("deferred"),
+			Name:    name.StringToName("send"),
+		},
+		contextFree: true,
+	}
+	module := NewWasmExecutionContext(context.Background(), runtime, nil, nil, applyContext, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	err := module.Exec(ModuleKey{CodeHash: *crypto.Hash256(sendDeferredWasm)}, func() ([]byte, error) {
+		return sendDeferredWasm, nil
+	})
+	assert.ErrorContains(t, err, "only context free")
+}
+
+// getBlockNumWasm is a contract whose apply function prints get_block_num
+var getBlockNumWasm = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	// Types: get_block_num() i32, printui(i64) and apply(i64, i64, i64)
+	0x01, 0x0f, 0x03, 0x60, 0x00, 0x01, 0x7f, 0x60, 0x01, 0x7e, 0x00, 0x60, 0x03, 0x7e, 0x7e, 0x7e, 0x00,
+	// Imports: env.get_block_num and env.printui
+	0x02, 0x23, 0x02,
+	0x03, 'e', 'n', 'v', 0x0d, 'g', 'e', 't', '_', 'b', 'l', 'o', 'c', 'k', '_', 'n', 'u', 'm', 0x00, 0x00,
+	0x03, 'e', 'n', 'v', 0x07, 'p', 'r', 'i', 'n', 't', 'u', 'i', 0x00, 0x01,
+	// Functions: apply
+	0x03, 0x02, 0x01, 0x02,
+	// Memory: one page
+	0x05, 0x03, 0x01, 0x00, 0x01,
+	// Exports: apply and memory
+	0x07, 0x12, 0x02, 0x05, 'a', 'p', 'p', 'l', 'y', 0x00, 0x02, 0x06, 'm', 'e', 'm', 'o', 'r', 'y', 0x02, 0x00,
+	// Code: apply calls printui(get_block_num())
+	0x0a, 0x09, 0x01, 0x07, 0x00, 0x10, 0x00, 0xad, 0x10, 0x01, 0x0b,
+}
+
+type testController struct {
+	wasmApi.Controller
+	blockNum uint32
+}
+
+func (c *testController) PendingBlockNum() uint32 { return c.blockNum }
+
+type testProtocolFeatures struct {
+	wasmApi.ProtocolFeatureManager
+	activated map[protocol.BuiltinProtocolFeatureType]bool
+}
+
+func (m *testProtocolFeatures) IsBuiltinFeatureActivated(codename protocol.BuiltinProtocolFeatureType) (bool, error) {
+	return m.activated[codename], nil
+}
+
+func TestGetBlockNumRequiresFeature(t *testing.T) {
+	runtime := NewRuntime(DefaultModuleCacheSize)
+	defer runtime.Close(context.Background())
+
+	controller := &testController{blockNum: 42}
+	features := &testProtocolFeatures{activated: make(map[protocol.BuiltinProtocolFeatureType]bool)}
+	exec := func() (string, error) {
+		applyContext := &testApplyContext{action: transaction.Action{Account: name.StringToName("blocknum")}}
+		module := NewWasmExecutionContext(context.Background(), runtime, controller, nil, applyContext, nil, nil, features, nil, nil, nil, nil, nil)
+		err := module.Exec(ModuleKey{CodeHash: *crypto.Hash256(getBlockNumWasm)}, func() ([]byte, error) {
+			return getBlockNumWasm, nil
+		})
+
+		return applyContext.console, err
+	}
+
+	_, err := exec()
+	assert.ErrorContains(t, err, "get_block_num is not exported")
+
+	features.activated[protocol.GetBlockNum] = true
+	console, err := exec()
+	assert.NoError(t, err)
+	assert.Equal(t, "42", console)
+}
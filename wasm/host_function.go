@@ -0,0 +1,334 @@
+package wasm
+
+import (
+	"context"
+	"fmt"
+	"unsafe"
+
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+	wasmApi "github.com/MetalBlockchain/antelopevm/wasm/api"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// wasmValue are the Go types intrinsics take and return, 32-bit types map to
+// i32 and 64-bit types to i64
+type wasmValue interface {
+	~int32 | ~uint32 | ~int64 | ~uint64
+}
+
+func valueType[T wasmValue]() api.ValueType {
+	var value T
+
+	if unsafe.Sizeof(value) == 4 {
+		return api.ValueTypeI32
+	}
+
+	return api.ValueTypeI64
+}
+
+func fromStack[T wasmValue](value uint64) T {
+	return T(value)
+}
+
+// toStack does not sign extend 32-bit values, wazero expects the upper bits
+// of an i32 to be zero
+func toStack[T wasmValue](value T) uint64 {
+	if unsafe.Sizeof(value) == 4 {
+		return uint64(uint32(value))
+	}
+
+	return uint64(value)
+}
+
+// hostFunction is an intrinsic exported to contracts, its arguments and
+// results are passed on the wazero stack
+type hostFunction struct {
+	function api.GoModuleFunc
+	params   []api.ValueType
+	results  []api.ValueType
+}
+
+// intrinsic returns intrinsic [name] bound to the action the call context
+// belongs to
+func intrinsic[F any](ctx context.Context, name string) F {
+	return ctx.Value(executionContextKey{}).(*ExecutionContext).intrinsic(name).(F)
+}
+
+// newHostFunction picks the adapter matching the signature of intrinsic [export],
+// the intrinsic itself is bound to the running action on every call
+func newHostFunction(export string, function func(wasmApi.Context) interface{}) (hostFunction, error) {
+	switch function(nil).(type) {
+	case func():
+		return call0(export), nil
+	case func() name.Name:
+		return call0r[name.Name](export), nil
+	case func() uint32:
+		return call0r[uint32](export), nil
+	case func() uint64:
+		return call0r[uint64](export), nil
+	case func(int32):
+		return call1[int32](export), nil
+	case func(int32) int64:
+		return call1r[int32, int64](export), nil
+	case func(int32, name.Name, uint32):
+		return call3[int32, name.Name, uint32](export), nil
+	case func(int32, uint32) int32:
+		return call2r[int32, uint32, int32](export), nil
+	case func(int32, uint32, uint32) int32:
+		return call3r[int32, uint32, uint32, int32](export), nil
+	case func(int64):
+		return call1[int64](export), nil
+	case func(int64, int64, int64, int64) int64:
+		return call4r[int64, int64, int64, int64, int64](export), nil
+	case func(name.Name):
+		return call1[name.Name](export), nil
+	case func(name.Name) int32:
+		return call1r[name.Name, int32](export), nil
+	case func(name.Name) int64:
+		return call1r[name.Name, int64](export), nil
+	case func(name.Name) uint32:
+		return call1r[name.Name, uint32](export), nil
+	case func(name.Name, int32):
+		return call2[name.Name, int32](export), nil
+	case func(name.Name, int64, int64, int64):
+		return call4[name.Name, int64, int64, int64](export), nil
+	case func(name.Name, name.Name):
+		return call2[name.Name, name.Name](export), nil
+	case func(name.Name, name.Name) int64:
+		return call2r[name.Name, name.Name, int64](export), nil
+	case func(name.Name, name.Name, name.Name) int32:
+		return call3r[name.Name, name.Name, name.Name, int32](export), nil
+	case func(name.Name, name.Name, name.Name, uint32, uint32) int32:
+		return call5r[name.Name, name.Name, name.Name, uint32, uint32, int32](export), nil
+	case func(name.Name, name.Name, name.Name, uint32, uint64) int32:
+		return call5r[name.Name, name.Name, name.Name, uint32, uint64, int32](export), nil
+	case func(name.Name, name.Name, name.Name, uint64) int32:
+		return call4r[name.Name, name.Name, name.Name, uint64, int32](export), nil
+	case func(name.Name, name.Name, name.Name, uint64, uint32) int32:
+		return call5r[name.Name, name.Name, name.Name, uint64, uint32, int32](export), nil
+	case func(name.Name, name.Name, name.Name, uint64, uint32, uint32) int32:
+		return call6r[name.Name, name.Name, name.Name, uint64, uint32, uint32, int32](export), nil
+	case func(name.Name, name.Name, uint32, uint32, uint32, uint32, uint64) int32:
+		return call7r[name.Name, name.Name, uint32, uint32, uint32, uint32, uint64, int32](export), nil
+	case func(name.Name, uint32, uint32, uint32):
+		return call4[name.Name, uint32, uint32, uint32](export), nil
+	case func(name.Name, uint32, uint32, uint32) uint32:
+		return call4r[name.Name, uint32, uint32, uint32, uint32](export), nil
+	case func(uint32):
+		return call1[uint32](export), nil
+	case func(uint32) int32:
+		return call1r[uint32, int32](export), nil
+	case func(uint32) uint32:
+		return call1r[uint32, uint32](export), nil
+	case func(uint32, int32):
+		return call2[uint32, int32](export), nil
+	case func(uint32, int32, uint32) uint32:
+		return call3r[uint32, int32, uint32, uint32](export), nil
+	case func(uint32, int64):
+		return call2[uint32, int64](export), nil
+	case func(uint32, name.Name, uint32, uint32):
+		return call4[uint32, name.Name, uint32, uint32](export), nil
+	case func(uint32, name.Name, uint32, uint32, uint32):
+		return call5[uint32, name.Name, uint32, uint32, uint32](export), nil
+	case func(uint32, uint32):
+		return call2[uint32, uint32](export), nil
+	case func(uint32, uint32) int32:
+		return call2r[uint32, uint32, int32](export), nil
+	case func(uint32, uint32) int64:
+		return call2r[uint32, uint32, int64](export), nil
+	case func(uint32, uint32) uint32:
+		return call2r[uint32, uint32, uint32](export), nil
+	case func(uint32, uint32, uint32):
+		return call3[uint32, uint32, uint32](export), nil
+	case func(uint32, uint32, uint32) int32:
+		return call3r[uint32, uint32, uint32, int32](export), nil
+	case func(uint32, uint32, uint32) uint32:
+		return call3r[uint32, uint32, uint32, uint32](export), nil
+	case func(uint32, uint32, uint32, uint32) int32:
+		return call4r[uint32, uint32, uint32, uint32, int32](export), nil
+	case func(uint32, uint32, uint32, uint32) uint32:
+		return call4r[uint32, uint32, uint32, uint32, uint32](export), nil
+	case func(uint32, uint32, uint32, uint32, uint32):
+		return call5[uint32, uint32, uint32, uint32, uint32](export), nil
+	case func(uint32, uint32, uint32, uint32, uint32) int32:
+		return call5r[uint32, uint32, uint32, uint32, uint32, int32](export), nil
+	case func(uint32, uint32, uint32, uint32, uint32, uint32) int32:
+		return call6r[uint32, uint32, uint32, uint32, uint32, uint32, int32](export), nil
+	case func(uint32, uint64):
+		return call2[uint32, uint64](export), nil
+	case func(uint32, uint64, uint64):
+		return call3[uint32, uint64, uint64](export), nil
+	case func(uint32, uint64, uint64, uint32):
+		return call4[uint32, uint64, uint64, uint32](export), nil
+	case func(uint32, uint64, uint64, uint64, uint64):
+		return call5[uint32, uint64, uint64, uint64, uint64](export), nil
+	case func(uint64):
+		return call1[uint64](export), nil
+	case func(uint64, uint32, uint32) int64:
+		return call3r[uint64, uint32, uint32, int64](export), nil
+	case func(uint64, uint64) int32:
+		return call2r[uint64, uint64, int32](export), nil
+	case func(uint64, uint64) int64:
+		return call2r[uint64, uint64, int64](export), nil
+	case func(uint64, uint64) uint32:
+		return call2r[uint64, uint64, uint32](export), nil
+	case func(uint64, uint64) uint64:
+		return call2r[uint64, uint64, uint64](export), nil
+	}
+
+	return hostFunction{}, fmt.Errorf("intrinsic %s has unsupported signature %T", export, function(nil))
+}
+
+func call0(export string) hostFunction {
+	return hostFunction{
+		function: func(ctx context.Context, _ api.Module, stack []uint64) {
+			f := intrinsic[func()](ctx, export)
+			f()
+		},
+		params:  nil,
+		results: nil,
+	}
+}
+
+func call0r[R wasmValue](export string) hostFunction {
+	return hostFunction{
+		function: func(ctx context.Context, _ api.Module, stack []uint64) {
+			f := intrinsic[func() R](ctx, export)
+			stack[0] = toStack(f())
+		},
+		params:  nil,
+		results: []api.ValueType{valueType[R]()},
+	}
+}
+
+func call1[P1 wasmValue](export string) hostFunction {
+	return hostFunction{
+		function: func(ctx context.Context, _ api.Module, stack []uint64) {
+			f := intrinsic[func(P1)](ctx, export)
+			f(fromStack[P1](stack[0]))
+		},
+		params:  []api.ValueType{valueType[P1]()},
+		results: nil,
+	}
+}
+
+func call1r[P1, R wasmValue](export string) hostFunction {
+	return hostFunction{
+		function: func(ctx context.Context, _ api.Module, stack []uint64) {
+			f := intrinsic[func(P1) R](ctx, export)
+			stack[0] = toStack(f(fromStack[P1](stack[0])))
+		},
+		params:  []api.ValueType{valueType[P1]()},
+		results: []api.ValueType{valueType[R]()},
+	}
+}
+
+func call2[P1, P2 wasmValue](export string) hostFunction {
+	return hostFunction{
+		function: func(ctx context.Context, _ api.Module, stack []uint64) {
+			f := intrinsic[func(P1, P2)](ctx, export)
+			f(fromStack[P1](stack[0]), fromStack[P2](stack[1]))
+		},
+		params:  []api.ValueType{valueType[P1](), valueType[P2]()},
+		results: nil,
+	}
+}
+
+func call2r[P1, P2, R wasmValue](export string) hostFunction {
+	return hostFunction{
+		function: func(ctx context.Context, _ api.Module, stack []uint64) {
+			f := intrinsic[func(P1, P2) R](ctx, export)
+			stack[0] = toStack(f(fromStack[P1](stack[0]), fromStack[P2](stack[1])))
+		},
+		params:  []api.ValueType{valueType[P1](), valueType[P2]()},
+		results: []api.ValueType{valueType[R]()},
+	}
+}
+
+func call3[P1, P2, P3 wasmValue](export string) hostFunction {
+	return hostFunction{
+		function: func(ctx context.Context, _ api.Module, stack []uint64) {
+			f := intrinsic[func(P1, P2, P3)](ctx, export)
+			f(fromStack[P1](stack[0]), fromStack[P2](stack[1]), fromStack[P3](stack[2]))
+		},
+		params:  []api.ValueType{valueType[P1](), valueType[P2](), valueType[P3]()},
+		results: nil,
+	}
+}
+
+func call3r[P1, P2, P3, R wasmValue](export string) hostFunction {
+	return hostFunction{
+		function: func(ctx context.Context, _ api.Module, stack []uint64) {
+			f := intrinsic[func(P1, P2, P3) R](ctx, export)
+			stack[0] = toStack(f(fromStack[P1](stack[0]), fromStack[P2](stack[1]), fromStack[P3](stack[2])))
+		},
+		params:  []api.ValueType{valueType[P1](), valueType[P2](), valueType[P3]()},
+		results: []api.ValueType{valueType[R]()},
+	}
+}
+
+func call4[P1, P2, P3, P4 wasmValue](export string) hostFunction {
+	return hostFunction{
+		function: func(ctx context.Context, _ api.Module, stack []uint64) {
+			f := intrinsic[func(P1, P2, P3, P4)](ctx, export)
+			f(fromStack[P1](stack[0]), fromStack[P2](stack[1]), fromStack[P3](stack[2]), fromStack[P4](stack[3]))
+		},
+		params:  []api.ValueType{valueType[P1](), valueType[P2](), valueType[P3](), valueType[P4]()},
+		results: nil,
+	}
+}
+
+func call4r[P1, P2, P3, P4, R wasmValue](export string) hostFunction {
+	return hostFunction{
+		function: func(ctx context.Context, _ api.Module, stack []uint64) {
+			f := intrinsic[func(P1, P2, P3, P4) R](ctx, export)
+			stack[0] = toStack(f(fromStack[P1](stack[0]), fromStack[P2](stack[1]), fromStack[P3](stack[2]), fromStack[P4](stack[3])))
+		},
+		params:  []api.ValueType{valueType[P1](), valueType[P2](), valueType[P3](), valueType[P4]()},
+		results: []api.ValueType{valueType[R]()},
+	}
+}
+
+func call5[P1, P2, P3, P4, P5 wasmValue](export string) hostFunction {
+	return hostFunction{
+		function: func(ctx context.Context, _ api.Module, stack []uint64) {
+			f := intrinsic[func(P1, P2, P3, P4, P5)](ctx, export)
+			f(fromStack[P1](stack[0]), fromStack[P2](stack[1]), fromStack[P3](stack[2]), fromStack[P4](stack[3]), fromStack[P5](stack[4]))
+		},
+		params:  []api.ValueType{valueType[P1](), valueType[P2](), valueType[P3](), valueType[P4](), valueType[P5]()},
+		results: nil,
+	}
+}
+
+func call5r[P1, P2, P3, P4, P5, R wasmValue](export string) hostFunction {
+	return hostFunction{
+		function: func(ctx context.Context, _ api.Module, stack []uint64) {
+			f := intrinsic[func(P1, P2, P3, P4, P5) R](ctx, export)
+			stack[0] = toStack(f(fromStack[P1](stack[0]), fromStack[P2](stack[1]), fromStack[P3](stack[2]), fromStack[P4](stack[3]), fromStack[P5](stack[4])))
+		},
+		params:  []api.ValueType{valueType[P1](), valueType[P2](), valueType[P3](), valueType[P4](), valueType[P5]()},
+		results: []api.ValueType{valueType[R]()},
+	}
+}
+
+func call6r[P1, P2, P3, P4, P5, P6, R wasmValue](export string) hostFunction {
+	return hostFunction{
+		function: func(ctx context.Context, _ api.Module, stack []uint64) {
+			f := intrinsic[func(P1, P2, P3, P4, P5, P6) R](ctx, export)
+			stack[0] = toStack(f(fromStack[P1](stack[0]), fromStack[P2](stack[1]), fromStack[P3](stack[2]), fromStack[P4](stack[3]), fromStack[P5](stack[4]), fromStack[P6](stack[5])))
+		},
+		params:  []api.ValueType{valueType[P1](), valueType[P2](), valueType[P3](), valueType[P4](), valueType[P5](), valueType[P6]()},
+		results: []api.ValueType{valueType[R]()},
+	}
+}
+
+func call7r[P1, P2, P3, P4, P5, P6, P7, R wasmValue](export string) hostFunction {
+	return hostFunction{
+		function: func(ctx context.Context, _ api.Module, stack []uint64) {
+			f := intrinsic[func(P1, P2, P3, P4, P5, P6, P7) R](ctx, export)
+			stack[0] = toStack(f(fromStack[P1](stack[0]), fromStack[P2](stack[1]), fromStack[P3](stack[2]), fromStack[P4](stack[3]), fromStack[P5](stack[4]), fromStack[P6](stack[5]), fromStack[P7](stack[6])))
+		},
+		params:  []api.ValueType{valueType[P1](), valueType[P2](), valueType[P3](), valueType[P4](), valueType[P5](), valueType[P6](), valueType[P7]()},
+		results: []api.ValueType{valueType[R]()},
+	}
+}
@@ -0,0 +1,168 @@
+package wasm
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/MetalBlockchain/antelopevm/crypto"
+	wasmApi "github.com/MetalBlockchain/antelopevm/wasm/api"
+	"github.com/tetratelabs/wazero"
+)
+
+// DefaultModuleCacheSize is the number of compiled contracts kept in memory
+const DefaultModuleCacheSize = 64
+
+// executionContextKey carries the *ExecutionContext of the running action
+// through wazero into the host functions
+type executionContextKey struct{}
+
+// ModuleKey identifies a compiled contract, it matches the key code objects
+// are stored under
+type ModuleKey struct {
+	CodeHash  crypto.Sha256
+	VmType    uint8
+	VmVersion uint8
+}
+
+type compiledModule struct {
+	key    ModuleKey
+	module wazero.CompiledModule
+	// Gated intrinsics the contract imports
+	imports []string
+}
+
+// Runtime is shared by all executions, it registers the host functions once
+// and keeps compiled contracts in an LRU cache so they are only compiled again
+// when evicted. Executions are serialized by the controller.
+type Runtime struct {
+	runtime wazero.Runtime
+	size    int
+
+	hostOnce  sync.Once
+	hostError error
+
+	lock    sync.Mutex
+	modules map[ModuleKey]*list.Element
+	lru     *list.List
+}
+
+func NewRuntime(size int) *Runtime {
+	config := wazero.NewRuntimeConfig().WithCompilationCache(wazero.NewCompilationCache())
+
+	return &Runtime{
+		runtime: wazero.NewRuntimeWithConfig(context.Background(), config),
+		size:    size,
+		modules: make(map[ModuleKey]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// instantiateHostModule exports every intrinsic in the env module, calls are
+// forwarded to the execution context found in the call context
+func (r *Runtime) instantiateHostModule() error {
+	r.hostOnce.Do(func() {
+		builder := r.runtime.NewHostModuleBuilder("env")
+
+		for name, function := range wasmApi.Functions {
+			host, err := newHostFunction(name, function)
+
+			if err != nil {
+				r.hostError = err
+				return
+			}
+
+			builder.NewFunctionBuilder().WithGoModuleFunction(host.function, host.params, host.results).Export(name)
+		}
+
+		_, r.hostError = builder.Instantiate(context.Background())
+	})
+
+	return r.hostError
+}
+
+// module returns the compiled contract for [key], [loadCode] is only called
+// when it is not cached
+func (r *Runtime) module(ctx context.Context, key ModuleKey, loadCode func() ([]byte, error)) (*compiledModule, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if element, ok := r.modules[key]; ok {
+		r.lru.MoveToFront(element)
+		return element.Value.(*compiledModule), nil
+	}
+
+	code, err := loadCode()
+
+	if err != nil {
+		return nil, err
+	}
+
+	module, err := r.runtime.CompileModule(ctx, code)
+
+	if err != nil {
+		return nil, err
+	}
+
+	compiled := &compiledModule{key: key, module: module}
+
+	for _, function := range module.ImportedFunctions() {
+		if moduleName, name, _ := function.Import(); moduleName == "env" {
+			if _, ok := wasmApi.RequiredFeatures[name]; ok {
+				compiled.imports = append(compiled.imports, name)
+			}
+		}
+	}
+
+	r.modules[key] = r.lru.PushFront(compiled)
+
+	for r.lru.Len() > r.size {
+		r.remove(r.lru.Back())
+	}
+
+	return compiled, nil
+}
+
+// Evict drops the compiled contract for [key]
+func (r *Runtime) Evict(key ModuleKey) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if element, ok := r.modules[key]; ok {
+		r.remove(element)
+	}
+}
+
+// Len returns the number of compiled contracts in the cache
+func (r *Runtime) Len() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.lru.Len()
+}
+
+func (r *Runtime) remove(element *list.Element) {
+	compiled := r.lru.Remove(element).(*compiledModule)
+	delete(r.modules, compiled.key)
+	compiled.module.Close(context.Background())
+}
+
+// Close releases the runtime and every compiled contract
+func (r *Runtime) Close(ctx context.Context) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.modules = make(map[ModuleKey]*list.Element)
+	r.lru.Init()
+
+	if err := r.runtime.Close(ctx); err != nil {
+		return fmt.Errorf("failed to close wasm runtime: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package wasm
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/MetalBlockchain/antelopevm/chain/name"
+	"github.com/MetalBlockchain/antelopevm/chain/transaction"
+	"github.com/MetalBlockchain/antelopevm/crypto"
+	wasmApi "github.com/MetalBlockchain/antelopevm/wasm/api"
+	"github.com/stretchr/testify/assert"
+)
+
+type testApplyContext struct {
+	wasmApi.ApplyContext
+	action      transaction.Action
+	console     string
+	contextFree bool
+}
+
+func (a *testApplyContext) GetAction() transaction.Action                       { return a.action }
+func (a *testApplyContext) GetReceiver() name.AccountName                       { return a.action.Account }
+func (a *testApplyContext) IsContextFree() bool                                 { return a.contextFree }
+func (a *testApplyContext) RequireAuthorization(account name.AccountName) error { return nil }
+func (a *testApplyContext) ConsoleAppend(value string)                          { a.console += value }
+
+func loader(path string, loads *int) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		*loads++
+		return os.ReadFile(path)
+	}
+}
+
+func TestRuntimeModuleCache(t *testing.T) {
+	runtime := NewRuntime(1)
+	defer runtime.Close(context.Background())
+
+	first := ModuleKey{CodeHash: *crypto.Hash256String("first")}
+	second := ModuleKey{CodeHash: *crypto.Hash256String("second")}
+	loads := 0
+
+	for i := 0; i < 2; i++ {
+		_, err := runtime.module(context.Background(), first, loader("eosio.token.wasm", &loads))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, loads)
+
+	// The least recently used contract makes room for the next one
+	_, err := runtime.module(context.Background(), second, loader("eosio.token.wasm", &loads))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, loads)
+	assert.Equal(t, 1, runtime.Len())
+
+	_, err = runtime.module(context.Background(), first, loader("eosio.token.wasm", &loads))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, loads)
+
+	runtime.Evict(first)
+	assert.Equal(t, 0, runtime.Len())
+
+	_, err = runtime.module(context.Background(), first, loader("eosio.token.wasm", &loads))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, loads)
+}
+
+func TestExecSharedRuntime(t *testing.T) {
+	runtime := NewRuntime(DefaultModuleCacheSize)
+	defer runtime.Close(context.Background())
+
+	key := ModuleKey{CodeHash: *crypto.Hash256String("hello")}
+	loads := 0
+
+	for _, user := range []string{"alice", "bob"} {
+		applyContext := &testApplyContext{
+			action: transaction.Action{
+				Account: name.StringToName("hello"),
+				Name:    name.StringToName("hi"),
+				Data:    name.StringToName(user).Pack(),
+			},
+		}
+		module := NewWasmExecutionContext(context.Background(), runtime, nil, nil, applyContext, nil, nil, nil, nil, nil, nil, nil, nil)
+
+		assert.NoError(t, module.Exec(key, loader("testdata/hello.wasm", &loads)))
+		assert.Contains(t, applyContext.console, user)
+	}
+
+	// Both actions ran on the contract compiled for the first one
+	assert.Equal(t, 1, loads)
+}
+
+func TestHostFunctions(t *testing.T) {
+	for name, function := range wasmApi.Functions {
+		_, err := newHostFunction(name, function)
+		assert.NoError(t, err, name)
+	}
+
+	// i32 results are not sign extended
+	assert.Equal(t, uint64(0xffffffff), toStack(int32(-1)))
+	assert.Equal(t, int32(-1), fromStack[int32](0xffffffff))
+	assert.Equal(t, uint64(1<<63), toStack(name.Name(1<<63)))
+}